@@ -52,5 +52,17 @@ func Run() error {
 	getCmd := getCMDs()
 	rootCmd.AddCommand(getCmd)
 
+	// Add lq subcommands
+	lqCmd := lqCMDs()
+	rootCmd.AddCommand(lqCmd)
+
+	// Add seencheck subcommands
+	seencheckCmd := seencheckCMDs()
+	rootCmd.AddCommand(seencheckCmd)
+
+	// Add package subcommands
+	packageCmd := packageCMDs()
+	rootCmd.AddCommand(packageCmd)
+
 	return rootCmd.Execute()
 }
@@ -22,6 +22,7 @@ func getCMDs() *cobra.Command {
 
 	getCmd.AddCommand(getURLCmd)
 	getCmd.AddCommand(getHQCmd)
+	getCmd.AddCommand(getListCmd)
 
 	return getCmd
 }
@@ -31,13 +32,33 @@ func getCMDsFlags(getCmd *cobra.Command) {
 	getCmd.PersistentFlags().String("job", "", "Job name to use, will determine the path for the persistent queue, seencheck database, and WARC files.")
 	getCmd.PersistentFlags().IntP("workers", "w", 1, "Number of concurrent workers to run.")
 	getCmd.PersistentFlags().Int("max-concurrent-assets", 1, "Max number of concurrent assets to fetch PER worker. E.g. if you have 100 workers and this setting at 8, Zeno could do up to 800 concurrent requests at any time.")
+	getCmd.PersistentFlags().Int("max-concurrent-per-host", 16, "Max number of concurrent requests to the same host, across all workers. 0 means no limit. Can be overridden per host with the max_concurrency of --rate-limit-rules.")
 	getCmd.PersistentFlags().Int("max-hops", 0, "Maximum number of hops to execute.")
-	getCmd.PersistentFlags().String("cookies", "", "File containing cookies that will be used for requests.")
+	getCmd.PersistentFlags().String("cookies", "", "File containing cookies that will be used for requests. Netscape/Mozilla cookies.txt and JSON exports are supported.")
+	getCmd.PersistentFlags().Bool("cookies-persist", false, "Store the cookies set by the crawled servers (Set-Cookie) in the cookie jar for the rest of the crawl.")
+	getCmd.PersistentFlags().String("header-profiles", "", "JSON file of per-site header profiles (host, domain or url_regex: headers) applied to the requests, evaluated before the built-in profiles. The first matching profile wins.")
+	getCmd.PersistentFlags().Bool("disable-default-header-profiles", false, "Disable the built-in header profiles of the sites that need specific headers.")
 	getCmd.PersistentFlags().Bool("disable-seencheck", false, "Disable the (remote or local) seencheck that avoid re-crawling of URIs.")
-	getCmd.PersistentFlags().Bool("api", false, "Enable API")
+	getCmd.PersistentFlags().Duration("seencheck-ttl", 0, "Time after which a URI seen by the local seencheck is crawled again, e.g. 24h. 0 means never.")
+	getCmd.PersistentFlags().String("seencheck-ttl-rules", "", "JSON file of per-host recrawl intervals for the local seencheck (host, domain or URL regex: ttl, \"0\" for never). The first matching rule wins, other URIs use --seencheck-ttl.")
+	getCmd.PersistentFlags().String("seencheck-hash", "fnv64", "Hash function the URIs are keyed with in the local seencheck: fnv64 or sha256 (truncated to 128 bits, for crawls large enough for FNV-64 to collide). It can't be changed once a job started.")
+	getCmd.PersistentFlags().Int("seencheck-filter-capacity", 10000000, "Number of URIs the in-memory bloom filter in front of the local seencheck database is sized for, the false positive rate growing past it. 0 disables the filter.")
+	getCmd.PersistentFlags().Float64("seencheck-filter-fp-rate", 0.01, "False positive rate of the local seencheck filter at its capacity.")
+	getCmd.PersistentFlags().StringSlice("seencheck-from", []string{}, "Jobs whose local seencheck databases are read, but never written, to skip the URIs they already captured. They must use the same --seencheck-hash and canonicalization as this job.")
+	getCmd.PersistentFlags().Bool("canonicalize", false, "Deduplicate the URIs in the local seencheck and queue on their canonical SURT form, stripping the user info, session IDs and tracking parameters. The original URIs are still the ones fetched. The canonicalization can't be changed once a job started.")
+	getCmd.PersistentFlags().StringSlice("canonicalize-strip-params", []string{}, "Query parameters stripped from every URI when canonicalizing, a name ending with * matching all the names starting with it. Implies --canonicalize.")
+	getCmd.PersistentFlags().String("canonicalize-rules", "", "JSON file of per-host parameters stripped when canonicalizing (host, domain or URL regex: strip_params). The first matching rule wins. Implies --canonicalize.")
+	getCmd.PersistentFlags().Bool("api", false, "Enable the API, exposing pause/resume/stop controls, stats and the seeds being processed.")
 	getCmd.PersistentFlags().Int("api-port", 9090, "Port to listen on for the API.")
 	getCmd.PersistentFlags().Int("max-redirect", 20, "Specifies the maximum number of redirections to follow for a resource.")
 	getCmd.PersistentFlags().Int("max-retry", 5, "Number of retry if error happen when executing HTTP request.")
+	getCmd.PersistentFlags().StringSlice("retry-status-codes", []string{"5xx", "403", "408", "425", "429"}, "HTTP status codes that are retried, whole classes can be given like 5xx.")
+	getCmd.PersistentFlags().StringSlice("retry-network-errors", []string{"all"}, "Classes of network errors that are retried: all, timeout, dns, connection-refused (including unreachable hosts), connection-reset, tls.")
+	getCmd.PersistentFlags().String("retry-backoff", "linear", "Backoff between retries: constant (base delay), linear (retry number * base delay) or exponential (base delay * 2^retry number).")
+	getCmd.PersistentFlags().Duration("retry-base-delay", 2*time.Second, "Base delay of the retry backoff.")
+	getCmd.PersistentFlags().Duration("retry-max-delay", time.Minute, "Maximum delay between two retries, 0 means no limit. Requests for which the server asks to wait longer (Retry-After) are not retried.")
+	getCmd.PersistentFlags().Float64("retry-jitter", 0.2, "Randomly vary the retry delays by up to this fraction (0 to 1).")
+	getCmd.PersistentFlags().Duration("retry-max-time", 0, "Maximum total time spent retrying a request, 0 means no limit.")
 	getCmd.PersistentFlags().Int("http-timeout", -1, "Number of seconds to wait before timing out a request. Note: this will CANCEL large files download.")
 	getCmd.PersistentFlags().Int("http-read-deadline", 60, "Number of seconds to wait before timing out a (blocking) read.")
 	getCmd.PersistentFlags().StringSlice("domains-crawl", []string{}, "Naive domains, full URLs or regexp to match against any URL to determine hop behaviour for outlinks. If an outlink URL is matched it will be queued to crawl with a hop of 0. This flag helps crawling entire domains while doing non-focused crawls.")
@@ -46,24 +67,43 @@ func getCMDsFlags(getCmd *cobra.Command) {
 	getCmd.PersistentFlags().StringSlice("exclude-host", []string{}, "Exclude a specific host from the crawl, note that it will not exclude the domain if it is encountered as an asset for another web page.")
 	getCmd.PersistentFlags().StringSlice("include-host", []string{}, "Only crawl specific hosts, note that it will not include the domain if it is encountered as an asset for another web page.")
 	getCmd.PersistentFlags().StringSlice("include-string", []string{}, "Only crawl URLs containing this string.")
-	getCmd.PersistentFlags().Int("crawl-time-limit", 0, "Number of seconds until the crawl will automatically set itself into the finished state.")
-	getCmd.PersistentFlags().Int("crawl-max-time-limit", 0, "Number of seconds until the crawl will automatically panic itself. Default to crawl-time-limit + (crawl-time-limit / 10)")
+	getCmd.PersistentFlags().Int("crawl-time-limit", 0, "Number of seconds until the crawl stops pulling new seeds, finishes the ones in flight and stops.")
+	getCmd.PersistentFlags().Int("crawl-max-time-limit", 0, "Number of seconds until the crawl resets its claimed seeds and exits with a non-zero code. Default to crawl-time-limit + (crawl-time-limit / 10)")
+	getCmd.PersistentFlags().Duration("lq-lease", 10*time.Minute, "How long a seed claimed from the local queue stays claimed without its lease being renewed. Leases of the seeds in flight are renewed periodically, expired seeds (e.g. claimed by a crawl that was killed) are given back to the queue. 0 disables leases.")
 	getCmd.PersistentFlags().StringSlice("exclude-string", []string{}, "Discard any (discovered) URLs containing this string.")
 	getCmd.PersistentFlags().StringSlice("exclusion-file", []string{}, "File containing regex to apply on URLs for exclusion. If the path start with http or https, it will be treated as a URL of a file to download.")
 	getCmd.PersistentFlags().Float64("min-space-required", 0, "Minimum space required in GB to continue the crawl. Default will be 50GB * (total disk space / 256GB) if total disk space is less than 256GB, else 50GB.")
 
 	// Network flags
-	getCmd.PersistentFlags().String("proxy", "", "Proxy to use when requesting pages.")
+	getCmd.PersistentFlags().StringSlice("proxy", []string{}, "Proxy to use when requesting pages (http:// or socks5://). Can be repeated to use a pool of proxies. Each proxy writes its own WARC files.")
+	getCmd.PersistentFlags().String("proxy-selection", "round-robin", "How a proxy of a pool is picked: round-robin or sticky (always the same proxy for a given host, as long as it isn't ejected).")
+	getCmd.PersistentFlags().String("proxy-rules", "", "JSON file of proxy pools and per-host routing rules (host, domain or regex: pool, \"direct\" for no proxy). The first matching rule wins, other hosts go through the --proxy pool if any, directly otherwise.")
+	getCmd.PersistentFlags().Int("proxy-max-failures", 5, "Number of consecutive connection failures after which a proxy is ejected from its pools. 0 disables ejection.")
+	getCmd.PersistentFlags().Duration("proxy-eject-duration", 5*time.Minute, "How long an ejected proxy is kept out of its pools.")
 	getCmd.PersistentFlags().Bool("random-local-ip", false, "Use random local IP for requests. (will be ignored if a proxy is set)")
 	getCmd.PersistentFlags().Bool("disable-ipv4", false, "Disable IPv4 for requests.")
 	getCmd.PersistentFlags().Bool("disable-ipv6", false, "Disable IPv6 for requests.")
 	getCmd.PersistentFlags().Bool("ipv6-anyip", false, "Use AnyIP kernel feature for requests. (only IPv6, need --random-local-ip)")
 
+	// DNS flags
+	getCmd.PersistentFlags().StringSlice("dns-servers", []string{}, "DNS servers (IP addresses) to use to resolve the hosts. Default to the servers of /etc/resolv.conf.")
+	getCmd.PersistentFlags().Duration("dns-timeout", 5*time.Second, "Timeout of a DNS resolution.")
+	getCmd.PersistentFlags().Duration("dns-cache-ttl", 5*time.Minute, "Max time a DNS resolution is cached, resolutions are cached for the TTL of their records up to this. Each resolution is written as a dns: record in the WARC files.")
+	getCmd.PersistentFlags().Int("dns-cache-size", 10000, "Max number of hosts in the DNS cache.")
+	getCmd.PersistentFlags().Duration("dns-negative-cache-ttl", 10*time.Minute, "How long a host that doesn't resolve (e.g. NXDOMAIN) is cached, its URLs fail without being requested or retried until then. 0 disables the negative cache.")
+	getCmd.PersistentFlags().String("dns-pin-file", "", "File pinning hosts to IPs, in the format of /etc/hosts (an IP followed by its hosts on each line). The pinned hosts are connected to without resolving them.")
+
+	// Robots.txt flags
+	getCmd.PersistentFlags().String("robots", "ignore", "How to handle robots.txt files: obey, ignore or obey-outlinks (robots.txt rules apply to seeds and outlinks, page assets are always fetched).")
+	getCmd.PersistentFlags().Bool("robots-sitemaps", false, "Enqueue the sitemaps listed in robots.txt files as outlinks. (requires --robots obey or obey-outlinks)")
+	getCmd.PersistentFlags().Int("robots-max-crawl-delay", 30, "Maximum Crawl-delay in seconds accepted from robots.txt files, longer delays are capped to this value. Crawl-delay is enforced by the rate limiter.")
+
 	// Rate limiting flags
 	getCmd.PersistentFlags().Bool("disable-rate-limit", false, "Disable the Token Bucket rate limiting.")
 	getCmd.PersistentFlags().Float64("rate-limit-capacity", 150, "Bucket capacity for each host.")
 	getCmd.PersistentFlags().Float64("rate-limit-refill-rate", 50, "Ideal requests per second for each host.")
 	getCmd.PersistentFlags().Duration("rate-limit-cleanup-frequency", time.Duration(5*time.Minute), "How often to run cleanup of stale buckets that are not accessed in the duration.")
+	getCmd.PersistentFlags().String("rate-limit-rules", "", "JSON file of per-host rate limit rules (host, domain or regex: capacity, refill_rate, max_concurrency, min_delay), reloaded when modified. The first matching rule wins.")
 
 	// WARC flags
 	getCmd.PersistentFlags().String("warc-prefix", "ZENO", "Prefix to use when naming the WARC files.")
@@ -80,8 +120,25 @@ func getCMDsFlags(getCmd *cobra.Command) {
 	getCmd.PersistentFlags().String("warc-cdx-cookie", "", "Pass custom cookie during CDX requests. Example: 'cdx_auth_token=test_value'")
 	getCmd.PersistentFlags().Int("warc-size", 1024, "Size of the WARC files in MB.")
 	getCmd.PersistentFlags().IntSlice("warc-discard-status", []int{429}, "HTTP status codes to discard from WARC files. By default, 429 is always discarded.")
+	getCmd.PersistentFlags().Bool("warc-metadata-records", false, "Write a WARC metadata record, concurrent to the response record, for each archived URL that outlinks or assets were discovered in, listing them with its hop path and via (Heritrix style).")
+	getCmd.PersistentFlags().Bool("warc-cdxj", false, "Write a sorted CDXJ index next to each WARC file once it is closed, for pywb-style replay.")
+	getCmd.PersistentFlags().Bool("warc-cdxj-merge", false, "When stopping, merge the CDXJ indexes of the job into jobs/<job>/index.cdxj. Implies --warc-cdxj.")
+	getCmd.PersistentFlags().Bool("warc-dedupe-index", false, "Persist the local deduplication in jobs/<job>/dedupe, so that the payloads captured in the previous sessions of the job are written as revisit records. Implies --warc-cdxj.")
+	getCmd.PersistentFlags().StringSlice("warc-dedupe-index-seed", []string{}, "CDX or CDXJ files (optionally gzipped) of earlier jobs to seed the dedupe index with, each imported once. Implies --warc-dedupe-index.")
 	getCmd.PersistentFlags().Bool("async-warc-write", false, "Write WARC records asynchronously. EXPERIMENTAL - may cause OOMs, lost data, or other unknown/unpredicted issues. No support will be provided for this feature.")
 
+	// S3 upload flags
+	getCmd.PersistentFlags().String("s3-endpoint", "", "S3-compatible endpoint (e.g. https://s3.us-east-1.amazonaws.com) to upload the closed WARC files to. Uploaded files are verified, recorded in jobs/<job>/uploads.jsonl and deleted locally.")
+	getCmd.PersistentFlags().String("s3-region", "us-east-1", "Region of the S3 bucket.")
+	getCmd.PersistentFlags().String("s3-bucket", "", "Bucket to upload the WARC files to.")
+	getCmd.PersistentFlags().String("s3-prefix", "", "Prefix of the keys of the uploaded WARC files, e.g. crawls/my-job/.")
+	getCmd.PersistentFlags().String("s3-access-key", "", "Access key of the S3 endpoint.")
+	getCmd.PersistentFlags().String("s3-secret-key", "", "Secret key of the S3 endpoint (can be set with ZENO_S3_SECRET_KEY).")
+	getCmd.PersistentFlags().Int("s3-part-size", 64, "Size of the multipart upload parts in MB, at least 5.")
+	getCmd.PersistentFlags().Int("s3-concurrency", 2, "Number of WARC files uploaded in parallel.")
+	getCmd.PersistentFlags().Int("s3-max-retries", 5, "Number of retries of a failing S3 request, with exponential backoff.")
+	getCmd.PersistentFlags().Float64("s3-rate-limit", 0, "Maximum upload bandwidth in MB/s shared by all the uploads, 0 means unlimited.")
+
 	// Logging flags
 	getCmd.PersistentFlags().Bool("tui", false, "Display a terminal user interface.")
 	getCmd.PersistentFlags().String("tui-log-level", "info", "Log level for the TUI.")
@@ -90,6 +147,8 @@ func getCMDsFlags(getCmd *cobra.Command) {
 	getCmd.PersistentFlags().String("log-file-prefix", "ZENO", "Prefix to use when naming the log files. Default is : `ZENO`, without '-'")
 	getCmd.PersistentFlags().String("log-file-level", "info", "Log level for the log file.")
 	getCmd.PersistentFlags().String("log-file-rotation", "1h", "Log file rotation period. Default is : `1h`. Valid time units are 'ns', 'us' (or 'µs'), 'ms', 's', 'm', 'h'.")
+	getCmd.PersistentFlags().Bool("no-crawl-log", false, "Disable the crawl log, the Heritrix-style log of every fetch written to crawl.log in the logs directory.")
+	getCmd.PersistentFlags().Duration("crawl-log-rotation", 24*time.Hour, "Crawl log rotation period, 0 disables the rotation.")
 
 	// Profiling flags
 	getCmd.PersistentFlags().String("pyroscope-address", "", "Pyroscope server address. Setting this flag will enable profiling.")
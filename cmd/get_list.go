@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/internal/pkg/controler"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq"
+	"github.com/spf13/cobra"
+)
+
+var getListCmd = &cobra.Command{
+	Use:   "list <file|->",
+	Short: "Archive the seeds listed in a file (or stdin with -)",
+	Long: `Archive the seeds listed in a file (or stdin with -).
+The seeds are streamed in the local queue of the job before the crawl starts, seeds already in the queue are ignored.
+The file can be gzipped, each line is either a URL, a tab-separated URL, hops, via and priority, or a JSON object with url, hops, via
+and priority fields. The seeds with the highest priority, then the lowest hops, are crawled first, round-robin across hosts.
+Empty lines and lines starting with # are ignored, invalid URLs are skipped.`,
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(_ *cobra.Command, _ []string) error {
+		if cfg == nil {
+			return fmt.Errorf("viper config is nil")
+		}
+
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := config.GenerateCrawlConfig()
+		if err != nil {
+			return err
+		}
+
+		// The seeds get the keys of the URLs discovered by the crawl in the LQ
+		if config.Get().Canonicalize && !config.Get().UseHQ {
+			if err := startCanonicalizer(); err != nil {
+				return err
+			}
+		}
+
+		if err := loadSeedsList(args[0]); err != nil {
+			return err
+		}
+
+		controler.Start()
+		controler.WatchSignals()
+		return nil
+	},
+}
+
+// loadSeedsList streams the seeds file into the LQ of the job
+func loadSeedsList(filePath string) error {
+	input, err := openInput(filePath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	if err := os.MkdirAll(config.Get().JobPath, 0755); err != nil {
+		return fmt.Errorf("can't create job directory: %w", err)
+	}
+
+	client, err := lq.Open(path.Join(config.Get().JobPath, "lq.db"))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	before, err := queueSize(client)
+	if err != nil {
+		return err
+	}
+
+	read, skipped, err := importSeeds(client, input, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("error loading seeds: %w", err)
+	}
+
+	after, err := queueSize(client)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d seeds read, %d added to the queue of job %s (the others were already in it), %d line(s) skipped\n", read, after-before, config.Get().Job, skipped)
+
+	return nil
+}
@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
+	"github.com/spf13/cobra"
+)
+
+func lqCMDs() *cobra.Command {
+	lqCmd := &cobra.Command{
+		Use:   "lq",
+		Short: "Inspect and manage the local queue of a job.",
+		Long: `Inspect and manage the local queue (lq.db) of a job.
+Commands that modify the queue should not be used while a crawl is running on the same job.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+			}
+		},
+	}
+
+	lqCmd.PersistentFlags().String("job", "", "Job name of the local queue to use.")
+
+	lqListCmd.Flags().String("status", "", "Only list the URLs with this status (FRESH, CLAIMED or DONE).")
+	lqListCmd.Flags().Int("limit", 100, "Maximum number of URLs to list, 0 means no limit.")
+
+	lqAddCmd.Flags().Int("hops", 0, "Hops of the added URLs.")
+	lqAddCmd.Flags().String("via", "", "Via of the added URLs.")
+	lqAddCmd.Flags().Int64("priority", 0, "Priority of the added URLs, the URLs with the highest priority are claimed first.")
+
+	for _, cmd := range []*cobra.Command{lqAddCmd, lqImportCmd} {
+		cmd.Flags().Bool("canonicalize", false, "Deduplicate the URLs on their canonical form, for jobs crawled with --canonicalize.")
+		cmd.Flags().StringSlice("canonicalize-strip-params", []string{}, "Query parameters stripped when canonicalizing, as in the crawls. Implies --canonicalize.")
+		cmd.Flags().String("canonicalize-rules", "", "JSON file of per-host parameters stripped when canonicalizing, as in the crawls. Implies --canonicalize.")
+	}
+
+	lqExportCmd.Flags().String("status", "DONE", "Only export the URLs with this status (FRESH, CLAIMED or DONE), empty exports everything.")
+	lqExportCmd.Flags().String("format", "url", "Export format: url (one URL per line) or tsv (URL, hops, via and priority, tab-separated, can be imported back).")
+	lqExportCmd.Flags().StringP("output", "o", "-", "File to export to, - for stdout.")
+
+	lqCmd.AddCommand(lqStatsCmd)
+	lqCmd.AddCommand(lqListCmd)
+	lqCmd.AddCommand(lqAddCmd)
+	lqCmd.AddCommand(lqImportCmd)
+	lqCmd.AddCommand(lqExportCmd)
+	lqCmd.AddCommand(lqResetClaimedCmd)
+	lqCmd.AddCommand(lqPurgeDoneCmd)
+
+	return lqCmd
+}
+
+var lqStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the number of URLs for each status.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		client, err := openLQReadOnly()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		stats, err := client.Stats(context.Background())
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		for _, status := range []string{"FRESH", "CLAIMED", "DONE"} {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-8s %d\n", status, stats[status])
+			total += stats[status]
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%-8s %d\n", "TOTAL", total)
+
+		return nil
+	},
+}
+
+var lqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the URLs of the queue (id, status, hops, priority, URL, via).",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		status, _ := cmd.Flags().GetString("status")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		status, err := validateLQStatus(status)
+		if err != nil {
+			return err
+		}
+
+		client, err := openLQReadOnly()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		listed := 0
+		return iterateLQ(client, status, func(URL sqlc_model.Url) bool {
+			if limit > 0 && listed >= limit {
+				return false
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%d\t%d\t%s\t%s\n", URL.ID, URL.Status, URL.Hops, URL.Priority, URL.Value, URL.Via)
+			listed++
+			return true
+		})
+	},
+}
+
+var lqAddCmd = &cobra.Command{
+	Use:   "add URL [URL...]",
+	Short: "Add URLs to the queue.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hops, _ := cmd.Flags().GetInt("hops")
+		via, _ := cmd.Flags().GetString("via")
+		priority, _ := cmd.Flags().GetInt64("priority")
+
+		URLs := make([]sqlc_model.Url, 0, len(args))
+		for _, arg := range args {
+			if err := validateURL(arg); err != nil {
+				return err
+			}
+			URLs = append(URLs, sqlc_model.Url{Value: arg, Hops: int64(hops), Via: via, Priority: priority})
+		}
+
+		if canonicalizeRequested() {
+			if err := startCanonicalizer(); err != nil {
+				return err
+			}
+			defer canonicalizer.Stop()
+		}
+
+		client, err := openLQ(true)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		before, err := queueSize(client)
+		if err != nil {
+			return err
+		}
+
+		if err := client.Add(context.Background(), URLs, false); err != nil {
+			return err
+		}
+
+		after, err := queueSize(client)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d URL(s) added (the others were already in the queue)\n", after-before)
+
+		return nil
+	},
+}
+
+var lqImportCmd = &cobra.Command{
+	Use:   "import <file|->",
+	Short: "Import URLs from a file (or stdin with -) into the queue.",
+	Long: `Import URLs from a file (or stdin with -) into the queue.
+The file can be gzipped, each line is either a URL, a tab-separated URL, hops, via and priority (as produced by "lq export --format tsv"),
+or a JSON object with url, hops, via and priority fields. Empty lines and lines starting with # are ignored, invalid URLs are skipped.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if canonicalizeRequested() {
+			if err := startCanonicalizer(); err != nil {
+				return err
+			}
+			defer canonicalizer.Stop()
+		}
+
+		input, err := openInput(args[0])
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+
+		client, err := openLQ(true)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		before, err := queueSize(client)
+		if err != nil {
+			return err
+		}
+
+		read, skipped, err := importSeeds(client, input, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+
+		after, err := queueSize(client)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d URL(s) read, %d added to the queue (the others were already in it), %d line(s) skipped\n", read, after-before, skipped)
+
+		return nil
+	},
+}
+
+var lqExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the URLs of the queue.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		status, _ := cmd.Flags().GetString("status")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		if format != "url" && format != "tsv" {
+			return fmt.Errorf("invalid format %q, must be url or tsv", format)
+		}
+
+		status, err := validateLQStatus(status)
+		if err != nil {
+			return err
+		}
+
+		client, err := openLQReadOnly()
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		var out io.Writer = cmd.OutOrStdout()
+		if output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		writer := bufio.NewWriter(out)
+
+		var writeErr error
+		err = iterateLQ(client, status, func(URL sqlc_model.Url) bool {
+			if format == "tsv" {
+				_, writeErr = fmt.Fprintf(writer, "%s\t%d\t%s\t%d\n", URL.Value, URL.Hops, URL.Via, URL.Priority)
+			} else {
+				_, writeErr = fmt.Fprintln(writer, URL.Value)
+			}
+			return writeErr == nil
+		})
+		if err != nil {
+			return err
+		}
+		if writeErr != nil {
+			return writeErr
+		}
+
+		return writer.Flush()
+	},
+}
+
+var lqResetClaimedCmd = &cobra.Command{
+	Use:   "reset-claimed",
+	Short: "Set the CLAIMED URLs back to FRESH, e.g. after a crash.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		client, err := openLQ(false)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		reset, err := client.ResetClaimed(context.Background())
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d URL(s) reset\n", reset)
+
+		return nil
+	},
+}
+
+var lqPurgeDoneCmd = &cobra.Command{
+	Use:   "purge-done",
+	Short: "Delete the DONE URLs from the queue.",
+	Long: `Delete the DONE URLs from the queue.
+Note that the queue won't ignore these URLs anymore if they are added again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		client, err := openLQ(false)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		purged, err := client.PurgeDone(context.Background())
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d URL(s) deleted\n", purged)
+
+		return nil
+	},
+}
+
+// openLQ opens the local queue of the job given with --job, create allows creating it if it doesn't exist
+func openLQ(create bool) (*lq.LQClient, error) {
+	dbPath, err := lqPath(create)
+	if err != nil {
+		return nil, err
+	}
+
+	return lq.Open(dbPath)
+}
+
+// openLQReadOnly opens the existing local queue of the job given with --job read-only, without migrating it,
+// so that it can be inspected while a crawl is running on the job
+func openLQReadOnly() (*lq.LQClient, error) {
+	dbPath, err := lqPath(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return lq.OpenReadOnly(dbPath)
+}
+
+// lqPath returns the path of the local queue of the job given with --job, create allows creating its
+// directory if the queue doesn't exist
+func lqPath(create bool) (string, error) {
+	if cfg == nil {
+		return "", fmt.Errorf("viper config is nil")
+	}
+
+	if cfg.Job == "" {
+		return "", fmt.Errorf("--job is required")
+	}
+
+	jobPath := path.Join("jobs", cfg.Job)
+	dbPath := path.Join(jobPath, "lq.db")
+
+	if create {
+		if err := os.MkdirAll(jobPath, 0755); err != nil {
+			return "", fmt.Errorf("can't create job directory: %w", err)
+		}
+	} else if _, err := os.Stat(dbPath); err != nil {
+		return "", fmt.Errorf("no local queue found for job %s: %w", cfg.Job, err)
+	}
+
+	return dbPath, nil
+}
+
+// iterateLQ calls fn for each URL of the queue with the given status (all if empty), until fn returns false
+func iterateLQ(client *lq.LQClient, status string, fn func(sqlc_model.Url) bool) error {
+	var afterID string
+
+	for {
+		URLs, err := client.List(context.Background(), status, afterID, 1000)
+		if err != nil {
+			return err
+		}
+
+		if len(URLs) == 0 {
+			return nil
+		}
+
+		for _, URL := range URLs {
+			if !fn(URL) {
+				return nil
+			}
+		}
+
+		afterID = URLs[len(URLs)-1].ID
+	}
+}
+
+func validateLQStatus(status string) (string, error) {
+	status = strings.ToUpper(status)
+
+	switch status {
+	case "", "FRESH", "CLAIMED", "DONE":
+		return status, nil
+	}
+
+	return "", fmt.Errorf("invalid status %q, must be FRESH, CLAIMED or DONE", status)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/dustin/go-humanize"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
+	"github.com/internetarchive/Zeno/internal/pkg/utils"
+	"github.com/internetarchive/Zeno/internal/pkg/wacz"
+	"github.com/spf13/cobra"
+)
+
+func packageCMDs() *cobra.Command {
+	packageCmd := &cobra.Command{
+		Use:   "package",
+		Short: "Package the output of a finished job.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+			}
+		},
+	}
+
+	packageCmd.PersistentFlags().String("job", "", "Job name to package.")
+
+	packageWACZCmd.Flags().StringP("output", "o", "", "Path of the WACZ file, default is jobs/<job>/<job>.wacz.")
+	packageWACZCmd.Flags().String("title", "", "Title of the WACZ, default is the job name.")
+	packageWACZCmd.Flags().Bool("detect-pages", false, "List all the HTML pages captured as pages, instead of the seeds of the local queue.")
+	packageWACZCmd.Flags().String("signing-key", "", "PEM-encoded ECDSA private key to sign the datapackage digest with.")
+
+	packageCmd.AddCommand(packageWACZCmd)
+
+	return packageCmd
+}
+
+var packageWACZCmd = &cobra.Command{
+	Use:   "wacz",
+	Short: "Package the WARC files of a job into a WACZ file.",
+	Long: `Package the closed WARC files of a job into a WACZ file, with a CDXJ index, a pages.jsonl listing the seeds
+of the local queue that were captured (or all the HTML pages captured if there are none), and a datapackage.json with the digests of all the files.
+This should not be used while a crawl is running on the same job.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		title, _ := cmd.Flags().GetString("title")
+		signingKey, _ := cmd.Flags().GetString("signing-key")
+		detectPages, _ := cmd.Flags().GetBool("detect-pages")
+
+		if cfg == nil {
+			return fmt.Errorf("viper config is nil")
+		}
+
+		if cfg.Job == "" {
+			return fmt.Errorf("--job is required")
+		}
+
+		jobPath := path.Join("jobs", cfg.Job)
+		if _, err := os.Stat(jobPath); err != nil {
+			return fmt.Errorf("no job found: %w", err)
+		}
+
+		if output == "" {
+			output = path.Join(jobPath, cfg.Job+".wacz")
+		}
+
+		if title == "" {
+			title = cfg.Job
+		}
+
+		opts := wacz.Options{
+			Title:    title,
+			Software: "Zeno/" + utils.GetVersion().Version,
+		}
+
+		if signingKey != "" {
+			var err error
+			opts.SigningKey, err = wacz.LoadSigningKey(signingKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The finished URLs are removed from the local queue, so the seeds may not be there anymore
+		if detectPages {
+			opts.DetectPages = true
+		} else {
+			seeds, err := jobSeeds(jobPath)
+			if err != nil {
+				return err
+			}
+			if len(seeds) == 0 {
+				fmt.Fprintln(cmd.ErrOrStderr(), "no seeds found in the local queue, listing all the HTML pages captured instead")
+				opts.DetectPages = true
+			}
+			opts.Seeds = seeds
+		}
+
+		summary, err := wacz.Create(jobPath, output, opts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s written (%s): %d WARC file(s), %d page(s)\n", output, humanize.Bytes(uint64(summary.Bytes)), summary.WARCs, summary.Pages)
+
+		return nil
+	},
+}
+
+// jobSeeds returns the seeds (URLs with 0 hops) of the local queue of the job, none if it has no local queue.
+// The queue is opened read-only, without migrating it.
+func jobSeeds(jobPath string) (seeds []wacz.Seed, err error) {
+	dbPath := path.Join(jobPath, "lq.db")
+	if _, err := os.Stat(dbPath); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	client, err := lq.OpenReadOnly(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't open the local queue: %w", err)
+	}
+	defer client.Close()
+
+	err = iterateLQ(client, "", func(URL sqlc_model.Url) bool {
+		if URL.Hops == 0 {
+			seeds = append(seeds, wacz.Seed{ID: URL.ID, URL: URL.Value})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read the seeds of the local queue: %w", err)
+	}
+
+	return seeds, nil
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+const (
+	// seedsBatchSize is the number of seeds inserted in the LQ per transaction
+	seedsBatchSize = 10000
+	// seedsProgressInterval is the number of lines read between two progress reports
+	seedsProgressInterval = 100000
+)
+
+// jsonSeed is a line of a JSONL seeds file
+type jsonSeed struct {
+	URL      string `json:"url"`
+	Hops     int64  `json:"hops"`
+	Via      string `json:"via"`
+	Priority int64  `json:"priority"`
+}
+
+// importSeeds streams the seeds read from r into the LQ in batches, invalid lines are reported to w and skipped.
+// Seeds already in the queue are ignored by the LQ (unique index on the URL).
+func importSeeds(client *lq.LQClient, r io.Reader, w io.Writer) (read, skipped int, err error) {
+	batch := make([]sqlc_model.Url, 0, seedsBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := client.Add(context.Background(), batch, false); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err = readLines(r, func(lineNumber int, line string) error {
+		URL, err := parseSeedLine(line)
+		if err != nil {
+			fmt.Fprintf(w, "skipping line %d: %s\n", lineNumber, err)
+			skipped++
+			return nil
+		}
+
+		read++
+		if read%seedsProgressInterval == 0 {
+			fmt.Fprintf(w, "%d seeds read (line %d)\n", read, lineNumber)
+		}
+
+		batch = append(batch, URL)
+		if len(batch) >= seedsBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return read, skipped, err
+	}
+
+	return read, skipped, flush()
+}
+
+// queueSize returns the total number of URLs in the LQ
+func queueSize(client *lq.LQClient) (int64, error) {
+	stats, err := client.Stats(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, count := range stats {
+		total += count
+	}
+
+	return total, nil
+}
+
+// parseSeedLine parses a line of a seeds file, that is either:
+//   - a URL
+//   - a URL followed by tab-separated hops, via and priority
+//   - a JSON object with url, hops, via and priority fields
+func parseSeedLine(line string) (URL sqlc_model.Url, err error) {
+	line = strings.TrimSpace(line)
+
+	if strings.HasPrefix(line, "{") {
+		var seed jsonSeed
+		if err := json.Unmarshal([]byte(line), &seed); err != nil {
+			return URL, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		if seed.Hops < 0 {
+			return URL, fmt.Errorf("invalid hops %d", seed.Hops)
+		}
+
+		URL = sqlc_model.Url{Value: strings.TrimSpace(seed.URL), Hops: seed.Hops, Via: seed.Via, Priority: seed.Priority}
+
+		return URL, validateURL(URL.Value)
+	}
+
+	fields := strings.Split(line, "\t")
+
+	URL.Value = strings.TrimSpace(fields[0])
+	if err := validateURL(URL.Value); err != nil {
+		return URL, err
+	}
+
+	if len(fields) > 1 && strings.TrimSpace(fields[1]) != "" {
+		URL.Hops, err = strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil || URL.Hops < 0 {
+			return URL, fmt.Errorf("invalid hops %q", fields[1])
+		}
+	}
+
+	if len(fields) > 2 {
+		URL.Via = strings.TrimSpace(fields[2])
+	}
+
+	if len(fields) > 3 && strings.TrimSpace(fields[3]) != "" {
+		URL.Priority, err = strconv.ParseInt(strings.TrimSpace(fields[3]), 10, 64)
+		if err != nil {
+			return URL, fmt.Errorf("invalid priority %q", fields[3])
+		}
+	}
+
+	return URL, nil
+}
+
+func validateURL(raw string) error {
+	URL := &models.URL{Raw: raw}
+	if err := URL.Parse(); err != nil {
+		return fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+
+	return nil
+}
+
+// gzipReadCloser closes both the gzip reader and the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// openInput opens the given file, or stdin if the path is -.
+// Gzipped inputs are detected and transparently decompressed.
+func openInput(filePath string) (io.ReadCloser, error) {
+	var file io.ReadCloser = io.NopCloser(os.Stdin)
+
+	if filePath != "-" {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	}
+
+	buffered := bufio.NewReader(file)
+
+	magic, err := buffered.Peek(2)
+	if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid gzip input: %w", err)
+		}
+
+		return &gzipReadCloser{Reader: gzipReader, file: file}, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{buffered, file}, nil
+}
+
+// readLines calls fn for each non-empty line of r that isn't a comment (starting with #)
+func readLines(r io.Reader, fn func(lineNumber int, line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		if err := fn(lineNumber, line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/seencheck"
+	"github.com/spf13/cobra"
+)
+
+func seencheckCMDs() *cobra.Command {
+	seencheckCmd := &cobra.Command{
+		Use:   "seencheck",
+		Short: "Export, import and merge the local seencheck of a job.",
+		Long: `Export, import and merge the local seencheck database of a job.
+The seencheck of a job can also be read by another one with "get --seencheck-from", to skip the URLs it already captured.
+Commands that modify the seencheck should not be used while a crawl is running on the same job.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				cmd.Help()
+			}
+		},
+	}
+
+	seencheckCmd.PersistentFlags().String("job", "", "Job name of the seencheck to use.")
+	seencheckCmd.PersistentFlags().String("hash", "", "Hash function the URLs are keyed with (fnv64 or sha256) when creating the seencheck, the existing one is used by default.")
+
+	seencheckExportCmd.Flags().StringP("output", "o", "-", "File to export to, - for stdout.")
+
+	seencheckImportCmd.Flags().String("format", "seencheck", "Import format: seencheck (as produced by \"seencheck export\"), urls (one URL per line) or cdx (CDX or CDXJ index of the captures).")
+	seencheckImportCmd.Flags().Bool("canonicalize", false, "Key the URLs on their canonical form, for jobs crawled with --canonicalize.")
+	seencheckImportCmd.Flags().StringSlice("canonicalize-strip-params", []string{}, "Query parameters stripped when canonicalizing, as in the crawls. Implies --canonicalize.")
+	seencheckImportCmd.Flags().String("canonicalize-rules", "", "JSON file of per-host parameters stripped when canonicalizing, as in the crawls. Implies --canonicalize.")
+
+	seencheckCmd.AddCommand(seencheckStatsCmd)
+	seencheckCmd.AddCommand(seencheckExportCmd)
+	seencheckCmd.AddCommand(seencheckImportCmd)
+	seencheckCmd.AddCommand(seencheckMergeCmd)
+
+	return seencheckCmd
+}
+
+var seencheckStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the hash function, the canonicalization and the number of URLs of the seencheck.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		db, err := openSeencheck(cmd, false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		count, err := db.Count()
+		if err != nil {
+			return err
+		}
+
+		canonicalization := db.Canonicalization()
+		if canonicalization == "" {
+			canonicalization = "-"
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%-16s %s\n", "HASH", db.Hash())
+		fmt.Fprintf(cmd.OutOrStdout(), "%-16s %s\n", "CANONICALIZATION", canonicalization)
+		fmt.Fprintf(cmd.OutOrStdout(), "%-16s %d\n", "URLS", count)
+
+		return nil
+	},
+}
+
+var seencheckExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the seencheck, to import it into another job.",
+	Long: `Export the seencheck, to import it into another job.
+The seencheck only holds hashes of the URLs, the export can't be turned back into a list of URLs.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		output, _ := cmd.Flags().GetString("output")
+
+		db, err := openSeencheck(cmd, false)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		var out io.Writer = cmd.OutOrStdout()
+		if output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		exported, err := db.Export(out)
+		if err != nil {
+			return err
+		}
+
+		if output != "-" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%d URL(s) exported\n", exported)
+		}
+
+		return nil
+	},
+}
+
+var seencheckImportCmd = &cobra.Command{
+	Use:   "import <file|->",
+	Short: "Import URLs from a file (or stdin with -) into the seencheck.",
+	Long: `Import URLs from a file (or stdin with -) into the seencheck, so that the crawls of the job skip them.
+The file can be gzipped and is either a seencheck export, a list of URLs (one per line, considered captured now)
+or a CDX or CDXJ index of captures already in the archive (considered captured at the date of the capture).
+URLs already in the seencheck keep the latest capture date.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+
+		if format != "seencheck" && format != "urls" && format != "cdx" {
+			return fmt.Errorf("invalid format %q, must be seencheck, urls or cdx", format)
+		}
+
+		if format != "seencheck" && canonicalizeRequested() {
+			if err := startCanonicalizer(); err != nil {
+				return err
+			}
+			defer canonicalizer.Stop()
+		}
+
+		input, err := openInput(args[0])
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+
+		db, err := openSeencheck(cmd, true)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		var read int
+		switch format {
+		case "seencheck":
+			read, err = db.Import(input)
+		case "urls":
+			read, err = db.ImportURLs(input, time.Now())
+		case "cdx":
+			read, err = db.ImportCDX(input)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%d URL(s) imported\n", read)
+
+		return nil
+	},
+}
+
+var seencheckMergeCmd = &cobra.Command{
+	Use:   "merge <source-job> [source-job...]",
+	Short: "Merge the seencheck of other jobs into the seencheck of the job.",
+	Long: `Merge the seencheck of other jobs into the seencheck of the job.
+The jobs must use the same hash function and canonicalization.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := openSeencheck(cmd, true)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		for _, job := range args {
+			if job == cfg.Job {
+				return fmt.Errorf("can't merge the seencheck of job %s into itself", job)
+			}
+
+			source, err := seencheck.OpenDB(path.Join("jobs", job), db.Hash(), true)
+			if err != nil {
+				return fmt.Errorf("can't open the seencheck of job %s: %w", job, err)
+			}
+
+			read, err := db.Merge(source)
+			source.Close()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "%d URL(s) merged from job %s\n", read, job)
+		}
+
+		return nil
+	},
+}
+
+// openSeencheck opens the seencheck of the job given with --job, create allows creating it if it doesn't exist
+func openSeencheck(cmd *cobra.Command, create bool) (*seencheck.DB, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("viper config is nil")
+	}
+
+	if cfg.Job == "" {
+		return nil, fmt.Errorf("--job is required")
+	}
+
+	hash, _ := cmd.Flags().GetString("hash")
+	jobPath := path.Join("jobs", cfg.Job)
+
+	if create {
+		if err := os.MkdirAll(jobPath, 0755); err != nil {
+			return nil, fmt.Errorf("can't create job directory: %w", err)
+		}
+	} else if _, err := os.Stat(path.Join(jobPath, "seencheck")); err != nil {
+		return nil, fmt.Errorf("no seencheck found for job %s: %w", cfg.Job, err)
+	}
+
+	return seencheck.OpenDB(jobPath, hash, !create)
+}
+
+// canonicalizeRequested returns true if --canonicalize or a flag implying it is given. The implied flag is only
+// resolved by GenerateCrawlConfig, that the commands managing a job outside of a crawl don't call.
+func canonicalizeRequested() bool {
+	return cfg.Canonicalize || len(cfg.CanonicalizeStripParams) > 0 || cfg.CanonicalizeRules != ""
+}
+
+// startCanonicalizer starts the canonicalizer with the --canonicalize flags, so that the URLs written outside of
+// the crawls get the keys of the crawls using the same flags
+func startCanonicalizer() error {
+	settings := canonicalizer.Settings{StripParams: cfg.CanonicalizeStripParams}
+	if cfg.CanonicalizeRules != "" {
+		rules, err := canonicalizer.LoadRules(cfg.CanonicalizeRules)
+		if err != nil {
+			return err
+		}
+		settings.Rules = rules
+	}
+
+	return canonicalizer.Start(settings)
+}
@@ -22,11 +22,13 @@ var (
 )
 
 // Start begins serving HTTP requests in a separate goroutine.
-func Start() error {
+// stopFunc is called (in its own goroutine) when a stop is requested through the API,
+// it is expected to gracefully stop the pipeline and exit.
+func Start(stopFunc func()) error {
 	var done bool
 
 	once.Do(func() {
-		mux := http.NewServeMux()
+		mux := newMux(stopFunc)
 
 		if config.Get().Prometheus {
 			mux.Handle("/metrics", stats.PrometheusHandler())
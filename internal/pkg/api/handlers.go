@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/internetarchive/Zeno/internal/pkg/controler/pause"
+	"github.com/internetarchive/Zeno/internal/pkg/reactor"
+	"github.com/internetarchive/Zeno/internal/pkg/stats"
+)
+
+// pauseRequest is the optional body of a POST /pause request
+type pauseRequest struct {
+	Message string `json:"message"`
+}
+
+// pauseResponse is returned by the pause and resume endpoints
+type pauseResponse struct {
+	Paused  bool   `json:"paused"`
+	Message string `json:"message,omitempty"`
+}
+
+// seedResponse describes a seed currently being processed by the reactor
+type seedResponse struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	Source string `json:"source"`
+	Tree   string `json:"tree"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// newMux returns the router holding the control-plane endpoints
+func newMux(stopFunc func()) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /pause", pauseStatusHandler)
+	mux.HandleFunc("POST /pause", pauseHandler)
+	mux.HandleFunc("POST /resume", resumeHandler)
+	mux.HandleFunc("POST /stop", stopHandler(stopFunc))
+	mux.HandleFunc("GET /stats", statsHandler)
+	mux.HandleFunc("GET /seeds", seedsHandler)
+	mux.HandleFunc("POST /seeds", addSeedsHandler)
+	mux.HandleFunc("GET /ratelimit", rateLimitHandler)
+	mux.HandleFunc("POST /ratelimit/reload", rateLimitReloadHandler)
+	mux.HandleFunc("GET /hosts", hostsHandler)
+	mux.HandleFunc("GET /proxies", proxiesHandler)
+
+	return mux
+}
+
+func pauseStatusHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, pauseResponse{Paused: pause.IsPaused(), Message: pause.GetMessage()})
+}
+
+// pauseHandler pauses the pipeline, the message given in the body (JSON or plain text) is shown in the logs and the TUI
+func pauseHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	message := strings.TrimSpace(string(body))
+	if strings.HasPrefix(message, "{") {
+		var req pauseRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON body: " + err.Error()})
+			return
+		}
+		message = req.Message
+	}
+
+	if message == "" {
+		message = "Paused via API"
+	}
+
+	if pause.IsPaused() {
+		writeJSON(w, http.StatusConflict, pauseResponse{Paused: true, Message: pause.GetMessage()})
+		return
+	}
+
+	pause.Pause(message)
+
+	writeJSON(w, http.StatusOK, pauseResponse{Paused: true, Message: pause.GetMessage()})
+}
+
+func resumeHandler(w http.ResponseWriter, _ *http.Request) {
+	// Resuming a pipeline that isn't paused would block until the next pause
+	if !pause.IsPaused() {
+		writeJSON(w, http.StatusConflict, pauseResponse{Paused: false})
+		return
+	}
+
+	pause.Resume()
+
+	writeJSON(w, http.StatusOK, pauseResponse{Paused: pause.IsPaused(), Message: pause.GetMessage()})
+}
+
+func stopHandler(stopFunc func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if stopFunc == nil {
+			writeJSON(w, http.StatusNotImplemented, errorResponse{Error: "stopping is not available"})
+			return
+		}
+
+		// The stop shuts down this server, so it can't be done before the response is sent
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "stopping"})
+		go stopFunc()
+	}
+}
+
+func statsHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, stats.GetMapTUI())
+}
+
+func seedsHandler(w http.ResponseWriter, _ *http.Request) {
+	items := reactor.GetStateTableItems()
+
+	seeds := make([]seedResponse, 0, len(items))
+	for _, item := range items {
+		seed := seedResponse{
+			ID:     item.GetID(),
+			Status: item.GetStatus().String(),
+			Source: item.GetSource().String(),
+			Tree:   item.DrawTreeWithStatus(),
+		}
+
+		if item.GetURL() != nil {
+			seed.URL = item.GetURL().Raw
+		}
+
+		seeds = append(seeds, seed)
+	}
+
+	writeJSON(w, http.StatusOK, seeds)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/controler/pause"
+	"github.com/internetarchive/Zeno/internal/pkg/stats"
+)
+
+func doRequest(t *testing.T, mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPauseResume(t *testing.T) {
+	stats.Init()
+	mux := newMux(nil)
+
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		expectedStatus  int
+		expectedPaused  bool
+		expectedMessage string
+	}{
+		{"resume while running", http.MethodPost, "/resume", "", http.StatusConflict, false, ""},
+		{"pause with JSON message", http.MethodPost, "/pause", `{"message": "maintenance"}`, http.StatusOK, true, "maintenance"},
+		{"pause while paused", http.MethodPost, "/pause", "other", http.StatusConflict, true, "maintenance"},
+		{"pause status", http.MethodGet, "/pause", "", http.StatusOK, true, "maintenance"},
+		{"resume", http.MethodPost, "/resume", "", http.StatusOK, false, ""},
+		{"pause with text message", http.MethodPost, "/pause", "disk swap\n", http.StatusOK, true, "disk swap"},
+		{"resume again", http.MethodPost, "/resume", "", http.StatusOK, false, ""},
+		{"pause without message", http.MethodPost, "/pause", "", http.StatusOK, true, "Paused via API"},
+		{"final resume", http.MethodPost, "/resume", "", http.StatusOK, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, mux, tt.method, tt.path, tt.body)
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d (%s)", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+
+			var resp pauseResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+
+			if resp.Paused != tt.expectedPaused || resp.Message != tt.expectedMessage {
+				t.Errorf("expected paused=%v message=%q, got %+v", tt.expectedPaused, tt.expectedMessage, resp)
+			}
+		})
+	}
+
+	if pause.IsPaused() {
+		t.Error("pipeline should not be paused anymore")
+	}
+}
+
+func TestStop(t *testing.T) {
+	stopped := make(chan struct{})
+	mux := newMux(func() { close(stopped) })
+
+	rec := doRequest(t, mux, http.MethodPost, "/stop", "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop function was not called")
+	}
+
+	rec = doRequest(t, mux, http.MethodGet, "/stop", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d on GET, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestStatsAndSeeds(t *testing.T) {
+	stats.Init()
+	mux := newMux(nil)
+
+	rec := doRequest(t, mux, http.MethodGet, "/stats", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var statsResp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &statsResp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+
+	if _, ok := statsResp["Total URL crawled"]; !ok {
+		t.Errorf("expected stats to contain the crawled URLs count, got %v", statsResp)
+	}
+
+	rec = doRequest(t, mux, http.MethodGet, "/seeds", "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("expected an empty list of seeds, got %d %s", rec.Code, rec.Body.String())
+	}
+}
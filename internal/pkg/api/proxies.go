@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver"
+)
+
+// proxiesHandler returns the state of the proxies: requests, connection failures and ejections
+func proxiesHandler(w http.ResponseWriter, _ *http.Request) {
+	proxies, err := archiver.ProxiesStatus()
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, proxies)
+}
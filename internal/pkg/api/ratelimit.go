@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/ratelimiter"
+)
+
+// hostLimitsResponse describes the rate limits applied to a host
+type hostLimitsResponse struct {
+	Host           string  `json:"host"`
+	Capacity       float64 `json:"capacity"`
+	RefillRate     float64 `json:"refill_rate"`
+	MaxConcurrency int     `json:"max_concurrency"`
+	MinDelay       string  `json:"min_delay"`
+}
+
+// rateLimitResponse is returned by the rate limit endpoints
+type rateLimitResponse struct {
+	Rules []ratelimiter.Rule  `json:"rules"`
+	Host  *hostLimitsResponse `json:"host,omitempty"`
+}
+
+// rateLimitHandler returns the rate limit rules, and the limits applied to the host given with ?host= if any
+func rateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := archiver.RateLimitRules()
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := rateLimitResponse{Rules: rules}
+	if resp.Rules == nil {
+		resp.Rules = []ratelimiter.Rule{}
+	}
+
+	if host := r.URL.Query().Get("host"); host != "" {
+		limits, err := archiver.RateLimitHostLimits(host)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+			return
+		}
+
+		resp.Host = &hostLimitsResponse{
+			Host:           host,
+			Capacity:       limits.Capacity,
+			RefillRate:     limits.RefillRate,
+			MaxConcurrency: limits.MaxConcurrency,
+			MinDelay:       limits.MinDelay.String(),
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// rateLimitReloadHandler reloads the rate limit rules file without waiting for it to be detected as modified
+func rateLimitReloadHandler(w http.ResponseWriter, _ *http.Request) {
+	if err := archiver.ReloadRateLimitRules(); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, archiver.ErrRateLimitDisabled) || errors.Is(err, archiver.ErrNoRateLimitRules) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+
+	rules, _ := archiver.RateLimitRules()
+
+	writeJSON(w, http.StatusOK, rateLimitResponse{Rules: rules})
+}
+
+// hostsHandler returns the hosts with requests in flight, the most saturated first.
+// ?limit= caps the number of hosts returned (100 by default, 0 for all).
+func hostsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	hosts, err := archiver.HostsConcurrency()
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if limit > 0 && len(hosts) > limit {
+		hosts = hosts[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, hosts)
+}
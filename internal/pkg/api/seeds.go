@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/internal/pkg/reactor"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+// maxSeedsBodySize is the maximum size of a POST /seeds body
+const maxSeedsBodySize = 32 << 20
+
+// seedRequest is a seed submitted to POST /seeds
+type seedRequest struct {
+	URL      string `json:"url"`
+	Hops     int    `json:"hops"`
+	Via      string `json:"via"`
+	Priority int64  `json:"priority"`
+}
+
+// addSeedsResponse is returned by POST /seeds
+type addSeedsResponse struct {
+	Received int    `json:"received"`
+	Mode     string `json:"mode"`
+}
+
+// addSeedsErrorResponse is returned by POST /seeds when the direct insertion failed partway through,
+// Inserted seeds being already in the reactor
+type addSeedsErrorResponse struct {
+	Error    string `json:"error"`
+	Received int    `json:"received"`
+	Inserted int    `json:"inserted"`
+}
+
+// addSeedsHandler injects seeds in the running crawl.
+// The body is either a single URL, a newline-separated list of URLs, or a JSON object (or array of objects) with url, hops, via
+// and priority. By default the seeds are added to the local queue, the URLs with the highest priority being crawled first,
+// with ?direct=true they are inserted in the reactor right away,
+// which blocks until the reactor has room for them. If the insertion fails partway through, the response gives the
+// number of seeds already inserted.
+func addSeedsHandler(w http.ResponseWriter, r *http.Request) {
+	direct, _ := strconv.ParseBool(r.URL.Query().Get("direct"))
+
+	if !direct && config.Get().UseHQ {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "seeds can only be inserted with direct=true when crawling from HQ"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSeedsBodySize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	seeds, err := parseSeeds(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	if len(seeds) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "no seed provided"})
+		return
+	}
+
+	if direct {
+		for inserted, seed := range seeds {
+			parsedURL := &models.URL{Raw: seed.URL, Hops: seed.Hops}
+			if err := parsedURL.Parse(); err != nil {
+				writeJSON(w, http.StatusBadRequest, addSeedsErrorResponse{Error: fmt.Sprintf("invalid URL %q: %s", seed.URL, err), Received: len(seeds), Inserted: inserted})
+				return
+			}
+
+			item := models.NewItem(uuid.New().String(), parsedURL, seed.Via)
+			item.SetStatus(models.ItemFresh)
+
+			if err := reactor.ReceiveInsert(item); err != nil {
+				writeJSON(w, http.StatusServiceUnavailable, addSeedsErrorResponse{Error: err.Error(), Received: len(seeds), Inserted: inserted})
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, addSeedsResponse{Received: len(seeds), Mode: "direct"})
+		return
+	}
+
+	URLs := make([]sqlc_model.Url, 0, len(seeds))
+	for _, seed := range seeds {
+		URLs = append(URLs, sqlc_model.Url{
+			Value:    seed.URL,
+			Via:      seed.Via,
+			Hops:     int64(seed.Hops),
+			Priority: seed.Priority,
+		})
+	}
+
+	if err := lq.Add(r.Context(), URLs); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, addSeedsResponse{Received: len(seeds), Mode: "queue"})
+}
+
+// parseSeeds reads the seeds from a POST /seeds body, JSON is detected from the content type or the first character
+func parseSeeds(contentType string, body []byte) (seeds []seedRequest, err error) {
+	trimmed := bytes.TrimSpace(body)
+
+	if strings.HasPrefix(contentType, "application/json") || (len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')) {
+		if len(trimmed) > 0 && trimmed[0] == '{' {
+			var seed seedRequest
+			if err := json.Unmarshal(trimmed, &seed); err != nil {
+				return nil, fmt.Errorf("invalid JSON body: %w", err)
+			}
+			seeds = append(seeds, seed)
+		} else if err := json.Unmarshal(trimmed, &seeds); err != nil {
+			return nil, fmt.Errorf("invalid JSON body: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			seeds = append(seeds, seedRequest{URL: line})
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range seeds {
+		seeds[i].URL = strings.TrimSpace(seeds[i].URL)
+		if seeds[i].URL == "" {
+			return nil, fmt.Errorf("seed %d has no URL", i)
+		}
+
+		if seeds[i].Hops < 0 {
+			return nil, fmt.Errorf("seed %d has negative hops", i)
+		}
+
+		parsedURL := &models.URL{Raw: seeds[i].URL}
+		if err := parsedURL.Parse(); err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", seeds[i].URL, err)
+		}
+	}
+
+	return seeds, nil
+}
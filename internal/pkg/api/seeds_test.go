@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/internetarchive/Zeno/internal/pkg/reactor"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+func TestParseSeeds(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    []seedRequest
+		expectErr   bool
+	}{
+		{
+			name:     "single URL",
+			body:     "https://example.com/\n",
+			expected: []seedRequest{{URL: "https://example.com/"}},
+		},
+		{
+			name:     "newline list with comments and blank lines",
+			body:     "https://example.com/a\n\n# comment\r\nhttps://example.com/b\r\n",
+			expected: []seedRequest{{URL: "https://example.com/a"}, {URL: "https://example.com/b"}},
+		},
+		{
+			name:        "JSON object",
+			contentType: "application/json",
+			body:        `{"url": "https://example.com/", "hops": 2, "via": "https://example.org/", "priority": 10}`,
+			expected:    []seedRequest{{URL: "https://example.com/", Hops: 2, Via: "https://example.org/", Priority: 10}},
+		},
+		{
+			name:     "JSON array without content type",
+			body:     `[{"url": "https://example.com/a"}, {"url": "https://example.com/b", "hops": 1}]`,
+			expected: []seedRequest{{URL: "https://example.com/a"}, {URL: "https://example.com/b", Hops: 1}},
+		},
+		{
+			name:      "invalid JSON",
+			body:      `{"url": `,
+			expectErr: true,
+		},
+		{
+			name:      "JSON seed without URL",
+			body:      `[{"hops": 1}]`,
+			expectErr: true,
+		},
+		{
+			name:      "negative hops",
+			body:      `{"url": "https://example.com/", "hops": -1}`,
+			expectErr: true,
+		},
+		{
+			name:      "invalid URL",
+			body:      "https://exa mple.com/\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seeds, err := parseSeeds(tt.contentType, []byte(tt.body))
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", seeds)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(seeds, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, seeds)
+			}
+		})
+	}
+}
+
+func TestAddSeedsDirectPartialFailure(t *testing.T) {
+	output := make(chan *models.Item)
+	if err := reactor.Start(1, output); err != nil {
+		t.Fatal(err)
+	}
+	defer reactor.Stop()
+
+	// The reactor is frozen once the first seed got in, the others can't be inserted
+	go func() {
+		<-output
+		reactor.Freeze()
+	}()
+
+	rec := doRequest(t, newMux(nil), http.MethodPost, "/seeds?direct=true", "https://example.com/a\nhttps://example.com/b\nhttps://example.com/c")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	}
+
+	var resp addSeedsErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+
+	if resp.Received != 3 || resp.Inserted != 1 || resp.Error == "" {
+		t.Errorf("expected 1 of the 3 seeds to be reported as inserted, got %+v", resp)
+	}
+}
@@ -12,11 +12,14 @@ import (
 	"github.com/CorentinB/warc"
 	"github.com/dustin/go-humanize"
 	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
 	"github.com/internetarchive/Zeno/internal/pkg/archiver/ratelimiter"
 	"github.com/internetarchive/Zeno/internal/pkg/config"
 	"github.com/internetarchive/Zeno/internal/pkg/controler/pause"
 	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/log/crawllog"
 	"github.com/internetarchive/Zeno/internal/pkg/postprocessor/domainscrawl"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/cookies"
 	"github.com/internetarchive/Zeno/internal/pkg/stats"
 	"github.com/internetarchive/Zeno/pkg/models"
 )
@@ -35,8 +38,10 @@ type archiver struct {
 	inputCh  chan *models.Item
 	outputCh chan *models.Item
 
-	Client          *warc.CustomHTTPClient
-	ClientWithProxy *warc.CustomHTTPClient
+	Client       *warc.CustomHTTPClient
+	ProxyClients map[string]*warc.CustomHTTPClient // proxied clients by proxy URL
+
+	retryPolicy *retryPolicy
 }
 
 var (
@@ -49,6 +54,7 @@ var (
 // Start initializes the internal archiver structure, start the WARC writer and start routines, should only be called once and returns an error if called more than once
 func Start(inputChan, outputChan chan *models.Item) error {
 	var done bool
+	var startErr error
 
 	log.Start()
 	logger = log.NewFieldedLogger(&log.Fields{
@@ -58,12 +64,29 @@ func Start(inputChan, outputChan chan *models.Item) error {
 	stats.Init()
 
 	once.Do(func() {
+		retryPolicy, err := newRetryPolicy(config.Get())
+		if err != nil {
+			logger.Error("invalid retry policy", "err", err.Error())
+			startErr = err
+			done = true
+			return
+		}
+
+		// Setup the routing of the requests through the proxies, if any
+		if err := startProxyRouter(); err != nil {
+			logger.Error("invalid proxy configuration", "err", err.Error())
+			startErr = err
+			done = true
+			return
+		}
+
 		ctx, cancel := context.WithCancel(context.Background())
 		globalArchiver = &archiver{
-			ctx:      ctx,
-			cancel:   cancel,
-			inputCh:  inputChan,
-			outputCh: outputChan,
+			ctx:         ctx,
+			cancel:      cancel,
+			inputCh:     inputChan,
+			outputCh:    outputChan,
+			retryPolicy: retryPolicy,
 		}
 		if !config.Get().DisableRateLimit {
 			globalBucketManager = ratelimiter.NewBucketManager(ctx,
@@ -73,7 +96,36 @@ func Start(inputChan, outputChan chan *models.Item) error {
 				config.Get().RateLimitCleanupFrequency,
 			)
 			logger.Info("bucket manager started")
+
+			if config.Get().RateLimitRules != "" {
+				if err := ReloadRateLimitRules(); err != nil {
+					logger.Error("unable to load the rate limit rules", "err", err.Error())
+					startErr = err
+				}
+
+				globalArchiver.wg.Add(1)
+				go watchRateLimitRules(ctx, &globalArchiver.wg)
+			}
 		}
+		// Open the persistent dedupe index before the WARC writer that queries it
+		if config.Get().WARCDedupeIndex {
+			if err := startDedupeIndex(); err != nil {
+				logger.Error("unable to open the dedupe index", "err", err.Error())
+				startErr = err
+				done = true
+				return
+			}
+		}
+
+		startConcurrencyLimiter(ctx, &globalArchiver.wg)
+		globalResolver, err = newResolver()
+		if err != nil {
+			logger.Error("unable to start the DNS resolver", "err", err.Error())
+			startErr = err
+			done = true
+			return
+		}
+
 		logger.Debug("initialized")
 
 		// Setup WARC writing HTTP clients
@@ -81,6 +133,11 @@ func Start(inputChan, outputChan chan *models.Item) error {
 
 		logger.Debug("WARC writer started")
 
+		if config.Get().WARCCDXJ {
+			globalArchiver.wg.Add(1)
+			go watchClosedWARCs(ctx, &globalArchiver.wg)
+		}
+
 		for i := 0; i < config.Get().WorkersCount; i++ {
 			globalArchiver.wg.Add(1)
 			go globalArchiver.worker(strconv.Itoa(i))
@@ -94,7 +151,7 @@ func Start(inputChan, outputChan chan *models.Item) error {
 		return ErrArchiverAlreadyInitialized
 	}
 
-	return nil
+	return startErr
 }
 
 // Stop stops the archiver routines and the WARC writer
@@ -115,15 +172,27 @@ func Stop() {
 				}
 			}
 		}()
-		globalArchiver.Client.WaitGroup.Wait()
+		for _, c := range GetClients() {
+			c.WaitGroup.Wait()
+		}
 		stopLocalWatcher <- struct{}{}
 		logger.Debug("WARC writing finished")
-		globalArchiver.Client.Close()
-		if globalArchiver.ClientWithProxy != nil {
-			globalArchiver.ClientWithProxy.WaitGroup.Wait()
-			globalArchiver.ClientWithProxy.Close()
+
+		// Wait for the records written outside of the requests and refuse new ones
+		recordsMu.Lock()
+		clientsClosed = true
+		recordsMu.Unlock()
+
+		for _, c := range GetClients() {
+			c.Close()
 		}
 
+		if config.Get().WARCCDXJ {
+			finishCDXJIndexes()
+		}
+
+		stopDedupeIndex()
+
 		logger.Info("stopped")
 	}
 	if globalBucketManager != nil {
@@ -133,6 +202,56 @@ func Stop() {
 	}
 }
 
+// Do executes a request outside of the pipeline (e.g. robots.txt) with the WARC-writing client, so the exchange is archived.
+// The caller must read and close the response body for the records to be written.
+func Do(req *http.Request) (*http.Response, error) {
+	if globalArchiver == nil {
+		return nil, ErrArchiverNotInitialized
+	}
+
+	client, proxyURL, err := clientFor(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Go through the same per-host limits as the pipeline requests, the slot is held until the body is closed
+	release := globalConcurrencyLimiter.Acquire(req.URL.Host)
+	if globalBucketManager != nil {
+		globalBucketManager.Wait(req.URL.Host)
+	}
+
+	resp, err := client.Do(req)
+	reportProxyResult(proxyURL, err)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// releasingBody releases the concurrency slot of a request made with Do once its body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// SetCrawlDelay limits the rate of requests to the given host, if the rate limiter is enabled
+func SetCrawlDelay(host string, delay time.Duration) {
+	if globalBucketManager != nil {
+		globalBucketManager.SetCrawlDelay(host, delay)
+	}
+}
+
 func (a *archiver) worker(workerID string) {
 	defer a.wg.Done()
 
@@ -219,6 +338,11 @@ func archive(workerID string, seed *models.Item) {
 				err          error
 				resp         *http.Response
 				feedbackChan chan struct{}
+				tries        int
+				fetchStart   time.Time
+
+				responseRecordID string
+				responseResult   *warc.ResponseResult
 			)
 
 			// Execute the request
@@ -227,6 +351,18 @@ func archive(workerID string, seed *models.Item) {
 				panic("request is nil")
 			}
 
+			// Don't request the hosts that recently failed to resolve
+			if globalResolver.unresolvable(req.URL.Host) {
+				logger.Warn("host recently failed to resolve, skipping", "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "url", req.URL.String())
+				crawllog.Write(crawllog.NewEntry(item, crawllog.StatusDomainLookupFailed, workerID))
+				item.SetStatus(models.ItemFailed)
+				return
+			}
+
+			// Wait for a slot if the host has too many requests in flight
+			release := globalConcurrencyLimiter.Acquire(req.URL.Host)
+			defer release()
+
 			// Wait for the rate limiter if enabled
 			if globalBucketManager != nil {
 				elapsed := globalBucketManager.Wait(req.URL.Host)
@@ -236,12 +372,22 @@ func archive(workerID string, seed *models.Item) {
 			// Don't use the global bucket manager in the retry loop.
 			// Most failed requests won't reach the server anyway, so we don't need to wait for the rate limit.
 			// This prevents workers from being blocked for too long by dead sites, such as host unreachable or DNS errors.
-			for retry := 0; retry <= config.Get().MaxRetry; retry++ {
-				// This is unused unless there is an error
-				retrySleepTime := time.Second * time.Duration(retry*2)
-
+			policy := globalArchiver.retryPolicy
+			firstAttemptTime := time.Now()
+			for retry := 0; ; retry++ {
 				// Get and measure request time
 				getStartTime := time.Now()
+				tries, fetchStart = retry+1, getStartTime
+
+				// Name the response record of the attempt, for the records concurrent to it
+				responseRecordID = uuid.NewString()
+				req = req.WithContext(warc.WithResponseRecordID(req.Context(), responseRecordID))
+
+				// Have the WARC writer tell the payload digest and whether it wrote a revisit, for the crawl log
+				if crawllog.Enabled() {
+					responseResult = warc.NewResponseResult()
+					req = req.WithContext(warc.WithResponseResult(req.Context(), responseResult))
+				}
 
 				// If WARC writing is asynchronous, we don't need a feedback channel
 				if !config.Get().WARCWriteAsync {
@@ -250,51 +396,81 @@ func archive(workerID string, seed *models.Item) {
 					req = req.WithContext(context.WithValue(req.Context(), "feedback", feedbackChan))
 				}
 
-				if config.Get().Proxy != "" {
-					resp, err = globalArchiver.ClientWithProxy.Do(req)
+				// Pick the client of the proxy the host is routed to, if any
+				client, proxyURL, clientErr := clientFor(req.URL.Host)
+				if clientErr != nil {
+					err = clientErr
 				} else {
-					resp, err = globalArchiver.Client.Do(req)
+					resp, err = client.Do(req)
+					reportProxyResult(proxyURL, err)
 				}
 
 				if err != nil {
-					if retry < config.Get().MaxRetry {
-						logger.Warn("retrying request", "err", err.Error(), "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry, "sleep_time", retrySleepTime.String())
-						time.Sleep(retrySleepTime)
-						continue
+					// The host doesn't resolve, retrying won't help, neither will the other URLs of the host
+					if globalResolver.negativeCacheEnabled() && isUnresolvable(err) {
+						logger.Error("unable to resolve host", "err", err.Error(), "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry)
+						logFetchFailure(item, workerID, err, tries, fetchStart)
+						item.SetStatus(models.ItemFailed)
+						return
 					}
 
-					// retries exhausted
-					logger.Error("unable to execute request", "err", err.Error(), "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops())
+					if policy.retryableError(err) {
+						if retrySleepTime, ok := policy.next(retry, 0, firstAttemptTime); ok {
+							logger.Warn("retrying request", "err", err.Error(), "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry, "sleep_time", retrySleepTime.String())
+							time.Sleep(retrySleepTime)
+							continue
+						}
+					}
+
+					// retries exhausted or not retryable
+					logger.Error("unable to execute request", "err", err.Error(), "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry)
+					logFetchFailure(item, workerID, err, tries, fetchStart)
 					item.SetStatus(models.ItemFailed)
 					return
 				}
 
-				// Retries on 5XX, or 403, 408, 425 and 429
+				// Store the cookies set by the server in the jar, if enabled
+				cookies.SetFromResponse(req.URL, resp)
+
+				// Retries on the status codes of the retry policy (by default 5XX, or 403, 408, 425 and 429)
 				// TODO: 403 is too broad, we should retry only if/when we detect that some middleman or the server itself
 				// rate-limited us, like cloudflare with the cf-mitigate header etc.
-				if resp.StatusCode >= 500 || resp.StatusCode == 403 || resp.StatusCode == 408 || resp.StatusCode == 425 || resp.StatusCode == 429 {
+				if policy.retryableStatus(resp.StatusCode) {
+					retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+
 					if globalBucketManager != nil {
 						globalBucketManager.AdjustOnFailure(req.URL.Host, resp.StatusCode)
+
+						// The server told us when to come back, hold every request to this host until then
+						if retryAfter > 0 {
+							globalBucketManager.Penalize(req.URL.Host, min(retryAfter, maxRetryAfterPenalty))
+						}
 					}
-					if retry < config.Get().MaxRetry {
-						logger.Warn("bad response code, retrying", "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry, "sleep_time", retrySleepTime.String(), "status_code", resp.StatusCode, "url", req.URL.String())
 
-						// Consume body, needed to avoid leaking RAM & storage
-						io.Copy(io.Discard, resp.Body)
-						resp.Body.Close()
+					// Consume body, needed to avoid leaking RAM & storage
+					discarded, _ := io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+
+					if retrySleepTime, ok := policy.next(retry, retryAfter, firstAttemptTime); ok {
+						logger.Warn("bad response code, retrying", "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry, "sleep_time", retrySleepTime.String(), "retry_after", retryAfter.String(), "status_code", resp.StatusCode, "url", req.URL.String())
 
 						time.Sleep(retrySleepTime)
 						continue
-					} else {
-						logger.Error("bad response code, retries exceeded", "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "status_code", resp.StatusCode, "url", req.URL.String())
-						item.SetStatus(models.ItemFailed)
+					}
 
-						// Consume body, needed to avoid leaking RAM & storage
-						io.Copy(io.Discard, resp.Body)
-						resp.Body.Close()
+					logger.Error("bad response code, retries exceeded", "seed_id", seed.GetShortID(), "item_id", item.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops(), "retry", retry, "retry_after", retryAfter.String(), "status_code", resp.StatusCode, "url", req.URL.String())
 
-						return
-					}
+					entry := crawllog.NewEntry(item, resp.StatusCode, workerID)
+					entry.Size = discarded
+					entry.MIME = resp.Header.Get("Content-Type")
+					entry.FetchStart = fetchStart
+					entry.FetchDuration = time.Since(fetchStart)
+					entry.AnnotateTries(tries)
+					crawllog.Write(entry)
+
+					item.SetStatus(models.ItemFailed)
+
+					return
 				} else {
 					if globalBucketManager != nil {
 						globalBucketManager.OnSuccess(req.URL.Host)
@@ -306,19 +482,48 @@ func archive(workerID string, seed *models.Item) {
 				break
 			}
 
+			// Count the body as it's processed, for the crawl log
+			var payload *payloadReader
+			if crawllog.Enabled() {
+				payload = &payloadReader{ReadCloser: resp.Body}
+				resp.Body = payload
+			}
+
 			// Set the response in the URL
 			item.GetURL().SetResponse(resp)
+			item.GetURL().SetResponseRecordID(responseRecordID)
 
 			// Process the body and measure the time
 			processStartTime := time.Now()
 			err = ProcessBody(item.GetURL(), config.Get().DisableAssetsCapture, domainscrawl.Enabled(), config.Get().MaxHops, config.Get().WARCTempDir)
 			if err != nil {
 				logger.Error("unable to process body", "err", err.Error(), "item_id", item.GetShortID(), "seed_id", seed.GetShortID(), "depth", item.GetDepth(), "hops", item.GetURL().GetHops())
+				logFetchFailure(item, workerID, err, tries, fetchStart)
 				item.SetStatus(models.ItemFailed)
 				return
 			}
 
 			stats.MeanProcessBodyTimeAdd(time.Since(processStartTime))
+
+			if payload != nil {
+				entry := crawllog.NewEntry(item, resp.StatusCode, workerID)
+				entry.Size = payload.size
+				entry.MIME = resp.Header.Get("Content-Type")
+				if entry.MIME == "" && item.GetURL().GetMIMEType() != nil {
+					entry.MIME = item.GetURL().GetMIMEType().String()
+				}
+				entry.FetchStart = fetchStart
+				entry.FetchDuration = time.Since(fetchStart)
+				entry.AnnotateTries(tries)
+
+				// The body was read, the records are being written
+				<-responseResult.Done()
+				entry.Digest = responseResult.PayloadDigest
+				if responseResult.Revisit {
+					entry.Annotate(crawllog.AnnotationRevisit)
+				}
+				crawllog.Write(entry)
+			}
 			stats.HTTPReturnCodesIncr(strconv.Itoa(resp.StatusCode))
 
 			// If WARC writing is asynchronous, we don't need to wait for the feedback channel
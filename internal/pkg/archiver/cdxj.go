@@ -0,0 +1,95 @@
+package archiver
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/cdxj"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+// cdxjCheckInterval is how often the WARC directory is checked for closed WARC files to index
+const cdxjCheckInterval = 10 * time.Second
+
+// watchClosedWARCs indexes the WARC files as they are closed by the rotator
+func watchClosedWARCs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(cdxjCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			indexClosedWARCs()
+		}
+	}
+}
+
+// indexClosedWARCs writes the CDXJ index of the closed WARC files that aren't indexed yet,
+// the rotator removing the .open suffix once a file is complete
+func indexClosedWARCs() {
+	warcs, err := filepath.Glob(filepath.Join(config.Get().JobPath, "warcs", "*.warc.gz"))
+	if err != nil {
+		logger.Error("unable to list the WARC files", "err", err.Error(), "func", "archiver.indexClosedWARCs")
+		return
+	}
+
+	for _, warcPath := range warcs {
+		if _, err := os.Stat(cdxj.IndexPath(warcPath)); err == nil {
+			continue
+		}
+
+		lines, err := cdxj.ReadFile(warcPath)
+		if err != nil {
+			logger.Warn("unable to index WARC file", "warc", warcPath, "err", err.Error(), "func", "archiver.indexClosedWARCs")
+			continue
+		}
+
+		// Added to the dedupe index first, the CDXJ index marking the file as fully indexed
+		if globalDedupeIndex != nil {
+			if err := addToDedupeIndex(warcPath, lines); err != nil {
+				logger.Warn("unable to add WARC file to the dedupe index", "warc", warcPath, "err", err.Error(), "func", "archiver.indexClosedWARCs")
+				continue
+			}
+		}
+
+		indexPath := cdxj.IndexPath(warcPath)
+		if err := cdxj.WriteLines(indexPath, lines); err != nil {
+			logger.Warn("unable to index WARC file", "warc", warcPath, "err", err.Error(), "func", "archiver.indexClosedWARCs")
+			continue
+		}
+
+		logger.Debug("WARC file indexed", "warc", warcPath, "index", indexPath)
+	}
+}
+
+// finishCDXJIndexes indexes the last WARC files once the writers are closed, and merges all the indexes
+// of the job into jobs/<job>/index.cdxj if enabled
+func finishCDXJIndexes() {
+	indexClosedWARCs()
+
+	if !config.Get().WARCCDXJMerge {
+		return
+	}
+
+	indexes, err := filepath.Glob(filepath.Join(config.Get().JobPath, "warcs", "*.cdxj"))
+	if err != nil {
+		logger.Error("unable to list the CDXJ indexes", "err", err.Error(), "func", "archiver.finishCDXJIndexes")
+		return
+	}
+
+	output := path.Join(config.Get().JobPath, "index.cdxj")
+	if err := cdxj.Merge(indexes, output); err != nil {
+		logger.Error("unable to merge the CDXJ indexes", "err", err.Error(), "func", "archiver.finishCDXJIndexes")
+		return
+	}
+
+	logger.Info("CDXJ indexes merged", "index", output, "indexes", len(indexes))
+}
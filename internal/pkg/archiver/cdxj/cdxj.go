@@ -0,0 +1,288 @@
+// Package cdxj builds CDXJ indexes of WARC files, as read by pywb-style replay tools.
+// Each line is "<SURT key> <14-digit timestamp> <JSON block>", the lines being sorted bytewise.
+package cdxj
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/utils"
+)
+
+// Line is an entry of a CDXJ index, pointing to a record of a WARC file
+type Line struct {
+	Key       string
+	Timestamp string
+	URL       string
+	Mime      string
+	Status    string
+	Digest    string
+	Length    int64
+	Offset    int64
+	Filename  string
+	RecordID  string // WARC-Record-ID of the record, not written in the index
+}
+
+// String returns the line as written in the CDXJ index, without the trailing newline
+func (l Line) String() string {
+	block := struct {
+		URL      string `json:"url"`
+		Mime     string `json:"mime,omitempty"`
+		Status   string `json:"status,omitempty"`
+		Digest   string `json:"digest,omitempty"`
+		Length   string `json:"length"`
+		Offset   string `json:"offset"`
+		Filename string `json:"filename"`
+	}{l.URL, l.Mime, l.Status, l.Digest, strconv.FormatInt(l.Length, 10), strconv.FormatInt(l.Offset, 10), l.Filename}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(block) // Can't fail on strings
+
+	return l.Key + " " + l.Timestamp + " " + strings.TrimSuffix(buf.String(), "\n")
+}
+
+// IndexPath returns the path of the CDXJ index of the WARC file, written next to it
+func IndexPath(warcPath string) string {
+	return strings.TrimSuffix(warcPath, ".warc.gz") + ".cdxj"
+}
+
+// IndexFile returns the sorted CDXJ lines of the response, revisit and resource records of the gzipped WARC file
+func IndexFile(warcPath string) ([]string, error) {
+	lines, err := ReadFile(warcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedLines(lines), nil
+}
+
+// ReadFile returns the lines of the response, revisit and resource records of the gzipped WARC file, in the order of the file.
+// Every record must be in its own gzip member, so its offset and compressed length can be recorded.
+func ReadFile(warcPath string) ([]Line, error) {
+	file, err := os.Open(warcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := &countingReader{r: bufio.NewReader(file)}
+	filename := filepath.Base(warcPath)
+
+	var (
+		lines []Line
+		gz    *gzip.Reader
+	)
+
+	for {
+		offset := reader.n
+
+		if gz == nil {
+			gz, err = gzip.NewReader(reader)
+		} else {
+			err = gz.Reset(reader)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip member at offset %d: %w", offset, err)
+		}
+		gz.Multistream(false)
+
+		line, ok, err := readRecord(bufio.NewReader(gz))
+		if err != nil {
+			return nil, fmt.Errorf("reading record at offset %d: %w", offset, err)
+		}
+
+		// Consume the rest of the member to get its compressed length
+		if _, err := io.Copy(io.Discard, gz); err != nil {
+			return nil, fmt.Errorf("reading record at offset %d: %w", offset, err)
+		}
+
+		if !ok {
+			continue
+		}
+
+		line.Offset = offset
+		line.Length = reader.n - offset
+		line.Filename = filename
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// WriteIndex writes the CDXJ index of the WARC file next to it and returns its path
+func WriteIndex(warcPath string) (string, error) {
+	indexPath := IndexPath(warcPath)
+
+	return indexPath, WriteIndexTo(warcPath, indexPath)
+}
+
+// WriteIndexTo writes the CDXJ index of the WARC file at indexPath
+func WriteIndexTo(warcPath, indexPath string) error {
+	lines, err := ReadFile(warcPath)
+	if err != nil {
+		return err
+	}
+
+	return WriteLines(indexPath, lines)
+}
+
+// WriteLines writes the lines, read with ReadFile, as a sorted CDXJ index at indexPath
+func WriteLines(indexPath string, lines []Line) error {
+	return writeLines(indexPath, func(w *bufio.Writer) error {
+		for _, line := range sortedLines(lines) {
+			if _, err := w.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sortedLines returns the lines as written in the index, sorted bytewise
+func sortedLines(lines []Line) []string {
+	sorted := make([]string, 0, len(lines))
+	for _, line := range lines {
+		sorted = append(sorted, line.String())
+	}
+
+	slices.Sort(sorted)
+
+	return sorted
+}
+
+// readRecord reads the headers of the WARC record and returns its CDXJ line, ok being false if the record isn't indexed
+func readRecord(r *bufio.Reader) (line Line, ok bool, err error) {
+	reader := textproto.NewReader(r)
+
+	version, err := reader.ReadLine()
+	if err != nil {
+		return line, false, err
+	}
+
+	if !strings.HasPrefix(version, "WARC/") {
+		return line, false, fmt.Errorf("invalid WARC version line %q", version)
+	}
+
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return line, false, err
+	}
+
+	warcType := header.Get("WARC-Type")
+	if warcType != "response" && warcType != "revisit" && warcType != "resource" {
+		return line, false, nil
+	}
+
+	// WARC/1.0 target URIs may be enclosed in angle brackets
+	line.URL = header.Get("WARC-Target-URI")
+	if strings.HasPrefix(line.URL, "<") && strings.HasSuffix(line.URL, ">") {
+		line.URL = line.URL[1 : len(line.URL)-1]
+	}
+	line.Key, err = utils.SURT(line.URL)
+	if err != nil {
+		return line, false, nil
+	}
+
+	date, err := time.Parse(time.RFC3339Nano, header.Get("WARC-Date"))
+	if err != nil {
+		return line, false, fmt.Errorf("invalid WARC-Date: %w", err)
+	}
+	line.Timestamp = date.UTC().Format("20060102150405")
+
+	line.Digest = strings.TrimPrefix(header.Get("WARC-Payload-Digest"), "sha1:")
+	line.RecordID = header.Get("WARC-Record-ID")
+
+	if warcType == "resource" || !strings.HasPrefix(header.Get("Content-Type"), "application/http") {
+		line.Mime = mime(header.Get("Content-Type"))
+		return line, true, nil
+	}
+
+	// A malformed HTTP response is still indexed, without status and mime
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		return line, true, nil
+	}
+
+	line.Status = strconv.Itoa(resp.StatusCode)
+	if warcType == "revisit" {
+		line.Mime = "warc/revisit"
+	} else {
+		line.Mime = mime(resp.Header.Get("Content-Type"))
+	}
+
+	return line, true, nil
+}
+
+// mime returns the media type of the Content-Type header, without its parameters
+func mime(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// writeLines writes the file through a temporary file, so readers never see a partial index
+func writeLines(path string, write func(w *bufio.Writer) error) error {
+	tmpPath := path + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	if err := write(w); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// countingReader counts the bytes read from the WARC file. It is an io.ByteReader so that
+// the gzip reader doesn't read past the end of the members.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
@@ -0,0 +1,172 @@
+package cdxj
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CorentinB/warc"
+)
+
+// writeTestWARC writes the records to a gzipped WARC file, one gzip member per record like the rotator
+func writeTestWARC(t *testing.T, path string, records []map[string]string) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, fields := range records {
+		writer, err := warc.NewWriter(file, filepath.Base(path), "GZIP", "", false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record := warc.NewRecord(t.TempDir(), false)
+		for key, value := range fields {
+			if key == "content" {
+				record.Content.Write([]byte(value))
+				continue
+			}
+			record.Header.Set(key, value)
+		}
+
+		if _, err := writer.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writer.CloseCompressedWriter(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIndexFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ZENO-20250101000000-00001-test.warc.gz")
+
+	writeTestWARC(t, path, []map[string]string{
+		{"WARC-Type": "warcinfo", "WARC-Date": "2025-01-01T00:00:00Z", "content": "software: Zeno\r\n"},
+		{"WARC-Type": "request", "WARC-Target-URI": "https://www.example.com/b", "WARC-Date": "2025-01-01T00:00:01Z", "Content-Type": "application/http; msgtype=request", "content": "GET /b HTTP/1.1\r\nHost: www.example.com\r\n\r\n"},
+		{"WARC-Type": "response", "WARC-Target-URI": "https://www.example.com/b", "WARC-Date": "2025-01-01T00:00:01Z", "Content-Type": "application/http; msgtype=response", "WARC-Payload-Digest": "sha1:AAAA", "content": "HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<html></html>"},
+		{"WARC-Type": "revisit", "WARC-Target-URI": "https://example.com/a?x=1&y=<2>", "WARC-Date": "2025-01-01T00:00:02.5Z", "Content-Type": "application/http; msgtype=response", "WARC-Payload-Digest": "sha1:BBBB", "content": "HTTP/1.1 301 Moved Permanently\r\nLocation: /b\r\n\r\n"},
+		{"WARC-Type": "resource", "WARC-Target-URI": "dns:example.com", "WARC-Date": "2025-01-01T00:00:00Z", "Content-Type": "text/dns", "content": "example.com. 300 IN A 10.0.0.1\r\n"},
+	})
+
+	lines, err := IndexFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		prefix string
+		url    string
+		mime   string
+		status string
+		digest string
+	}{
+		{"com,example)/a?x=1&y=<2> 20250101000002 ", "https://example.com/a?x=1&y=<2>", "warc/revisit", "301", "BBBB"},
+		{"com,example)/b 20250101000001 ", "https://www.example.com/b", "text/html", "200", "AAAA"},
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %v", len(expected), len(lines), lines)
+	}
+
+	warcFile, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range expected {
+		if !strings.HasPrefix(lines[i], tt.prefix) {
+			t.Fatalf("expected line %d to start with %q, got %q", i, tt.prefix, lines[i])
+		}
+
+		var block map[string]string
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[i], tt.prefix)), &block); err != nil {
+			t.Fatalf("invalid JSON block in %q: %v", lines[i], err)
+		}
+
+		if block["url"] != tt.url || block["mime"] != tt.mime || block["status"] != tt.status || block["digest"] != tt.digest || block["filename"] != filepath.Base(path) {
+			t.Errorf("unexpected block %v", block)
+		}
+
+		// The offset and length point to the gzip member of the record
+		offset, _ := strconv.Atoi(block["offset"])
+		length, _ := strconv.Atoi(block["length"])
+		if offset+length > len(warcFile) {
+			t.Fatalf("offset %d and length %d out of the file", offset, length)
+		}
+
+		gz, err := gzip.NewReader(bytes.NewReader(warcFile[offset : offset+length]))
+		if err != nil {
+			t.Fatalf("invalid gzip member: %v", err)
+		}
+
+		record, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("invalid gzip member: %v", err)
+		}
+
+		if !bytes.HasPrefix(record, []byte("WARC/1.1\r\n")) || !bytes.Contains(record, []byte("WARC-Target-URI: "+tt.url)) {
+			t.Errorf("unexpected record at offset %d: %q", offset, record)
+		}
+	}
+}
+
+func TestWriteIndexAndMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	first := filepath.Join(dir, "ZENO-1.warc.gz")
+	writeTestWARC(t, first, []map[string]string{
+		{"WARC-Type": "response", "WARC-Target-URI": "https://c.com/", "WARC-Date": "2025-01-01T00:00:00Z", "Content-Type": "application/http; msgtype=response", "content": "HTTP/1.1 200 OK\r\n\r\n"},
+		{"WARC-Type": "response", "WARC-Target-URI": "https://a.com/", "WARC-Date": "2025-01-01T00:00:00Z", "Content-Type": "application/http; msgtype=response", "content": "HTTP/1.1 200 OK\r\n\r\n"},
+	})
+
+	second := filepath.Join(dir, "ZENO-2.warc.gz")
+	writeTestWARC(t, second, []map[string]string{
+		{"WARC-Type": "response", "WARC-Target-URI": "https://b.com/", "WARC-Date": "2025-01-01T00:00:00Z", "Content-Type": "application/http; msgtype=response", "content": "HTTP/1.1 200 OK\r\n\r\n"},
+	})
+
+	empty := filepath.Join(dir, "ZENO-3.warc.gz")
+	writeTestWARC(t, empty, nil)
+
+	var indexes []string
+	for _, path := range []string{first, second, empty} {
+		index, err := WriteIndex(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if index != strings.TrimSuffix(path, ".warc.gz")+".cdxj" {
+			t.Errorf("unexpected index path %s", index)
+		}
+		indexes = append(indexes, index)
+	}
+
+	output := filepath.Join(dir, "index.cdxj")
+	if err := Merge(indexes, output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		keys = append(keys, strings.Fields(line)[0])
+	}
+
+	if strings.Join(keys, " ") != "com,a)/ com,b)/ com,c)/" {
+		t.Errorf("unexpected merged index:\n%s", content)
+	}
+}
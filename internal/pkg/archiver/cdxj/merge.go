@@ -0,0 +1,104 @@
+package cdxj
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"os"
+	"strings"
+)
+
+// Merge merges the sorted CDXJ indexes into a single sorted index at the output path
+func Merge(indexes []string, output string) error {
+	var cursors mergeHeap
+
+	for _, index := range indexes {
+		file, err := os.Open(index)
+		if err != nil {
+			cursors.close()
+			return err
+		}
+
+		cursor := &mergeCursor{file: file, reader: bufio.NewReader(file)}
+		if err := cursor.next(); err != nil {
+			file.Close()
+			if err == io.EOF {
+				continue
+			}
+			cursors.close()
+			return err
+		}
+
+		cursors = append(cursors, cursor)
+	}
+	defer cursors.close()
+
+	heap.Init(&cursors)
+
+	return writeLines(output, func(w *bufio.Writer) error {
+		for cursors.Len() > 0 {
+			cursor := cursors[0]
+
+			if _, err := w.WriteString(cursor.line + "\n"); err != nil {
+				return err
+			}
+
+			if err := cursor.next(); err != nil {
+				if err != io.EOF {
+					return err
+				}
+				heap.Pop(&cursors)
+				cursor.file.Close()
+				continue
+			}
+
+			heap.Fix(&cursors, 0)
+		}
+
+		return nil
+	})
+}
+
+// mergeCursor is the current line of an index being merged
+type mergeCursor struct {
+	file   *os.File
+	reader *bufio.Reader
+	line   string
+}
+
+// next reads the next non-empty line of the index
+func (c *mergeCursor) next() error {
+	for {
+		line, err := c.reader.ReadString('\n')
+		line = strings.TrimSuffix(line, "\n")
+
+		if line != "" {
+			c.line = line
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// mergeHeap orders the cursors by their current line
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return h[i].line < h[j].line }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)        { *h = append(*h, x.(*mergeCursor)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	cursor := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return cursor
+}
+
+func (h mergeHeap) close() {
+	for _, cursor := range h {
+		cursor.file.Close()
+	}
+}
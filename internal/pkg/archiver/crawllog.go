@@ -0,0 +1,61 @@
+package archiver
+
+import (
+	"io"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/log/crawllog"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+// payloadReader counts the response body as it's read, for the crawl log
+type payloadReader struct {
+	io.ReadCloser
+	size int64
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+// SetReadDeadline sets the read deadline of the underlying body, if it supports it
+func (r *payloadReader) SetReadDeadline(t time.Time) error {
+	if conn, ok := r.ReadCloser.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return conn.SetReadDeadline(t)
+	}
+
+	return nil
+}
+
+// logFetchFailure writes the crawl log line of a fetch that failed with the error
+func logFetchFailure(item *models.Item, workerID string, err error, tries int, fetchStart time.Time) {
+	if !crawllog.Enabled() {
+		return
+	}
+
+	entry := crawllog.NewEntry(item, fetchErrorStatus(err), workerID)
+	entry.FetchStart = fetchStart
+	entry.FetchDuration = time.Since(fetchStart)
+	entry.AnnotateTries(tries)
+
+	crawllog.Write(entry)
+}
+
+// fetchErrorStatus returns the crawl log status of a fetch that failed with the error, from its network error class
+func fetchErrorStatus(err error) int {
+	switch networkErrorClass(err) {
+	case networkErrorDNS:
+		return crawllog.StatusDomainLookupFailed
+	case networkErrorConnectionRefused, networkErrorTLS:
+		return crawllog.StatusConnectFailed
+	case networkErrorConnectionReset:
+		return crawllog.StatusConnectionLost
+	case networkErrorTimeout:
+		return crawllog.StatusTimeout
+	default:
+		return crawllog.StatusUnexpectedError
+	}
+}
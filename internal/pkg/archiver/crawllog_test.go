@@ -0,0 +1,36 @@
+package archiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/internetarchive/Zeno/internal/pkg/log/crawllog"
+)
+
+func TestFetchErrorStatus(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{&net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}, crawllog.StatusDomainLookupFailed},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), crawllog.StatusTimeout},
+		{&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, crawllog.StatusTimeout},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, crawllog.StatusConnectFailed},
+		{&net.OpError{Op: "dial", Err: syscall.EHOSTUNREACH}, crawllog.StatusConnectFailed},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, crawllog.StatusConnectionLost},
+		{io.ErrUnexpectedEOF, crawllog.StatusConnectionLost},
+		{errors.New("something else"), crawllog.StatusUnexpectedError},
+	}
+
+	for _, tt := range tests {
+		if got := fetchErrorStatus(tt.err); got != tt.expected {
+			t.Errorf("%v: expected %d, got %d", tt.err, tt.expected, got)
+		}
+	}
+}
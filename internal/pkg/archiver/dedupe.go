@@ -0,0 +1,105 @@
+package archiver
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CorentinB/warc"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/cdxj"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/dedupe"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+var globalDedupeIndex *dedupe.Index
+
+// startDedupeIndex opens the persistent dedupe index of the job and seeds it with the CDX files given in
+// the configuration and the WARC files of the previous sessions. The WARC writer looks up the payloads in it.
+func startDedupeIndex() error {
+	index, err := dedupe.Open(path.Join(config.Get().JobPath, "dedupe"))
+	if err != nil {
+		return err
+	}
+
+	for _, seed := range config.Get().WARCDedupeIndexSeeds {
+		if done, err := index.IsDone(seed); err != nil || done {
+			continue
+		}
+
+		imported, err := index.ImportCDX(seed)
+		if err != nil {
+			index.Close()
+			return err
+		}
+
+		index.MarkDone(seed)
+		logger.Info("dedupe index seeded", "cdx", seed, "captures", imported)
+	}
+
+	globalDedupeIndex = index
+
+	// The WARC files closed before a crash or written with an older version aren't in the index yet
+	warcs, err := filepath.Glob(filepath.Join(config.Get().JobPath, "warcs", "*.warc.gz"))
+	if err != nil {
+		logger.Error("unable to list the WARC files", "err", err.Error(), "func", "archiver.startDedupeIndex")
+	}
+
+	for _, warcPath := range warcs {
+		if done, err := index.IsDone(filepath.Base(warcPath)); err != nil || done {
+			continue
+		}
+
+		lines, err := cdxj.ReadFile(warcPath)
+		if err != nil {
+			logger.Warn("unable to read WARC file", "warc", warcPath, "err", err.Error(), "func", "archiver.startDedupeIndex")
+			continue
+		}
+
+		if err := addToDedupeIndex(warcPath, lines); err != nil {
+			logger.Warn("unable to add WARC file to the dedupe index", "warc", warcPath, "err", err.Error(), "func", "archiver.startDedupeIndex")
+		}
+	}
+
+	return nil
+}
+
+// lookupRevisit is the dedupe lookup of the WARC writer: a payload in the index is written as a revisit record
+// referring to its original capture, whatever the URI it was captured at
+func lookupRevisit(digest, _ string) (warc.Revisit, bool) {
+	capture, found, err := globalDedupeIndex.Lookup(digest)
+	if err != nil {
+		logger.Error("unable to look up the dedupe index", "digest", digest, "err", err.Error(), "func", "archiver.lookupRevisit")
+		return warc.Revisit{}, false
+	}
+
+	if !found {
+		return warc.Revisit{}, false
+	}
+
+	return warc.Revisit{
+		ResponseUUID: strings.TrimSuffix(strings.TrimPrefix(capture.RecordID, "<urn:uuid:"), ">"),
+		TargetURI:    capture.URI,
+		Date:         capture.Date.UTC().Format(time.RFC3339),
+		Size:         int(capture.Length),
+	}, true
+}
+
+// addToDedupeIndex adds the captures of the WARC file, read with cdxj.ReadFile, to the dedupe index
+func addToDedupeIndex(warcPath string, lines []cdxj.Line) error {
+	if err := globalDedupeIndex.AddLines(lines); err != nil {
+		return err
+	}
+
+	return globalDedupeIndex.MarkDone(filepath.Base(warcPath))
+}
+
+// stopDedupeIndex closes the dedupe index, once the WARC files are indexed
+func stopDedupeIndex() {
+	if globalDedupeIndex != nil {
+		if err := globalDedupeIndex.Close(); err != nil {
+			logger.Error("unable to close the dedupe index", "err", err.Error(), "func", "archiver.stopDedupeIndex")
+		}
+		globalDedupeIndex = nil
+	}
+}
@@ -0,0 +1,146 @@
+package dedupe
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/cdxj"
+)
+
+func openTestIndex(t *testing.T) *Index {
+	t.Helper()
+
+	index, err := Open(filepath.Join(t.TempDir(), "dedupe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	return index
+}
+
+func TestIndex(t *testing.T) {
+	index := openTestIndex(t)
+
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := index.AddLines([]cdxj.Line{
+		{URL: "https://example.com/a", Timestamp: "20240101000000", Digest: "AAAA", RecordID: "<urn:uuid:1>", Mime: "text/html", Status: "200", Length: 100},
+		// Same payload at another URI, the first capture stays the original
+		{URL: "https://example.com/b", Timestamp: "20240102000000", Digest: "AAAA", RecordID: "<urn:uuid:2>", Mime: "text/html", Status: "200", Length: 100},
+		{URL: "https://example.com/c", Timestamp: "20240103000000", Digest: "AAAA", Mime: "warc/revisit", Status: "200", Length: 50},
+		// The payload of the URI changed
+		{URL: "https://example.com/a", Timestamp: "20240104000000", Digest: "BBBB", RecordID: "<urn:uuid:3>", Mime: "text/html", Status: "200", Length: 120},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capture, found, err := index.Lookup("AAAA")
+	if err != nil || !found {
+		t.Fatalf("expected the digest to be found, got %v, %v", found, err)
+	}
+
+	if capture.URI != "https://example.com/a" || !capture.Date.Equal(first) || capture.RecordID != "<urn:uuid:1>" {
+		t.Errorf("expected the first capture, got %+v", capture)
+	}
+
+	tests := []struct {
+		uri            string
+		expectedDigest string
+		expectedURI    string
+	}{
+		{"https://example.com/a", "BBBB", "https://example.com/a"},
+		{"https://example.com/b", "AAAA", "https://example.com/a"},
+		{"https://example.com/c", "AAAA", "https://example.com/a"},
+		{"https://example.com/d", "", ""},
+	}
+
+	for _, tt := range tests {
+		digest, capture, found, err := index.LookupURI(tt.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found != (tt.expectedDigest != "") || digest != tt.expectedDigest || capture.URI != tt.expectedURI {
+			t.Errorf("%s: expected %q from %q, got %q from %q (found %v)", tt.uri, tt.expectedDigest, tt.expectedURI, digest, capture.URI, found)
+		}
+	}
+
+	if done, _ := index.IsDone("ZENO-00001.warc.gz"); done {
+		t.Error("expected the file not to be done")
+	}
+	index.MarkDone("ZENO-00001.warc.gz")
+	if done, _ := index.IsDone("ZENO-00001.warc.gz"); !done {
+		t.Error("expected the file to be done")
+	}
+}
+
+func TestImportCDX(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"job.cdxj": `com,example)/a 20240101000000 {"url":"https://example.com/a","mime":"text/html","status":"200","digest":"AAAA","length":"100","offset":"0","filename":"a.warc.gz"}
+com,example)/b 20240102000000 {"url":"https://example.com/b","mime":"warc/revisit","status":"200","digest":"AAAA","length":"50","offset":"100","filename":"a.warc.gz"}
+`,
+		"job.cdx": ` CDX N b a m s k r M S V g
+com,example)/c 20240103000000 https://example.com/c image/png 200 sha1:CCCC - - 300 0 b.warc.gz
+`,
+		"legacy.cdx": `com,example)/d 20240104000000 https://example.com/d text/css 200 DDDD - 400 b.warc.gz
+`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Gzipped CDX files are read too
+	gzFile, err := os.Create(filepath.Join(dir, "job.cdx.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(gzFile)
+	io.WriteString(gz, files["job.cdx"])
+	gz.Close()
+	gzFile.Close()
+
+	index := openTestIndex(t)
+
+	for name, expected := range map[string]int{"job.cdxj": 2, "job.cdx": 1, "legacy.cdx": 1, "job.cdx.gz": 1} {
+		imported, err := index.ImportCDX(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if imported != expected {
+			t.Errorf("%s: expected %d lines imported, got %d", name, expected, imported)
+		}
+	}
+
+	for uri, expectedDigest := range map[string]string{
+		"https://example.com/a": "AAAA",
+		"https://example.com/b": "AAAA",
+		"https://example.com/c": "CCCC",
+		"https://example.com/d": "DDDD",
+	} {
+		digest, capture, found, err := index.LookupURI(uri)
+		if err != nil || !found || digest != expectedDigest {
+			t.Errorf("%s: expected %q, got %q (found %v, err %v)", uri, expectedDigest, digest, found, err)
+		}
+
+		if capture.Date.IsZero() {
+			t.Errorf("%s: expected the capture date", uri)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "invalid.cdx"), []byte("a b c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.ImportCDX(filepath.Join(dir, "invalid.cdx")); err == nil {
+		t.Error("expected an error on an unknown CDX format")
+	}
+}
@@ -0,0 +1,171 @@
+package dedupe
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Legends of the CDX files without a " CDX ..." header line, by number of fields
+var defaultLegends = map[int]string{
+	11: "N b a m s k r M S V g",
+	9:  "N b a m s k r V g",
+}
+
+// ImportCDX adds the captures listed in the CDX or CDXJ file, optionally gzipped, to the index.
+// It returns the number of lines imported.
+func (i *Index) ImportCDX(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	imported, err := ReadCDX(reader, func(digest string, capture Capture, revisit bool) error {
+		if revisit {
+			return i.AddRevisit(capture.URI, digest)
+		}
+		return i.Add(digest, capture)
+	})
+	if err != nil {
+		return imported, fmt.Errorf("%s:%w", path, err)
+	}
+
+	return imported, nil
+}
+
+// ReadCDX calls fn with the payload digest and the capture of each line of the CDX or CDXJ input,
+// revisit being true for the revisit records. It returns the number of lines read.
+func ReadCDX(reader io.Reader, fn func(digest string, capture Capture, revisit bool) error) (int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var (
+		legend []string
+		read   int
+	)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, " CDX ") {
+			legend = strings.Fields(line)[1:]
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		var (
+			capture  Capture
+			digest   string
+			revisit  bool
+			parseErr error
+		)
+
+		if fields := strings.SplitN(line, " ", 3); len(fields) == 3 && strings.HasPrefix(fields[2], "{") {
+			digest, capture, revisit, parseErr = parseCDXJLine(fields)
+		} else {
+			digest, capture, revisit, parseErr = parseCDXLine(line, legend)
+		}
+
+		if parseErr != nil {
+			return read, fmt.Errorf("%d: %w", lineNumber, parseErr)
+		}
+
+		if err := fn(digest, capture, revisit); err != nil {
+			return read, err
+		}
+
+		read++
+	}
+
+	return read, scanner.Err()
+}
+
+// parseCDXJLine parses the "<key> <timestamp> <JSON block>" fields of a CDXJ line
+func parseCDXJLine(fields []string) (digest string, capture Capture, revisit bool, err error) {
+	var block struct {
+		URL    string `json:"url"`
+		Mime   string `json:"mime"`
+		Status string `json:"status"`
+		Digest string `json:"digest"`
+		Length string `json:"length"`
+	}
+
+	if err := json.Unmarshal([]byte(fields[2]), &block); err != nil {
+		return "", capture, false, err
+	}
+
+	capture.Date, err = time.Parse("20060102150405", fields[1])
+	if err != nil {
+		return "", capture, false, err
+	}
+
+	capture.URI = block.URL
+	capture.Mime = block.Mime
+	capture.Status = block.Status
+	capture.Length, _ = strconv.ParseInt(block.Length, 10, 64)
+
+	return strings.TrimPrefix(block.Digest, "sha1:"), capture, block.Mime == "warc/revisit", nil
+}
+
+// parseCDXLine parses a line of a space-separated CDX file with the given legend, e.g. N b a m s k r M S V g
+func parseCDXLine(line string, legend []string) (digest string, capture Capture, revisit bool, err error) {
+	fields := strings.Fields(line)
+
+	if legend == nil {
+		defaultLegend, ok := defaultLegends[len(fields)]
+		if !ok {
+			return "", capture, false, fmt.Errorf("unknown CDX format with %d fields", len(fields))
+		}
+		legend = strings.Fields(defaultLegend)
+	}
+
+	if len(fields) != len(legend) {
+		return "", capture, false, fmt.Errorf("expected %d fields, got %d", len(legend), len(fields))
+	}
+
+	for index, field := range fields {
+		if field == "-" {
+			continue
+		}
+
+		switch legend[index] {
+		case "a":
+			capture.URI = field
+		case "b":
+			capture.Date, err = time.Parse("20060102150405", field)
+			if err != nil {
+				return "", capture, false, err
+			}
+		case "m":
+			capture.Mime = field
+		case "s":
+			capture.Status = field
+		case "k":
+			digest = strings.TrimPrefix(field, "sha1:")
+		case "S":
+			capture.Length, _ = strconv.ParseInt(field, 10, 64)
+		}
+	}
+
+	return digest, capture, capture.Mime == "warc/revisit", nil
+}
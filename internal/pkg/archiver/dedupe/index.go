@@ -0,0 +1,150 @@
+// Package dedupe persists the payload digests of the captured responses, so that revisit records
+// are written for the payloads captured in the previous sessions of a job or in related jobs.
+//
+// The index maps the digests to their original capture and the URIs to the digest of their last capture.
+// The WARC writer looks up the digests of the payloads it captures in it.
+package dedupe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/cdxj"
+	"github.com/philippgille/gokv/leveldb"
+)
+
+// Prefixes of the keys of the index
+const (
+	digestPrefix = "d:" // digest -> original Capture
+	uriPrefix    = "u:" // URI -> digest of its last capture
+	donePrefix   = "f:" // name of an imported file -> true
+)
+
+// Capture is the original capture of a payload, the one the revisit records refer to
+type Capture struct {
+	URI      string    `json:"uri"`
+	Date     time.Time `json:"date"`
+	RecordID string    `json:"record_id,omitempty"`
+	Mime     string    `json:"mime,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Length   int64     `json:"length,omitempty"`
+}
+
+// Index is a persistent index of the captured payloads
+type Index struct {
+	mu sync.Mutex
+	db leveldb.Store
+}
+
+// Open opens the index stored at path, creating it if needed
+func Open(path string) (*Index, error) {
+	db, err := leveldb.NewStore(leveldb.Options{Path: path})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Index{db: db}, nil
+}
+
+// Close closes the index
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// Add records the capture of the payload. The first capture of a payload is kept as its original capture,
+// while the URI is always mapped to the digest of its last capture.
+func (i *Index) Add(digest string, capture Capture) error {
+	if digest == "" || capture.URI == "" {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var original Capture
+	found, err := i.db.Get(digestPrefix+digest, &original)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		if err := i.db.Set(digestPrefix+digest, capture); err != nil {
+			return err
+		}
+	}
+
+	return i.db.Set(uriPrefix+capture.URI, digest)
+}
+
+// AddRevisit maps the URI to the digest of a revisit record, its original capture being recorded elsewhere
+func (i *Index) AddRevisit(uri, digest string) error {
+	if digest == "" || uri == "" {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.db.Set(uriPrefix+uri, digest)
+}
+
+// AddLines records the captures of the CDXJ lines of a WARC file, as read by cdxj.ReadFile
+func (i *Index) AddLines(lines []cdxj.Line) error {
+	for _, line := range lines {
+		if line.Mime == "warc/revisit" {
+			if err := i.AddRevisit(line.URL, line.Digest); err != nil {
+				return err
+			}
+			continue
+		}
+
+		date, err := time.Parse("20060102150405", line.Timestamp)
+		if err != nil {
+			continue
+		}
+
+		err = i.Add(line.Digest, Capture{
+			URI:      line.URL,
+			Date:     date,
+			RecordID: line.RecordID,
+			Mime:     line.Mime,
+			Status:   line.Status,
+			Length:   line.Length,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the original capture of the payload
+func (i *Index) Lookup(digest string) (capture Capture, found bool, err error) {
+	found, err = i.db.Get(digestPrefix+digest, &capture)
+	return capture, found, err
+}
+
+// LookupURI returns the digest of the last capture of the URI and the original capture of that payload
+func (i *Index) LookupURI(uri string) (digest string, capture Capture, found bool, err error) {
+	found, err = i.db.Get(uriPrefix+uri, &digest)
+	if err != nil || !found {
+		return "", capture, false, err
+	}
+
+	capture, found, err = i.Lookup(digest)
+
+	return digest, capture, found, err
+}
+
+// IsDone returns true if the file (a WARC file or a CDX file) was already added to the index
+func (i *Index) IsDone(name string) (bool, error) {
+	var done bool
+	_, err := i.db.Get(donePrefix+name, &done)
+	return done, err
+}
+
+// MarkDone records that the file was added to the index
+func (i *Index) MarkDone(name string) error {
+	return i.db.Set(donePrefix+name, true)
+}
@@ -0,0 +1,44 @@
+package archiver
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CorentinB/warc"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/dedupe"
+)
+
+func TestLookupRevisit(t *testing.T) {
+	index, err := dedupe.Open(filepath.Join(t.TempDir(), "dedupe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+
+	globalDedupeIndex = index
+	defer func() { globalDedupeIndex = nil }()
+
+	index.Add("AAAA", dedupe.Capture{
+		URI:      "https://example.com/a",
+		Date:     time.Date(2024, 1, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600)),
+		RecordID: "<urn:uuid:6f2c0c4e-0d43-4c8e-9d3e-2f2b1c2a3b4c>",
+		Length:   100,
+	})
+
+	// Another URI with the same payload refers to the original capture
+	revisit, found := lookupRevisit("AAAA", "https://example.org/b")
+	expected := warc.Revisit{
+		ResponseUUID: "6f2c0c4e-0d43-4c8e-9d3e-2f2b1c2a3b4c",
+		TargetURI:    "https://example.com/a",
+		Date:         "2024-01-01T11:30:00Z",
+		Size:         100,
+	}
+	if !found || revisit != expected {
+		t.Errorf("expected %+v, got %+v (found: %v)", expected, revisit, found)
+	}
+
+	if _, found := lookupRevisit("BBBB", "https://example.com/a"); found {
+		t.Error("expected an unknown digest not to be found")
+	}
+}
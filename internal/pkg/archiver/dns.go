@@ -0,0 +1,445 @@
+package archiver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CorentinB/warc"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/miekg/dns"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxDNSServers is the number of DNS servers tried, in order, before giving up on a resolution
+const maxDNSServers = 4
+
+// resolver resolves the hosts for the WARC-writing clients. Each resolution is written as a dns: response record,
+// like Heritrix does, and cached for the TTL of the answers (at most --dns-cache-ttl). The hosts that don't resolve
+// (e.g. NXDOMAIN) are cached for --dns-negative-cache-ttl: their URLs fail without being requested again.
+// The hosts of the pin file are connected to the pinned IP, without any resolution.
+type resolver struct {
+	client      *dns.Client
+	tcpClient   *dns.Client
+	servers     []string
+	pins        map[string]net.IP
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	cacheSize   int
+	disableIPv4 bool
+	disableIPv6 bool
+	writeRecord func(host, server string, answers []dns.RR, resolvedAt time.Time)
+
+	lookups singleflight.Group
+	mu      sync.Mutex
+	cache   map[string]dnsEntry
+	nowFunc func() time.Time
+}
+
+// dnsEntry is a cached resolution, IP is nil if the host doesn't resolve
+type dnsEntry struct {
+	IP      net.IP
+	expires time.Time
+}
+
+var globalResolver *resolver
+
+// newResolver returns the resolver configured by the --dns flags
+func newResolver() (*resolver, error) {
+	servers, err := dnsServers(config.Get().DNSServers)
+	if err != nil {
+		return nil, err
+	}
+
+	var pins map[string]net.IP
+	if config.Get().DNSPinFile != "" {
+		pins, err = loadDNSPins(config.Get().DNSPinFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &resolver{
+		client:      &dns.Client{Net: "udp", Timeout: config.Get().DNSTimeout},
+		tcpClient:   &dns.Client{Net: "tcp", Timeout: config.Get().DNSTimeout},
+		servers:     servers,
+		pins:        pins,
+		maxTTL:      config.Get().DNSCacheTTL,
+		negativeTTL: config.Get().DNSNegativeCacheTTL,
+		cacheSize:   config.Get().DNSCacheSize,
+		disableIPv4: config.Get().DisableIPv4,
+		disableIPv6: config.Get().DisableIPv6,
+		writeRecord: writeDNSRecord,
+		cache:       make(map[string]dnsEntry),
+		nowFunc:     time.Now,
+	}, nil
+}
+
+// dnsServers returns the addresses of the DNS servers, the ones of /etc/resolv.conf if none are given
+func dnsServers(servers []string) ([]string, error) {
+	port := "53"
+	if len(servers) == 0 {
+		resolvConf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the DNS servers of /etc/resolv.conf: %w", err)
+		}
+		servers, port = resolvConf.Servers, resolvConf.Port
+	}
+
+	if len(servers) == 0 {
+		return nil, errors.New("no DNS servers configured")
+	}
+
+	addresses := make([]string, 0, len(servers))
+	for _, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err == nil {
+			addresses = append(addresses, server)
+			continue
+		}
+
+		if net.ParseIP(server) == nil {
+			return nil, fmt.Errorf("invalid DNS server %q, must be an IP address", server)
+		}
+		addresses = append(addresses, net.JoinHostPort(server, port))
+	}
+
+	return addresses, nil
+}
+
+// loadDNSPins reads a pin file in the format of /etc/hosts: an IP followed by the hosts pinned to it on each line,
+// # starting a comment
+func loadDNSPins(path string) (map[string]net.IP, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the DNS pin file: %w", err)
+	}
+	defer file.Close()
+
+	pins := make(map[string]net.IP)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		IP := net.ParseIP(fields[0])
+		if IP == nil || len(fields) < 2 {
+			return nil, fmt.Errorf("DNS pin file line %d: expected an IP followed by the hosts pinned to it", lineNumber)
+		}
+
+		for _, host := range fields[1:] {
+			pins[strings.ToLower(host)] = IP
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the DNS pin file: %w", err)
+	}
+
+	return pins, nil
+}
+
+// resolve returns the IP to connect to for the host, it's the warc.ResolveFunc of the WARC-writing clients.
+// The error is a *net.DNSError, with IsNotFound set if the host doesn't resolve.
+func (r *resolver) resolve(ctx context.Context, host string) (net.IP, error) {
+	host = strings.ToLower(host)
+
+	if IP, ok := r.pins[host]; ok {
+		return IP, nil
+	}
+
+	if entry, ok := r.cached(host); ok {
+		if entry.IP == nil {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return entry.IP, nil
+	}
+
+	// Concurrent connections to the same host share the resolution, and its dns: record. The resolution isn't
+	// canceled with the connection that started it, the queries are bounded by the DNS timeout instead.
+	lookup := r.lookups.DoChan(host, func() (any, error) {
+		return r.lookup(context.WithoutCancel(ctx), host)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, &net.DNSError{Err: ctx.Err().Error(), Name: host, IsTimeout: errors.Is(ctx.Err(), context.DeadlineExceeded)}
+	case result := <-lookup:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		return result.Val.(net.IP), nil
+	}
+}
+
+// unresolvable returns true if the host recently failed to resolve
+func (r *resolver) unresolvable(host string) bool {
+	if !r.negativeCacheEnabled() {
+		return false
+	}
+
+	entry, ok := r.cached(hostname(host))
+	return ok && entry.IP == nil
+}
+
+// negativeCacheEnabled returns true if the hosts that don't resolve are cached
+func (r *resolver) negativeCacheEnabled() bool {
+	return r != nil && r.negativeTTL > 0 && r.cacheSize > 0
+}
+
+// lookup queries the DNS servers in order until one of them answers
+func (r *resolver) lookup(ctx context.Context, host string) (net.IP, error) {
+	var err error
+	for _, server := range r.servers[:min(len(r.servers), maxDNSServers)] {
+		var IP net.IP
+		IP, err = r.queryWithTimeout(ctx, host, server)
+
+		var dnsErr *net.DNSError
+		if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) || ctx.Err() != nil {
+			return IP, err
+		}
+	}
+
+	return nil, err
+}
+
+// queryWithTimeout is query bounded by the DNS timeout, if any
+func (r *resolver) queryWithTimeout(ctx context.Context, host, server string) (net.IP, error) {
+	if r.client.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.client.Timeout)
+		defer cancel()
+	}
+
+	return r.query(ctx, host, server)
+}
+
+// query resolves the host with the server, writing the dns: record and caching the result
+func (r *resolver) query(ctx context.Context, host, server string) (net.IP, error) {
+	var types []uint16
+	if !r.disableIPv4 {
+		types = append(types, dns.TypeA)
+	}
+	if !r.disableIPv6 {
+		types = append(types, dns.TypeAAAA)
+	}
+
+	resolvedAt := r.nowFunc()
+
+	var (
+		wg        sync.WaitGroup
+		responses = make([]*dns.Msg, len(types))
+		errs      = make([]error, len(types))
+	)
+	for i, recordType := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.Fqdn(host), recordType)
+			responses[i], errs[i] = r.exchange(ctx, msg, server)
+		}()
+	}
+	wg.Wait()
+
+	var (
+		answers  []dns.RR
+		IPv4     net.IP
+		IPv6     net.IP
+		ttl      = r.maxTTL
+		notFound = 0
+		rcode    = dns.RcodeSuccess
+	)
+	for i, response := range responses {
+		if errs[i] != nil {
+			continue
+		}
+
+		if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+			rcode = response.Rcode
+			continue
+		}
+
+		found := false
+		for _, answer := range response.Answer {
+			// Both queries get the CNAME records, if any
+			if !slices.ContainsFunc(answers, func(rr dns.RR) bool { return dns.IsDuplicate(rr, answer) }) {
+				answers = append(answers, answer)
+			}
+			ttl = min(ttl, time.Duration(answer.Header().Ttl)*time.Second)
+
+			switch record := answer.(type) {
+			case *dns.A:
+				found = true
+				if IPv4 == nil {
+					IPv4 = record.A
+				}
+			case *dns.AAAA:
+				found = true
+				if IPv6 == nil {
+					IPv6 = record.AAAA
+				}
+			}
+		}
+
+		// NXDOMAIN, or no address of the type (possibly behind a CNAME)
+		if !found {
+			notFound++
+		}
+	}
+
+	if len(answers) > 0 {
+		r.writeRecord(host, server, answers, resolvedAt)
+	}
+
+	// IPv6 is preferred, like the dialer of the warc library does, --disable-ipv6 turns it off
+	IP := IPv6
+	if IP == nil {
+		IP = IPv4
+	}
+
+	switch {
+	case IP != nil:
+		r.store(host, IP, ttl)
+		return IP, nil
+	case notFound == len(types):
+		r.store(host, nil, r.negativeTTL)
+		return nil, &net.DNSError{Err: "no such host", Name: host, Server: server, IsNotFound: true}
+	case rcode != dns.RcodeSuccess:
+		return nil, &net.DNSError{Err: "server replied " + dns.RcodeToString[rcode], Name: host, Server: server, IsTemporary: true}
+	}
+
+	// Every query failed
+	err := errors.Join(errs...)
+	var netErr net.Error
+	timeout := errors.As(err, &netErr) && netErr.Timeout()
+
+	return nil, &net.DNSError{Err: err.Error(), Name: host, Server: server, IsTimeout: timeout, IsTemporary: true}
+}
+
+// exchange sends the query to the server over UDP, and again over TCP if the answer is truncated
+func (r *resolver) exchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	response, _, err := r.client.ExchangeContext(ctx, msg, server)
+	if err != nil || !response.Truncated {
+		return response, err
+	}
+
+	response, _, err = r.tcpClient.ExchangeContext(ctx, msg, server)
+	return response, err
+}
+
+// cached returns the cached resolution of the host, if it didn't expire
+func (r *resolver) cached(host string) (dnsEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.cache[host]
+	if !ok {
+		return dnsEntry{}, false
+	}
+
+	if !r.nowFunc().Before(entry.expires) {
+		delete(r.cache, host)
+		return dnsEntry{}, false
+	}
+
+	return entry, true
+}
+
+// store caches the resolution of the host for the TTL, evicting entries if the cache is full
+func (r *resolver) store(host string, IP net.IP, ttl time.Duration) {
+	if ttl <= 0 || r.cacheSize <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.nowFunc()
+
+	if len(r.cache) >= r.cacheSize {
+		for h, entry := range r.cache {
+			if !now.Before(entry.expires) {
+				delete(r.cache, h)
+			}
+		}
+
+		// Still full, drop arbitrary entries
+		for h := range r.cache {
+			if len(r.cache) < r.cacheSize {
+				break
+			}
+			delete(r.cache, h)
+		}
+	}
+
+	r.cache[host] = dnsEntry{IP: IP, expires: now.Add(ttl)}
+}
+
+// writeDNSRecord writes the answers of a resolution as a dns: response record, like Heritrix does: the content
+// is the 14-digit date of the resolution followed by the records, and WARC-IP-Address is the DNS server
+func writeDNSRecord(host, server string, answers []dns.RR, resolvedAt time.Time) {
+	recordsMu.RLock()
+	defer recordsMu.RUnlock()
+
+	if globalArchiver == nil || globalArchiver.Client == nil || clientsClosed {
+		return
+	}
+
+	var content strings.Builder
+	content.WriteString(resolvedAt.UTC().Format("20060102150405") + "\n")
+	for _, answer := range answers {
+		content.WriteString(answer.String() + "\n")
+	}
+
+	serverIP, _, err := net.SplitHostPort(server)
+	if err != nil {
+		serverIP = server
+	}
+
+	record := warc.NewRecord(config.Get().WARCTempDir, config.Get().WARCOnDisk)
+	record.Header.Set("WARC-Type", "response")
+	record.Header.Set("WARC-Target-URI", "dns:"+host)
+	record.Header.Set("WARC-IP-Address", serverIP)
+	record.Header.Set("Content-Type", "text/dns")
+
+	if _, err := record.Content.Write([]byte(content.String())); err != nil {
+		record.Content.Close()
+		logger.Error("unable to write the DNS record", "host", host, "err", err.Error())
+		return
+	}
+
+	batch := warc.NewRecordBatch(nil)
+	batch.CaptureTime = resolvedAt.UTC().Format(time.RFC3339Nano)
+	batch.Records = append(batch.Records, record)
+
+	globalArchiver.Client.WARCWriter <- batch
+}
+
+// isUnresolvable returns true if the error means the host has no address (e.g. NXDOMAIN),
+// as opposed to a transient resolution failure like a timeout
+func isUnresolvable(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// hostname returns the lowercased host without its port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
@@ -0,0 +1,260 @@
+package archiver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startDNSServer starts a local DNS server, over UDP and TCP, answering a.example with an A record and a 60s TTL,
+// cname.example with a CNAME to a.example, dual.example with A and AAAA records, truncated.example with an A record
+// only over TCP, and NXDOMAIN otherwise. It returns its address and the number of queries received.
+func startDNSServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := new(atomic.Int32)
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		queries.Add(1)
+
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+
+		question := req.Question[0]
+		switch question.Name {
+		case "cname.example.":
+			cname, _ := dns.NewRR("cname.example. 30 IN CNAME a.example.")
+			resp.Answer = append(resp.Answer, cname)
+			fallthrough
+		case "a.example.":
+			if question.Qtype == dns.TypeA {
+				a, _ := dns.NewRR("a.example. 60 IN A 192.0.2.1")
+				resp.Answer = append(resp.Answer, a)
+			}
+		case "dual.example.":
+			record := "dual.example. 60 IN A 192.0.2.3"
+			if question.Qtype == dns.TypeAAAA {
+				record = "dual.example. 60 IN AAAA 2001:db8::3"
+			}
+			rr, _ := dns.NewRR(record)
+			resp.Answer = append(resp.Answer, rr)
+		case "truncated.example.":
+			if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+				resp.Truncated = true
+			} else if question.Qtype == dns.TypeA {
+				a, _ := dns.NewRR("truncated.example. 60 IN A 192.0.2.4")
+				resp.Answer = append(resp.Answer, a)
+			}
+		default:
+			resp.Rcode = dns.RcodeNameError
+		}
+
+		w.WriteMsg(resp)
+	})
+
+	for _, server := range []*dns.Server{{PacketConn: conn, Handler: handler}, {Listener: listener, Handler: handler}} {
+		go server.ActivateAndServe()
+		t.Cleanup(func() { server.Shutdown() })
+	}
+
+	return conn.LocalAddr().String(), queries
+}
+
+func TestResolver(t *testing.T) {
+	address, queries := startDNSServer(t)
+
+	now := time.Now()
+	var records []string
+	r := &resolver{
+		client:      &dns.Client{Net: "udp", Timeout: time.Second},
+		tcpClient:   &dns.Client{Net: "tcp", Timeout: time.Second},
+		servers:     []string{address},
+		pins:        map[string]net.IP{"pinned.example": net.ParseIP("192.0.2.9")},
+		maxTTL:      5 * time.Minute,
+		negativeTTL: 10 * time.Minute,
+		cacheSize:   10,
+		writeRecord: func(host, server string, answers []dns.RR, resolvedAt time.Time) {
+			for _, answer := range answers {
+				records = append(records, host+" "+answer.String())
+			}
+		},
+		cache:   make(map[string]dnsEntry),
+		nowFunc: func() time.Time { return now },
+	}
+
+	IP, err := r.resolve(context.Background(), "A.example")
+	if err != nil || !IP.Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected 192.0.2.1, got %v, %v", IP, err)
+	}
+
+	if len(records) != 1 || records[0] != "a.example a.example.\t60\tIN\tA\t192.0.2.1" {
+		t.Fatalf("expected the A record to be written, got %q", records)
+	}
+
+	// Cached for the TTL of the answer
+	queried := queries.Load()
+	now = now.Add(59 * time.Second)
+	if _, err := r.resolve(context.Background(), "a.example"); err != nil || queries.Load() != queried {
+		t.Errorf("expected the resolution to be cached, got %v and %d new queries", err, queries.Load()-queried)
+	}
+
+	now = now.Add(time.Second)
+	if _, err := r.resolve(context.Background(), "a.example"); err != nil || queries.Load() == queried {
+		t.Errorf("expected the resolution to expire with its TTL, got %v", err)
+	}
+
+	// The CNAME is written once, and its shorter TTL applies
+	records = nil
+	if IP, err := r.resolve(context.Background(), "cname.example"); err != nil || !IP.Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("expected 192.0.2.1, got %v, %v", IP, err)
+	}
+	if len(records) != 2 {
+		t.Errorf("expected the CNAME and A records to be written, got %q", records)
+	}
+	if entry, ok := r.cached("cname.example"); !ok || entry.expires != now.Add(30*time.Second) {
+		t.Errorf("expected the resolution to be cached for 30s, got %v", entry.expires.Sub(now))
+	}
+
+	// IPv6 is preferred
+	if IP, err := r.resolve(context.Background(), "dual.example"); err != nil || !IP.Equal(net.ParseIP("2001:db8::3")) {
+		t.Errorf("expected 2001:db8::3, got %v, %v", IP, err)
+	}
+
+	// Truncated answers are retried over TCP
+	if IP, err := r.resolve(context.Background(), "truncated.example"); err != nil || !IP.Equal(net.ParseIP("192.0.2.4")) {
+		t.Errorf("expected 192.0.2.4, got %v, %v", IP, err)
+	}
+
+	// A canceled caller returns, without canceling the resolution it started
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.resolve(canceled, "dual.example.com"); err == nil {
+		t.Error("expected an error for a canceled context")
+	}
+	if _, err := r.resolve(context.Background(), "dual.example.com"); !isUnresolvable(err) {
+		t.Errorf("expected the resolution to complete, got %v", err)
+	}
+
+	// Negative caching
+	records = nil
+	_, err = r.resolve(context.Background(), "nx.example")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("expected a not found DNS error, got %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no record for NXDOMAIN, got %q", records)
+	}
+	if !r.unresolvable("NX.example:443") || r.unresolvable("a.example") {
+		t.Error("expected only nx.example to be cached as unresolvable")
+	}
+
+	queried = queries.Load()
+	if _, err := r.resolve(context.Background(), "nx.example"); !isUnresolvable(err) || queries.Load() != queried {
+		t.Errorf("expected the negative cache to answer, got %v and %d new queries", err, queries.Load()-queried)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if r.unresolvable("nx.example") {
+		t.Error("expected the negative entry to expire")
+	}
+
+	// Pinned hosts aren't resolved
+	queried = queries.Load()
+	if IP, err := r.resolve(context.Background(), "pinned.example"); err != nil || !IP.Equal(net.ParseIP("192.0.2.9")) || queries.Load() != queried {
+		t.Errorf("expected the pinned IP without query, got %v, %v", IP, err)
+	}
+
+	var unset *resolver
+	if unset.negativeCacheEnabled() || unset.unresolvable("nx.example") {
+		t.Error("expected a nil resolver to have no negative cache")
+	}
+}
+
+func TestResolverUnreachableServer(t *testing.T) {
+	// Nothing answers on the port
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := conn.LocalAddr().String()
+	conn.Close()
+
+	r := &resolver{
+		client:      &dns.Client{Net: "udp", Timeout: 100 * time.Millisecond},
+		tcpClient:   &dns.Client{Net: "tcp", Timeout: 100 * time.Millisecond},
+		servers:     []string{address},
+		maxTTL:      time.Minute,
+		negativeTTL: time.Minute,
+		cacheSize:   10,
+		writeRecord: func(string, string, []dns.RR, time.Time) {},
+		cache:       make(map[string]dnsEntry),
+		nowFunc:     time.Now,
+	}
+
+	_, err = r.resolve(context.Background(), "a.example")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || dnsErr.IsNotFound {
+		t.Fatalf("expected a DNS error other than not found, got %v", err)
+	}
+
+	if r.unresolvable("a.example") {
+		t.Error("expected a failed resolution not to be cached")
+	}
+}
+
+func TestLoadDNSPins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pins")
+	os.WriteFile(path, []byte("# pinned hosts\n192.0.2.1 example.com WWW.example.com\n\n2001:db8::1 example.org # comment\n"), 0644)
+
+	pins, err := loadDNSPins(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pins) != 3 || !pins["www.example.com"].Equal(net.ParseIP("192.0.2.1")) || !pins["example.org"].Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("unexpected pins: %v", pins)
+	}
+
+	os.WriteFile(path, []byte("example.com 192.0.2.1\n"), 0644)
+	if _, err := loadDNSPins(path); err == nil {
+		t.Error("expected an error when the IP isn't first")
+	}
+}
+
+func TestIsUnresolvable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"not found", &url.Error{Op: "Get", URL: "https://example.invalid/", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}, true},
+		{"DNS timeout", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, false},
+		{"connection refused", errors.New("dial tcp 127.0.0.1:80: connect: connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUnresolvable(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -5,4 +5,14 @@ import "errors"
 var (
 	// ErrArchiverAlreadyInitialized is the error returned when the preprocess is already initialized
 	ErrArchiverAlreadyInitialized = errors.New("archiver already initialized")
+	// ErrArchiverNotInitialized is the error returned when the archiver is used before being started
+	ErrArchiverNotInitialized = errors.New("archiver not initialized")
+	// ErrArchiverStopped is the error returned when writing records after the archiver is stopped
+	ErrArchiverStopped = errors.New("archiver stopped")
+	// ErrRateLimitDisabled is the error returned when the rate limiting is used while disabled
+	ErrRateLimitDisabled = errors.New("rate limiting is disabled")
+	// ErrNoRateLimitRules is the error returned when reloading the rate limit rules while no rules file is configured
+	ErrNoRateLimitRules = errors.New("no rate limit rules file configured")
+	// ErrNoProxies is the error returned when querying the proxies while none is configured
+	ErrNoProxies = errors.New("no proxies configured")
 )
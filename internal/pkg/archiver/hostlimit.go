@@ -0,0 +1,53 @@
+package archiver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/ratelimiter"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/internal/pkg/stats"
+)
+
+var globalConcurrencyLimiter *ratelimiter.ConcurrencyLimiter
+
+// startConcurrencyLimiter limits the number of requests in flight per host to --max-concurrent-per-host,
+// overridden by the max_concurrency of the rate limit rules
+func startConcurrencyLimiter(ctx context.Context, wg *sync.WaitGroup) {
+	var overrides func(host string) int
+	if globalBucketManager != nil {
+		overrides = func(host string) int {
+			return globalBucketManager.Limits(host).MaxConcurrency
+		}
+	}
+
+	globalConcurrencyLimiter = ratelimiter.NewConcurrencyLimiter(config.Get().MaxConcurrentPerHost, overrides)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				hosts, waiting := globalConcurrencyLimiter.Saturated()
+				stats.HostsSaturatedSet(int64(hosts), int64(waiting))
+			}
+		}
+	}()
+}
+
+// HostsConcurrency returns the hosts with requests in flight, the most saturated first
+func HostsConcurrency() ([]ratelimiter.HostConcurrency, error) {
+	if globalConcurrencyLimiter == nil {
+		return nil, ErrArchiverNotInitialized
+	}
+
+	return globalConcurrencyLimiter.Hosts(), nil
+}
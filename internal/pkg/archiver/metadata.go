@@ -0,0 +1,52 @@
+package archiver
+
+import (
+	"sync"
+
+	"github.com/CorentinB/warc"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+var (
+	// recordsMu prevents the records written outside of the requests from being sent to closed WARC writers
+	recordsMu     sync.RWMutex
+	clientsClosed bool
+)
+
+// WriteMetadataRecord writes a WARC metadata record about the given URL, the content being application/warc-fields lines.
+// concurrentTo is the WARC-Record-ID (a UUID) of the record the metadata is about, if any. It returns once the record is written.
+func WriteMetadataRecord(targetURI, concurrentTo, content string) error {
+	recordsMu.RLock()
+	defer recordsMu.RUnlock()
+
+	if globalArchiver == nil || globalArchiver.Client == nil {
+		return ErrArchiverNotInitialized
+	}
+
+	if clientsClosed {
+		return ErrArchiverStopped
+	}
+
+	record := warc.NewRecord(config.Get().WARCTempDir, config.Get().WARCOnDisk)
+	record.Header.Set("WARC-Type", "metadata")
+	record.Header.Set("WARC-Target-URI", targetURI)
+	record.Header.Set("Content-Type", "application/warc-fields")
+	if concurrentTo != "" {
+		record.Header.Set("WARC-Concurrent-To", "<urn:uuid:"+concurrentTo+">")
+	}
+
+	if _, err := record.Content.Write([]byte(content)); err != nil {
+		record.Content.Close()
+		return err
+	}
+
+	batch := warc.NewRecordBatch(make(chan struct{}, 1))
+	batch.Records = append(batch.Records, record)
+
+	globalArchiver.Client.WARCWriter <- batch
+
+	// Wait for the record to be written
+	<-batch.FeedbackChan
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package archiver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/CorentinB/warc"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/proxypool"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+var globalProxyRouter *proxypool.Router
+
+// startProxyRouter builds the proxy router from the --proxy flags and the proxy rules file, if any
+func startProxyRouter() error {
+	var rules *proxypool.Rules
+	if config.Get().ProxyRules != "" {
+		var err error
+		rules, err = proxypool.LoadRules(config.Get().ProxyRules)
+		if err != nil {
+			return err
+		}
+	}
+
+	router, err := proxypool.NewRouter(config.Get().Proxy, proxypool.Selection(config.Get().ProxySelection), rules, proxypool.Settings{
+		MaxFailures:   config.Get().ProxyMaxFailures,
+		EjectDuration: config.Get().ProxyEjectDuration,
+	})
+	if err != nil {
+		return err
+	}
+
+	globalProxyRouter = router
+
+	return nil
+}
+
+// clientFor returns the WARC-writing client to use to request the host, and the URL of its proxy if any
+func clientFor(host string) (client *warc.CustomHTTPClient, proxyURL string, err error) {
+	if globalProxyRouter == nil {
+		return globalArchiver.Client, "", nil
+	}
+
+	proxyURL, err = globalProxyRouter.Select(host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if proxyURL == "" {
+		return globalArchiver.Client, "", nil
+	}
+
+	return globalArchiver.ProxyClients[proxyURL], proxyURL, nil
+}
+
+// reportProxyResult updates the state of the proxy used for a request, ejecting it after repeated connection failures
+func reportProxyResult(proxyURL string, err error) {
+	if proxyURL == "" || globalProxyRouter == nil {
+		return
+	}
+
+	if err == nil || !isProxyConnectionFailure(err) {
+		globalProxyRouter.ReportSuccess(proxyURL)
+		return
+	}
+
+	if globalProxyRouter.ReportFailure(proxyURL) {
+		logger.Warn("proxy ejected after repeated connection failures", "proxy", proxypool.Redact(proxyURL), "duration", config.Get().ProxyEjectDuration.String(), "err", err.Error())
+	}
+}
+
+// isProxyConnectionFailure returns true if the proxy itself couldn't be reached: the dial to the proxy or the
+// CONNECT or SOCKS5 handshake failed. Errors of the proxy reaching the target, that are reported once the
+// handshake went through, don't count.
+func isProxyConnectionFailure(err error) bool {
+	var proxyErr *proxypool.ProxyError
+	if errors.As(err, &proxyErr) {
+		return true
+	}
+
+	// The SOCKS5 dialer wraps both the dial to the proxy and the handshake errors
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "socks connect" {
+		return false
+	}
+
+	var dialErr *net.OpError
+	if errors.As(opErr.Err, &dialErr) && dialErr.Op == "dial" {
+		return true
+	}
+
+	// The reply of the proxy to the CONNECT command, the target couldn't be reached (host unreachable, connection
+	// refused...), or the request timed out or was canceled, possibly while the proxy was reaching the target
+	var netErr net.Error
+	if strings.HasPrefix(opErr.Err.Error(), "unknown error ") || errors.Is(opErr.Err, context.Canceled) ||
+		(errors.As(opErr.Err, &netErr) && netErr.Timeout()) {
+		return false
+	}
+
+	// I/O, protocol and authentication errors of the handshake
+	return true
+}
+
+// ProxiesStatus returns the state of the proxies
+func ProxiesStatus() ([]proxypool.ProxyStatus, error) {
+	if globalProxyRouter == nil {
+		return nil, ErrArchiverNotInitialized
+	}
+
+	status := globalProxyRouter.Status()
+	if len(status) == 0 {
+		return nil, ErrNoProxies
+	}
+
+	return status, nil
+}
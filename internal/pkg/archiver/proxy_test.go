@@ -0,0 +1,187 @@
+package archiver
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// serve accepts connections on a local listener and handles them with handler until the test ends
+func serve(t *testing.T, handler func(net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handler(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// closedAddress returns the address of a port nothing listens on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	return address
+}
+
+// socks5Proxy is a SOCKS5 proxy without authentication that dials the requested target
+func socks5Proxy(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	// Greeting: version, number of methods, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(reader, make([]byte, header[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{0x05, 0x00})
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return
+	}
+
+	var host string
+	switch request[3] {
+	case 0x01:
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(reader, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		length, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(reader, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(reader, port); err != nil {
+		return
+	}
+
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+	if err != nil {
+		// Host unreachable
+		conn.Write([]byte{0x05, 0x04, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+	go io.Copy(upstream, reader)
+	io.Copy(conn, upstream)
+}
+
+// connectProxy is an HTTP proxy handling CONNECT, requiring the user:pass credentials
+func connectProxy(conn net.Conn) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+
+	if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+		conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		return
+	}
+
+	upstream, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		return
+	}
+	defer upstream.Close()
+
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestIsProxyConnectionFailure(t *testing.T) {
+	liveTarget := serve(t, func(net.Conn) {})
+	deadTarget := closedAddress(t)
+
+	socksProxy := serve(t, socks5Proxy)
+	httpProxy := serve(t, connectProxy)
+	hangingUpProxy := serve(t, func(net.Conn) {})
+	deadProxy := closedAddress(t)
+
+	tests := []struct {
+		name     string
+		proxyURL string
+		target   string
+		expected bool
+	}{
+		{"SOCKS5 proxy, dead target", "socks5://" + socksProxy, deadTarget, false},
+		{"SOCKS5 proxy down", "socks5://" + deadProxy, liveTarget, true},
+		{"SOCKS5 proxy hanging up", "socks5://" + hangingUpProxy, liveTarget, true},
+		{"HTTP proxy, dead target", "http://user:pass@" + httpProxy, deadTarget, false},
+		{"HTTP proxy, wrong credentials", "http://user:wrong@" + httpProxy, liveTarget, true},
+		{"HTTP proxy down", "http://" + deadProxy, liveTarget, true},
+		{"HTTP proxy hanging up", "http://" + hangingUpProxy, liveTarget, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.proxyURL)
+			dialer, err := proxy.FromURL(u, &net.Dialer{Timeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", tt.target)
+			if err == nil {
+				conn.Close()
+				t.Fatal("expected the dial to fail")
+			}
+
+			if got := isProxyConnectionFailure(err); got != tt.expected {
+				t.Errorf("expected %v, got %v for error %v", tt.expected, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package proxypool
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// connectTimeout bounds the CONNECT exchange with an HTTP proxy when the context has no deadline
+const connectTimeout = 30 * time.Second
+
+func init() {
+	// golang.org/x/net/proxy only knows about SOCKS5, HTTP proxies are used through CONNECT tunnels
+	proxy.RegisterDialerType("http", newConnectDialer)
+}
+
+// ProxyError is returned by the HTTP CONNECT dialer when the proxy itself couldn't be reached or refused the
+// tunnel, as opposed to the proxy failing to reach the target
+type ProxyError struct {
+	Proxy string
+	Err   error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("proxy %s: %s", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// connectDialer opens tunnels through an HTTP proxy with the CONNECT method
+type connectDialer struct {
+	proxyURL *url.URL
+	forward  proxy.Dialer
+}
+
+func newConnectDialer(proxyURL *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &connectDialer{proxyURL: proxyURL, forward: forward}, nil
+}
+
+func (d *connectDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	proxyAddress := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		proxyAddress = net.JoinHostPort(d.proxyURL.Hostname(), "80")
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if forward, ok := d.forward.(proxy.ContextDialer); ok {
+		conn, err = forward.DialContext(ctx, "tcp", proxyAddress)
+	} else {
+		conn, err = d.forward.Dial("tcp", proxyAddress)
+	}
+	if err != nil {
+		return nil, &ProxyError{Proxy: d.proxyURL.Redacted(), Err: err}
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(connectTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.proxyURL.User != nil {
+		password, _ := d.proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, &ProxyError{Proxy: d.proxyURL.Redacted(), Err: err}
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		// The proxy only replies once it reached the target, a timeout may be the target's
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, fmt.Errorf("proxy %s: CONNECT to %s: %w", d.proxyURL.Redacted(), address, err)
+		}
+		return nil, &ProxyError{Proxy: d.proxyURL.Redacted(), Err: err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		conn.Close()
+		return nil, &ProxyError{Proxy: d.proxyURL.Redacted(), Err: fmt.Errorf("CONNECT to %s failed: %s", address, resp.Status)}
+	}
+
+	// Any other status is the proxy failing to reach the target (502, 504...)
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: CONNECT to %s failed: %s", d.proxyURL.Redacted(), address, resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn reads the bytes buffered while reading the CONNECT response before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,116 @@
+package archiver
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/ratelimiter"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+// rateLimitRulesCheckInterval is how often the rules file is checked for changes
+const rateLimitRulesCheckInterval = 10 * time.Second
+
+var (
+	rateLimitRulesMu      sync.Mutex
+	rateLimitRulesModTime time.Time
+)
+
+// ReloadRateLimitRules reads the rate limit rules file again and applies it to the bucket manager.
+// If the file is invalid, the rules currently applied are kept.
+func ReloadRateLimitRules() error {
+	if globalBucketManager == nil {
+		return ErrRateLimitDisabled
+	}
+
+	rulesPath := config.Get().RateLimitRules
+	if rulesPath == "" {
+		return ErrNoRateLimitRules
+	}
+
+	rateLimitRulesMu.Lock()
+	defer rateLimitRulesMu.Unlock()
+
+	info, err := os.Stat(rulesPath)
+	if err != nil {
+		return err
+	}
+
+	rules, err := ratelimiter.LoadRules(rulesPath)
+	if err != nil {
+		return err
+	}
+
+	if err := globalBucketManager.SetRules(rules); err != nil {
+		return err
+	}
+
+	rateLimitRulesModTime = info.ModTime()
+
+	// The concurrency limits may have been raised
+	if globalConcurrencyLimiter != nil {
+		globalConcurrencyLimiter.Refresh()
+	}
+
+	logger.Info("rate limit rules loaded", "path", rulesPath, "rules", len(rules))
+
+	return nil
+}
+
+// RateLimitRules returns the rate limit rules currently applied
+func RateLimitRules() ([]ratelimiter.Rule, error) {
+	if globalBucketManager == nil {
+		return nil, ErrRateLimitDisabled
+	}
+
+	return globalBucketManager.Rules(), nil
+}
+
+// RateLimitHostLimits returns the rate limits applied to the given host
+func RateLimitHostLimits(host string) (ratelimiter.HostLimits, error) {
+	if globalBucketManager == nil {
+		return ratelimiter.HostLimits{}, ErrRateLimitDisabled
+	}
+
+	return globalBucketManager.Limits(host), nil
+}
+
+// watchRateLimitRules reloads the rules file when it is modified
+func watchRateLimitRules(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(rateLimitRulesCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(config.Get().RateLimitRules)
+			if err != nil {
+				logger.Warn("unable to check the rate limit rules file", "err", err.Error())
+				continue
+			}
+
+			rateLimitRulesMu.Lock()
+			modified := !info.ModTime().Equal(rateLimitRulesModTime)
+			rateLimitRulesMu.Unlock()
+
+			if !modified {
+				continue
+			}
+
+			if err := ReloadRateLimitRules(); err != nil {
+				logger.Error("unable to reload the rate limit rules, keeping the current ones", "err", err.Error())
+
+				// Don't retry until the file is modified again
+				rateLimitRulesMu.Lock()
+				rateLimitRulesModTime = info.ModTime()
+				rateLimitRulesMu.Unlock()
+			}
+		}
+	}
+}
@@ -33,6 +33,18 @@ func (tb *tokenBucket) adjustOnFailure(statusCode int) {
 	}
 }
 
+// penalize stops refilling tokens for the given duration, unless a longer penalty is already applied.
+func (tb *tokenBucket) penalize(duration time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	penaltyUntil := tb.nowFunc().Add(duration)
+	if penaltyUntil.After(tb.penaltyUntil) {
+		tb.penaltyUntil = penaltyUntil
+	}
+	tb.tokens = 0
+}
+
 // onSuccess should be called when a request succeeds.
 // It gradually restores the refill rate and resets the failure count.
 func (tb *tokenBucket) onSuccess() {
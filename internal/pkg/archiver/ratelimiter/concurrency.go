@@ -0,0 +1,150 @@
+package ratelimiter
+
+import (
+	"sort"
+	"sync"
+)
+
+// hostSlots counts the requests in flight to a host.
+type hostSlots struct {
+	inFlight int
+	waiting  int
+	cond     *sync.Cond
+}
+
+// HostConcurrency describes the requests in flight to a host.
+type HostConcurrency struct {
+	Host           string `json:"host"`
+	InFlight       int    `json:"in_flight"`
+	Waiting        int    `json:"waiting"`
+	MaxConcurrency int    `json:"max_concurrency"`
+}
+
+// ConcurrencyLimiter limits the number of requests in flight per host.
+type ConcurrencyLimiter struct {
+	mu         sync.Mutex
+	slots      map[string]*hostSlots
+	defaultMax int                   // default maximum number of requests in flight per host, 0 means no limit
+	overrides  func(host string) int // per-host maximum, 0 means the default applies
+	saturated  int                   // number of hosts with requests waiting for a slot
+	waiting    int                   // total number of requests waiting for a slot
+}
+
+// NewConcurrencyLimiter creates a new ConcurrencyLimiter allowing defaultMax requests in flight per host (0 means no limit).
+// overrides, if not nil, returns the maximum for a given host, or 0 to use the default.
+func NewConcurrencyLimiter(defaultMax int, overrides func(host string) int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		slots:      make(map[string]*hostSlots),
+		defaultMax: defaultMax,
+		overrides:  overrides,
+	}
+}
+
+// maxFor returns the maximum number of requests in flight to the host, 0 means no limit.
+func (cl *ConcurrencyLimiter) maxFor(host string) int {
+	if cl.overrides != nil {
+		if maxInFlight := cl.overrides(host); maxInFlight > 0 {
+			return maxInFlight
+		}
+	}
+
+	return cl.defaultMax
+}
+
+// Acquire blocks until a request to the given host can be made without exceeding its concurrency limit.
+// The returned function must be called once the request is done.
+func (cl *ConcurrencyLimiter) Acquire(host string) (release func()) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	slots, ok := cl.slots[host]
+	if !ok {
+		if cl.maxFor(host) <= 0 {
+			return func() {}
+		}
+
+		slots = &hostSlots{cond: sync.NewCond(&cl.mu)}
+		cl.slots[host] = slots
+	}
+
+	for {
+		maxInFlight := cl.maxFor(host)
+		if maxInFlight <= 0 || slots.inFlight < maxInFlight {
+			break
+		}
+
+		if slots.waiting == 0 {
+			cl.saturated++
+		}
+		slots.waiting++
+		cl.waiting++
+
+		slots.cond.Wait()
+
+		slots.waiting--
+		cl.waiting--
+		if slots.waiting == 0 {
+			cl.saturated--
+		}
+	}
+
+	slots.inFlight++
+
+	return func() {
+		cl.mu.Lock()
+		defer cl.mu.Unlock()
+
+		slots.inFlight--
+		if slots.inFlight == 0 && slots.waiting == 0 {
+			delete(cl.slots, host)
+			return
+		}
+		slots.cond.Signal()
+	}
+}
+
+// Refresh wakes up the requests waiting for a slot, to be called when the limits may have been raised.
+func (cl *ConcurrencyLimiter) Refresh() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	for _, slots := range cl.slots {
+		slots.cond.Broadcast()
+	}
+}
+
+// Saturated returns the number of hosts with requests waiting for a slot, and the total number of waiting requests.
+func (cl *ConcurrencyLimiter) Saturated() (hosts, waiting int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.saturated, cl.waiting
+}
+
+// Hosts returns the hosts with requests in flight, the most saturated first.
+func (cl *ConcurrencyLimiter) Hosts() []HostConcurrency {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	hosts := make([]HostConcurrency, 0, len(cl.slots))
+	for host, slots := range cl.slots {
+		hosts = append(hosts, HostConcurrency{
+			Host:           host,
+			InFlight:       slots.inFlight,
+			Waiting:        slots.waiting,
+			MaxConcurrency: cl.maxFor(host),
+		})
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Waiting != hosts[j].Waiting {
+			return hosts[i].Waiting > hosts[j].Waiting
+		}
+		if hosts[i].InFlight != hosts[j].InFlight {
+			return hosts[i].InFlight > hosts[j].InFlight
+		}
+		return hosts[i].Host < hosts[j].Host
+	})
+
+	return hosts
+}
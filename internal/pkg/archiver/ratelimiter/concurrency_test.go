@@ -0,0 +1,110 @@
+package ratelimiter
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultMax  int
+		overrides   func(host string) int
+		expectedMax int32
+	}{
+		{"default limit", 3, nil, 3},
+		{"override", 3, func(host string) int { return 1 }, 1},
+		{"override without default", 0, func(host string) int { return 2 }, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := NewConcurrencyLimiter(tt.defaultMax, tt.overrides)
+
+			var (
+				inFlight    atomic.Int32
+				maxInFlight atomic.Int32
+				wg          sync.WaitGroup
+			)
+
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					release := cl.Acquire("example.com")
+					defer release()
+
+					current := inFlight.Add(1)
+					for {
+						previous := maxInFlight.Load()
+						if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					inFlight.Add(-1)
+				}()
+			}
+
+			wg.Wait()
+
+			if maxInFlight.Load() != tt.expectedMax {
+				t.Errorf("expected at most %d requests in flight, got %d", tt.expectedMax, maxInFlight.Load())
+			}
+
+			if hosts := cl.Hosts(); len(hosts) != 0 {
+				t.Errorf("expected the slots to be released, got %v", hosts)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiterStats(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, nil)
+
+	release := cl.Acquire("example.com")
+
+	acquired := make(chan func())
+	for range 2 {
+		go func() { acquired <- cl.Acquire("example.com") }()
+	}
+
+	// Wait for the two requests to wait for a slot
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, waiting := cl.Saturated(); waiting == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected 2 requests waiting for a slot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if hosts, _ := cl.Saturated(); hosts != 1 {
+		t.Errorf("expected 1 saturated host, got %d", hosts)
+	}
+
+	hosts := cl.Hosts()
+	if len(hosts) != 1 || hosts[0] != (HostConcurrency{Host: "example.com", InFlight: 1, Waiting: 2, MaxConcurrency: 1}) {
+		t.Errorf("unexpected hosts: %+v", hosts)
+	}
+
+	release()
+	(<-acquired)()
+	(<-acquired)()
+
+	if hosts, waiting := cl.Saturated(); hosts != 0 || waiting != 0 {
+		t.Errorf("expected no saturated host, got %d hosts and %d waiting requests", hosts, waiting)
+	}
+
+	// Hosts without a limit are not tracked
+	unlimited := NewConcurrencyLimiter(0, nil)
+	defer unlimited.Acquire("example.com")()
+	if hosts := unlimited.Hosts(); len(hosts) != 0 {
+		t.Errorf("expected hosts without limit not to be tracked, got %v", hosts)
+	}
+}
@@ -14,10 +14,19 @@ type managedBucket struct {
 	lastAccess time.Time // last time the bucket was accessed
 }
 
+// hostLimit is a robots.txt Crawl-delay limit for a host, applied on top of the defaults and the rules.
+type hostLimit struct {
+	capacity   float64
+	refillRate float64
+}
+
 // BucketManager manages token buckets keyed by host.
 type BucketManager struct {
 	mu          sync.Mutex
 	buckets     map[string]*managedBucket
+	hostLimits  map[string]hostLimit // per-host limits that survive bucket eviction
+	rules       []compiledRule       // per-host overrides of the defaults, first match wins
+	rawRules    []Rule
 	maxBuckets  int           // maximum number of buckets allowed
 	capacity    float64       // default bucket capacity
 	refillRate  float64       // default refill rate for new buckets
@@ -32,6 +41,7 @@ type BucketManager struct {
 func NewBucketManager(ctx context.Context, maxBuckets int, capacity, refillRate float64, cleanupFreq time.Duration) *BucketManager {
 	bm := &BucketManager{
 		buckets:     make(map[string]*managedBucket),
+		hostLimits:  make(map[string]hostLimit),
 		maxBuckets:  maxBuckets,
 		capacity:    capacity,
 		refillRate:  refillRate,
@@ -67,7 +77,10 @@ func (bm *BucketManager) getBucket(host string) *managedBucket {
 		bm.evictLFU()
 	}
 
-	tb := newTokenBucket(bm.capacity, bm.refillRate)
+	limits := bm.limitsFor(host)
+
+	tb := newTokenBucket(limits.Capacity, limits.RefillRate)
+	tb.minDelay = limits.MinDelay
 	mb := &managedBucket{
 		bucket:     tb,
 		usageCount: 1,
@@ -77,6 +90,69 @@ func (bm *BucketManager) getBucket(host string) *managedBucket {
 	return mb
 }
 
+// limitsFor returns the limits of the host: the defaults, overridden by the first matching rule,
+// and restricted by the robots.txt Crawl-delay if any. Must be called with the lock held.
+func (bm *BucketManager) limitsFor(host string) HostLimits {
+	limits := HostLimits{
+		Capacity:   bm.capacity,
+		RefillRate: bm.refillRate,
+	}
+
+	if len(bm.rules) > 0 {
+		name := hostname(host)
+		for i := range bm.rules {
+			if bm.rules[i].match(name) {
+				bm.rules[i].apply(&limits)
+				break
+			}
+		}
+	}
+
+	if limit, ok := bm.hostLimits[host]; ok {
+		limits.Capacity = math.Min(limits.Capacity, limit.capacity)
+		limits.RefillRate = math.Min(limits.RefillRate, limit.refillRate)
+	}
+
+	return limits
+}
+
+// Limits returns the limits applied to the given host.
+func (bm *BucketManager) Limits(host string) HostLimits {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	return bm.limitsFor(host)
+}
+
+// SetRules replaces the per-host rules, the limits of the existing buckets are updated.
+func (bm *BucketManager) SetRules(rules []Rule) error {
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return err
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	bm.rules = compiled
+	bm.rawRules = rules
+
+	for host, mb := range bm.buckets {
+		limits := bm.limitsFor(host)
+		mb.bucket.setLimits(limits.Capacity, limits.RefillRate, limits.MinDelay)
+	}
+
+	return nil
+}
+
+// Rules returns the per-host rules currently applied.
+func (bm *BucketManager) Rules() []Rule {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	return append([]Rule(nil), bm.rawRules...)
+}
+
 // evictLFU removes the bucket with the lowest usageCount.
 func (bm *BucketManager) evictLFU() {
 	var lfuKey string
@@ -106,12 +182,38 @@ func (bm *BucketManager) AdjustOnFailure(host string, statusCode int) {
 	mb.bucket.adjustOnFailure(statusCode)
 }
 
+// Penalize stops giving tokens for the given host for the given duration, e.g. as asked by a Retry-After header.
+func (bm *BucketManager) Penalize(host string, duration time.Duration) {
+	mb := bm.getBucket(host)
+	mb.bucket.penalize(duration)
+}
+
 // OnSuccess signals success for the given host's bucket.
 func (bm *BucketManager) OnSuccess(host string) {
 	mb := bm.getBucket(host)
 	mb.bucket.onSuccess()
 }
 
+// SetCrawlDelay limits the given host to one request per delay, as asked by a robots.txt Crawl-delay.
+// The limit is only applied if it is stricter than the host's refill rate, and is kept if the bucket is evicted.
+func (bm *BucketManager) SetCrawlDelay(host string, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	delete(bm.hostLimits, host)
+	refillRate := math.Min(1/delay.Seconds(), bm.limitsFor(host).RefillRate)
+	bm.hostLimits[host] = hostLimit{capacity: 1, refillRate: refillRate}
+
+	if mb, ok := bm.buckets[host]; ok {
+		limits := bm.limitsFor(host)
+		mb.bucket.setLimits(limits.Capacity, limits.RefillRate, limits.MinDelay)
+	}
+}
+
 // cleanupLoop runs periodically to remove buckets that haven't been accessed
 // for a period longer than cleanupFreq.
 func (bm *BucketManager) cleanupLoop() {
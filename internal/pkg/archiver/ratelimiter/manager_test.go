@@ -258,3 +258,42 @@ func TestConcurrentSameHost(t *testing.T) {
 		t.Errorf("expected exactly 1 bucket for host %s, got %d", host, bucketCount)
 	}
 }
+
+func TestSetCrawlDelay(t *testing.T) {
+	ctx := context.Background()
+	bm := NewBucketManager(ctx, 1, 10, 5, 1*time.Second)
+	defer bm.Close()
+
+	// Existing bucket is updated
+	bm.Wait("host1")
+	bm.SetCrawlDelay("host1", 2*time.Second)
+
+	bm.mu.Lock()
+	tb := bm.buckets["host1"].bucket
+	bm.mu.Unlock()
+
+	tb.mu.Lock()
+	if tb.capacity != 1 || tb.refillRate != 0.5 || tb.idealRate != 0.5 || tb.tokens > 1 {
+		t.Errorf("unexpected bucket after crawl delay: capacity=%f refillRate=%f idealRate=%f tokens=%f", tb.capacity, tb.refillRate, tb.idealRate, tb.tokens)
+	}
+	tb.mu.Unlock()
+
+	// A crawl delay looser than the default rate doesn't speed things up
+	bm.SetCrawlDelay("host2", 10*time.Millisecond)
+
+	// host2 evicts host1 (maxBuckets is 1), the limits must survive the eviction
+	bm.Wait("host2")
+	bm.Wait("host1")
+
+	bm.mu.Lock()
+	tb = bm.buckets["host1"].bucket
+	bm.mu.Unlock()
+
+	if tb.capacity != 1 || tb.refillRate != 0.5 {
+		t.Errorf("expected host1 limits to be kept after eviction, got capacity=%f refillRate=%f", tb.capacity, tb.refillRate)
+	}
+
+	if limit := bm.hostLimits["host2"]; limit.refillRate != 5 {
+		t.Errorf("expected host2 refill rate to be capped to the default rate, got %f", limit.refillRate)
+	}
+}
@@ -23,13 +23,15 @@ const (
 // tokenBucket implements a token bucket with penalty and recovery.
 type tokenBucket struct {
 	mu           sync.Mutex
-	tokens       float64   // current tokens available
-	capacity     float64   // maximum tokens in the bucket
-	refillRate   float64   // current tokens per second
-	idealRate    float64   // the target refill rate under good conditions
-	lastRefill   time.Time // last time the bucket was refilled
-	penaltyUntil time.Time // if set, no tokens will be refilled until this time
-	failureCount int       // consecutive failure counter for exponential backoff
+	tokens       float64       // current tokens available
+	capacity     float64       // maximum tokens in the bucket
+	refillRate   float64       // current tokens per second
+	idealRate    float64       // the target refill rate under good conditions
+	lastRefill   time.Time     // last time the bucket was refilled
+	penaltyUntil time.Time     // if set, no tokens will be refilled until this time
+	failureCount int           // consecutive failure counter for exponential backoff
+	minDelay     time.Duration // minimum time between two tokens
+	lastTake     time.Time     // last time a token was taken
 
 	// nowFunc is used to fetch the current time; it defaults to time.Now,
 	// but can be overridden for testing.
@@ -54,8 +56,9 @@ func (tb *tokenBucket) Wait() {
 	for {
 		tb.mu.Lock()
 		tb.refill()
-		if tb.tokens >= 1 {
+		if tb.tokens >= 1 && tb.nowFunc().Sub(tb.lastTake) >= tb.minDelay {
 			tb.tokens--
+			tb.lastTake = tb.nowFunc()
 			tb.mu.Unlock()
 			return
 		}
@@ -64,6 +67,19 @@ func (tb *tokenBucket) Wait() {
 	}
 }
 
+// setLimits changes the capacity, the target refill rate and the minimum delay between tokens of the bucket.
+func (tb *tokenBucket) setLimits(capacity, refillRate float64, minDelay time.Duration) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.refill()
+	tb.capacity = capacity
+	tb.idealRate = refillRate
+	tb.refillRate = math.Min(tb.refillRate, refillRate)
+	tb.tokens = math.Min(tb.tokens, capacity)
+	tb.minDelay = minDelay
+}
+
 // refill adds tokens to the bucket based on the time elapsed.
 func (tb *tokenBucket) refill() {
 	now := tb.nowFunc()
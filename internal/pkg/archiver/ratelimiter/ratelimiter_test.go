@@ -227,3 +227,34 @@ func TestOnSuccessDuringPenalty(t *testing.T) {
 		t.Errorf("expected penaltyUntil to remain %v, got %v", penaltyUntil, tb.penaltyUntil)
 	}
 }
+
+func TestPenalize(t *testing.T) {
+	now := time.Now()
+	tb := newTokenBucket(10, 10)
+	tb.nowFunc = func() time.Time { return now }
+
+	tb.penalize(30 * time.Second)
+
+	if tb.tokens != 0 || !tb.penaltyUntil.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("expected the bucket to be emptied and penalized for 30s, got %f tokens until %s", tb.tokens, tb.penaltyUntil)
+	}
+
+	// A shorter penalty doesn't shorten the current one
+	tb.penalize(time.Second)
+	if !tb.penaltyUntil.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("expected the longer penalty to be kept, got %s", tb.penaltyUntil)
+	}
+
+	// No token is given during the penalty
+	now = now.Add(20 * time.Second)
+	tb.refill()
+	if tb.tokens != 0 {
+		t.Errorf("expected no token during the penalty, got %f", tb.tokens)
+	}
+
+	now = now.Add(20 * time.Second)
+	tb.refill()
+	if tb.tokens == 0 {
+		t.Error("expected tokens after the penalty")
+	}
+}
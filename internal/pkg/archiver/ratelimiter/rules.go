@@ -0,0 +1,148 @@
+package ratelimiter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Rule overrides the rate limiting of the hosts it matches.
+// Exactly one of Host, Domain or Regex must be set, and zero values keep the defaults.
+type Rule struct {
+	// Host matches this exact host
+	Host string `json:"host,omitempty"`
+	// Domain matches this domain and all its subdomains
+	Domain string `json:"domain,omitempty"`
+	// Regex matches the hosts matching this regular expression
+	Regex string `json:"regex,omitempty"`
+
+	// Capacity is the number of requests that can be made in a burst
+	Capacity float64 `json:"capacity,omitempty"`
+	// RefillRate is the number of requests per second
+	RefillRate float64 `json:"refill_rate,omitempty"`
+	// MaxConcurrency is the maximum number of requests in flight to a host
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// MinDelay is the minimum time between two requests to a host, e.g. "5s"
+	MinDelay string `json:"min_delay,omitempty"`
+}
+
+// HostLimits are the limits applied to a host
+type HostLimits struct {
+	Capacity       float64
+	RefillRate     float64
+	MaxConcurrency int // 0 means no limit
+	MinDelay       time.Duration
+}
+
+// compiledRule is a validated Rule ready to be matched
+type compiledRule struct {
+	Rule
+	regex    *regexp.Regexp
+	minDelay time.Duration
+}
+
+// LoadRules reads a JSON rules file, a list of rules that are evaluated in order, the first matching rule wins
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rate limit rules file: %w", err)
+	}
+
+	if _, err := compileRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func compileRules(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+
+	for i, rule := range rules {
+		matchers := 0
+		for _, matcher := range []string{rule.Host, rule.Domain, rule.Regex} {
+			if matcher != "" {
+				matchers++
+			}
+		}
+		if matchers != 1 {
+			return nil, fmt.Errorf("rate limit rule %d: exactly one of host, domain or regex must be set", i)
+		}
+
+		if rule.Capacity < 0 || rule.RefillRate < 0 || rule.MaxConcurrency < 0 {
+			return nil, fmt.Errorf("rate limit rule %d: limits can't be negative", i)
+		}
+
+		c := compiledRule{Rule: rule}
+		c.Host = strings.ToLower(rule.Host)
+		c.Domain = strings.ToLower(strings.TrimPrefix(rule.Domain, "."))
+
+		if rule.Regex != "" {
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("rate limit rule %d: %w", i, err)
+			}
+			c.regex = regex
+		}
+
+		if rule.MinDelay != "" {
+			minDelay, err := time.ParseDuration(rule.MinDelay)
+			if err != nil || minDelay < 0 {
+				return nil, fmt.Errorf("rate limit rule %d: invalid min_delay %q", i, rule.MinDelay)
+			}
+			c.minDelay = minDelay
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// match returns true if the rule applies to the hostname (without port)
+func (r *compiledRule) match(hostname string) bool {
+	switch {
+	case r.Host != "":
+		return hostname == r.Host
+	case r.Domain != "":
+		return hostname == r.Domain || strings.HasSuffix(hostname, "."+r.Domain)
+	case r.regex != nil:
+		return r.regex.MatchString(hostname)
+	}
+
+	return false
+}
+
+// apply overrides the limits with the non-zero values of the rule
+func (r *compiledRule) apply(limits *HostLimits) {
+	if r.Capacity > 0 {
+		limits.Capacity = r.Capacity
+	}
+	if r.RefillRate > 0 {
+		limits.RefillRate = r.RefillRate
+	}
+	if r.MaxConcurrency > 0 {
+		limits.MaxConcurrency = r.MaxConcurrency
+	}
+	if r.minDelay > 0 {
+		limits.MinDelay = r.minDelay
+	}
+}
+
+// hostname returns the lowercased host without its port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
@@ -0,0 +1,142 @@
+package ratelimiter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectError bool
+	}{
+		{"valid", `[{"host": "example.com", "refill_rate": 50}, {"domain": "example.org", "min_delay": "5s"}, {"regex": "^cdn[0-9]+\\.example\\.net$", "max_concurrency": 2}]`, false},
+		{"empty", `[]`, false},
+		{"invalid JSON", `{"host": "example.com"}`, true},
+		{"no matcher", `[{"refill_rate": 50}]`, true},
+		{"two matchers", `[{"host": "example.com", "domain": "example.com"}]`, true},
+		{"invalid regex", `[{"regex": "("}]`, true},
+		{"invalid min_delay", `[{"host": "example.com", "min_delay": "5"}]`, true},
+		{"negative limit", `[{"host": "example.com", "capacity": -1}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesPath := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(rulesPath, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadRules(rulesPath)
+			if (err != nil) != tt.expectError {
+				t.Errorf("expected error: %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestLimitsFor(t *testing.T) {
+	bm := NewBucketManager(context.Background(), 10, 10, 5, time.Second)
+	defer bm.Close()
+
+	err := bm.SetRules([]Rule{
+		{Host: "www.example.com", Capacity: 50, RefillRate: 50},
+		{Domain: "example.com", RefillRate: 1, MaxConcurrency: 2},
+		{Regex: `^cdn[0-9]+\.example\.net$`, MinDelay: "5s"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		host     string
+		expected HostLimits
+	}{
+		{"www.example.com", HostLimits{Capacity: 50, RefillRate: 50}},
+		{"WWW.example.com:8080", HostLimits{Capacity: 50, RefillRate: 50}},
+		{"example.com", HostLimits{Capacity: 10, RefillRate: 1, MaxConcurrency: 2}},
+		{"static.example.com", HostLimits{Capacity: 10, RefillRate: 1, MaxConcurrency: 2}},
+		{"notexample.com", HostLimits{Capacity: 10, RefillRate: 5}},
+		{"cdn12.example.net", HostLimits{Capacity: 10, RefillRate: 5, MinDelay: 5 * time.Second}},
+		{"cdn.example.net", HostLimits{Capacity: 10, RefillRate: 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := bm.Limits(tt.host); got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	// A robots.txt Crawl-delay only makes the limits stricter
+	bm.SetCrawlDelay("www.example.com", 2*time.Second)
+	if got := bm.Limits("www.example.com"); got.Capacity != 1 || got.RefillRate != 0.5 {
+		t.Errorf("expected the crawl delay to apply, got %+v", got)
+	}
+}
+
+func TestSetRulesUpdatesBuckets(t *testing.T) {
+	bm := NewBucketManager(context.Background(), 10, 10, 5, time.Second)
+	defer bm.Close()
+
+	bm.Wait("example.com")
+
+	if err := bm.SetRules([]Rule{{Domain: "example.com", Capacity: 2, RefillRate: 1, MinDelay: "1s"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bm.mu.Lock()
+	tb := bm.buckets["example.com"].bucket
+	bm.mu.Unlock()
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.capacity != 2 || tb.idealRate != 1 || tb.minDelay != time.Second {
+		t.Errorf("expected the bucket limits to be updated, got capacity %f, ideal rate %f, min delay %s", tb.capacity, tb.idealRate, tb.minDelay)
+	}
+
+	// Invalid rules are rejected and the current ones are kept
+	if err := bm.SetRules([]Rule{{Regex: "("}}); err == nil {
+		t.Error("expected an error on invalid rules")
+	}
+	if len(bm.rawRules) != 1 {
+		t.Errorf("expected the previous rules to be kept, got %v", bm.rawRules)
+	}
+}
+
+func TestMinDelay(t *testing.T) {
+	now := time.Now()
+	tb := newTokenBucket(10, 10)
+	tb.minDelay = time.Second
+	tb.nowFunc = func() time.Time { return now }
+
+	tb.Wait()
+
+	// Tokens are available but the minimum delay isn't elapsed
+	done := make(chan struct{})
+	go func() {
+		tb.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("expected Wait to block until the minimum delay is elapsed")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	tb.mu.Lock()
+	now = now.Add(time.Second)
+	tb.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Wait to return once the minimum delay is elapsed")
+	}
+}
@@ -0,0 +1,225 @@
+package archiver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+// maxRetryAfterPenalty caps the penalty applied to a host's bucket from a Retry-After header
+const maxRetryAfterPenalty = 10 * time.Minute
+
+// Network error classes that can be retried
+const (
+	networkErrorAll               = "all"
+	networkErrorTimeout           = "timeout"
+	networkErrorDNS               = "dns"
+	networkErrorConnectionRefused = "connection-refused"
+	networkErrorConnectionReset   = "connection-reset"
+	networkErrorTLS               = "tls"
+)
+
+// Backoff types
+const (
+	backoffConstant    = "constant"
+	backoffLinear      = "linear"
+	backoffExponential = "exponential"
+)
+
+// retryPolicy decides which failed requests are retried and how long to wait before retrying
+type retryPolicy struct {
+	maxRetry      int
+	statusCodes   map[int]bool
+	statusClasses map[int]bool // e.g. 5 for 5xx
+	networkErrors map[string]bool
+	backoff       string
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	jitter        float64
+	maxTime       time.Duration
+}
+
+// newRetryPolicy builds the retry policy from the configuration
+func newRetryPolicy(c *config.Config) (*retryPolicy, error) {
+	policy := &retryPolicy{
+		maxRetry:      c.MaxRetry,
+		statusCodes:   make(map[int]bool),
+		statusClasses: make(map[int]bool),
+		networkErrors: make(map[string]bool),
+		backoff:       c.RetryBackoff,
+		baseDelay:     c.RetryBaseDelay,
+		maxDelay:      c.RetryMaxDelay,
+		jitter:        c.RetryJitter,
+		maxTime:       c.RetryMaxTime,
+	}
+
+	for _, code := range c.RetryStatusCodes {
+		code = strings.ToLower(strings.TrimSpace(code))
+
+		if len(code) == 3 && strings.HasSuffix(code, "xx") && code[0] >= '1' && code[0] <= '5' {
+			policy.statusClasses[int(code[0]-'0')] = true
+			continue
+		}
+
+		statusCode, err := strconv.Atoi(code)
+		if err != nil || statusCode < 100 || statusCode > 599 {
+			return nil, fmt.Errorf("invalid retry status code %q", code)
+		}
+		policy.statusCodes[statusCode] = true
+	}
+
+	for _, class := range c.RetryNetworkErrors {
+		class = strings.ToLower(strings.TrimSpace(class))
+
+		switch class {
+		case networkErrorAll, networkErrorTimeout, networkErrorDNS, networkErrorConnectionRefused, networkErrorConnectionReset, networkErrorTLS:
+			policy.networkErrors[class] = true
+		default:
+			return nil, fmt.Errorf("invalid retry network error class %q", class)
+		}
+	}
+
+	switch policy.backoff {
+	case "":
+		policy.backoff = backoffLinear
+	case backoffConstant, backoffLinear, backoffExponential:
+	default:
+		return nil, fmt.Errorf("invalid retry backoff %q, must be one of: constant, linear, exponential", policy.backoff)
+	}
+
+	if policy.jitter < 0 || policy.jitter > 1 {
+		return nil, fmt.Errorf("invalid retry jitter %f, must be between 0 and 1", policy.jitter)
+	}
+
+	if policy.baseDelay < 0 || policy.maxDelay < 0 || policy.maxTime < 0 {
+		return nil, errors.New("retry delays can't be negative")
+	}
+
+	return policy, nil
+}
+
+// retryableStatus returns true if a response with this status code should be retried
+func (p *retryPolicy) retryableStatus(statusCode int) bool {
+	return p.statusCodes[statusCode] || p.statusClasses[statusCode/100]
+}
+
+// retryableError returns true if a request that failed with this error should be retried
+func (p *retryPolicy) retryableError(err error) bool {
+	if p.networkErrors[networkErrorAll] {
+		return true
+	}
+
+	class := networkErrorClass(err)
+
+	return class != "" && p.networkErrors[class]
+}
+
+// delay returns how long to wait before the given retry (starting at 0), at least retryAfter
+func (p *retryPolicy) delay(retry int, retryAfter time.Duration) time.Duration {
+	var delay time.Duration
+
+	switch p.backoff {
+	case backoffConstant:
+		delay = p.baseDelay
+	case backoffLinear:
+		delay = p.baseDelay * time.Duration(retry)
+	case backoffExponential:
+		delay = time.Duration(float64(p.baseDelay) * math.Pow(2, float64(retry)))
+	}
+
+	if p.maxDelay > 0 && delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+
+	if p.jitter > 0 && delay > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(delay))
+	}
+
+	return max(delay, retryAfter)
+}
+
+// next returns how long to wait before the given retry (starting at 0) and whether the request can be retried at all.
+// A request isn't retried if the retries are exhausted, if waiting would exceed the maximum retry time,
+// or if the server asked (with Retry-After) to wait longer than the maximum delay.
+func (p *retryPolicy) next(retry int, retryAfter time.Duration, start time.Time) (time.Duration, bool) {
+	if retry >= p.maxRetry {
+		return 0, false
+	}
+
+	if p.maxDelay > 0 && retryAfter > p.maxDelay {
+		return 0, false
+	}
+
+	delay := p.delay(retry, retryAfter)
+
+	if p.maxTime > 0 && time.Since(start)+delay > p.maxTime {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// networkErrorClass returns the class of a request error, or an empty string if unknown
+func networkErrorClass(err error) string {
+	var (
+		dnsErr           *net.DNSError
+		netErr           net.Error
+		recordHeaderErr  tls.RecordHeaderError
+		certVerifyErr    *tls.CertificateVerificationError
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		certInvalidErr   x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return networkErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return networkErrorConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return networkErrorConnectionReset
+	case errors.As(err, &recordHeaderErr), errors.As(err, &certVerifyErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr), strings.Contains(err.Error(), "tls: "):
+		return networkErrorTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return networkErrorTimeout
+	}
+
+	return ""
+}
+
+// parseRetryAfter parses a Retry-After header value, either a number of seconds or an HTTP-date.
+// It returns 0 if the header is absent, invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+
+	return date.Sub(now)
+}
@@ -0,0 +1,238 @@
+package archiver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+)
+
+func defaultRetryConfig() *config.Config {
+	return &config.Config{
+		MaxRetry:           5,
+		RetryStatusCodes:   []string{"5xx", "403", "408", "425", "429"},
+		RetryNetworkErrors: []string{"all"},
+		RetryBackoff:       "linear",
+		RetryBaseDelay:     2 * time.Second,
+		RetryMaxDelay:      time.Minute,
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"0", 0},
+		{"-1", 0},
+		{"Wed, 01 Jan 2025 12:01:30 GMT", 90 * time.Second},
+		{"Wed, 01 Jan 2025 11:00:00 GMT", 0},
+		{"tomorrow", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewRetryPolicyInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *config.Config)
+	}{
+		{"invalid status code", func(c *config.Config) { c.RetryStatusCodes = []string{"abc"} }},
+		{"out of range status code", func(c *config.Config) { c.RetryStatusCodes = []string{"600"} }},
+		{"invalid status class", func(c *config.Config) { c.RetryStatusCodes = []string{"9xx"} }},
+		{"invalid network error class", func(c *config.Config) { c.RetryNetworkErrors = []string{"cosmic-rays"} }},
+		{"invalid backoff", func(c *config.Config) { c.RetryBackoff = "fibonacci" }},
+		{"invalid jitter", func(c *config.Config) { c.RetryJitter = 2 }},
+		{"negative delay", func(c *config.Config) { c.RetryBaseDelay = -time.Second }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultRetryConfig()
+			tt.modify(c)
+
+			if _, err := newRetryPolicy(c); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	policy, err := newRetryPolicy(defaultRetryConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		statusCode int
+		expected   bool
+	}{
+		{200, false},
+		{301, false},
+		{403, true},
+		{404, false},
+		{429, true},
+		{500, true},
+		{503, true},
+		{599, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.statusCode), func(t *testing.T) {
+			if got := policy.retryableStatus(tt.statusCode); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryableError(t *testing.T) {
+	c := defaultRetryConfig()
+	c.RetryNetworkErrors = []string{"timeout", "connection-reset"}
+
+	policy, err := newRetryPolicy(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		err           error
+		expectedClass string
+		expected      bool
+	}{
+		{"dns", &url.Error{Op: "Get", URL: "http://example.invalid", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}, networkErrorDNS, false},
+		{"connection refused", &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, networkErrorConnectionRefused, false},
+		{"connection reset", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, networkErrorConnectionReset, true},
+		{"unexpected EOF", fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), networkErrorConnectionReset, true},
+		{"timeout", &url.Error{Op: "Get", Err: os.ErrDeadlineExceeded}, networkErrorTimeout, true},
+		{"tls", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, networkErrorTLS, false},
+		{"unknown", errors.New("something else"), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if class := networkErrorClass(tt.err); class != tt.expectedClass {
+				t.Errorf("expected class %q, got %q", tt.expectedClass, class)
+			}
+
+			if got := policy.retryableError(tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	// "all" retries every error
+	allPolicy, _ := newRetryPolicy(defaultRetryConfig())
+	if !allPolicy.retryableError(errors.New("something else")) {
+		t.Error("expected all errors to be retried")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		backoff    string
+		retry      int
+		retryAfter time.Duration
+		expected   time.Duration
+	}{
+		{"constant", 0, 0, 2 * time.Second},
+		{"constant", 3, 0, 2 * time.Second},
+		{"linear", 0, 0, 0},
+		{"linear", 3, 0, 6 * time.Second},
+		{"exponential", 0, 0, 2 * time.Second},
+		{"exponential", 3, 0, 16 * time.Second},
+		{"exponential", 10, 0, time.Minute},
+		{"linear", 1, 30 * time.Second, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d %s", tt.backoff, tt.retry, tt.retryAfter), func(t *testing.T) {
+			c := defaultRetryConfig()
+			c.RetryBackoff = tt.backoff
+
+			policy, err := newRetryPolicy(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := policy.delay(tt.retry, tt.retryAfter); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	c := defaultRetryConfig()
+	c.RetryBackoff = "constant"
+	c.RetryBaseDelay = 10 * time.Second
+	c.RetryJitter = 0.5
+
+	policy, err := newRetryPolicy(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 100 {
+		if delay := policy.delay(1, 0); delay < 5*time.Second || delay > 15*time.Second {
+			t.Fatalf("expected a delay between 5s and 15s, got %s", delay)
+		}
+	}
+}
+
+func TestRetryNext(t *testing.T) {
+	c := defaultRetryConfig()
+	c.MaxRetry = 2
+	c.RetryMaxTime = 10 * time.Second
+
+	policy, err := newRetryPolicy(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		retry      int
+		retryAfter time.Duration
+		start      time.Time
+		expected   bool
+	}{
+		{"first retry", 0, 0, now, true},
+		{"retries exhausted", 2, 0, now, false},
+		{"retry after within limits", 1, 5 * time.Second, now, true},
+		{"retry after longer than the max delay", 0, 2 * time.Minute, now, false},
+		{"max retry time exceeded", 1, 0, now.Add(-9 * time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := policy.next(tt.retry, tt.retryAfter, tt.start); ok != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/CorentinB/warc"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/proxypool"
 	"github.com/internetarchive/Zeno/internal/pkg/config"
 )
 
@@ -31,6 +32,12 @@ func startWARCWriter() {
 			SizeThreshold: config.Get().WARCDedupeSize,
 		}
 	}
+	if globalDedupeIndex != nil {
+		// The persistent dedupe index is looked up after the payloads captured in this session,
+		// and before the CDX dedupe server if any
+		dedupeOptions.LocalDedupe = true
+		dedupeOptions.Lookup = lookupRevisit
+	}
 
 	// Configure WARC settings
 	WARCSettings := warc.HTTPClientSettings{
@@ -45,68 +52,67 @@ func startWARCWriter() {
 		DisableIPv4:         config.Get().DisableIPv4,
 		DisableIPv6:         config.Get().DisableIPv6,
 		IPv6AnyIP:           config.Get().IPv6AnyIP,
+		Resolve:             globalResolver.resolve,
 	}
 
-	// Instantiate WARC client
+	// Instantiate the non-proxied WARC client, always needed for the hosts requested directly
 	var err error
-	if config.Get().Proxy != "" {
-		proxiedWARCSettings := WARCSettings
-		proxiedWARCSettings.Proxy = config.Get().Proxy
-		globalArchiver.ClientWithProxy, err = warc.NewWARCWritingHTTPClient(proxiedWARCSettings)
-		if err != nil {
-			logger.Error("unable to init proxied WARC HTTP client", "err", err.Error(), "func", "archiver.startWARCWriter")
-			os.Exit(1)
-		}
-
-		go func() {
-			for err := range globalArchiver.ClientWithProxy.ErrChan {
-				logger.Error("WARC writer error", "err", err.Err.Error(), "func", err.Func)
-			}
-		}()
+	globalArchiver.Client, err = warc.NewWARCWritingHTTPClient(WARCSettings)
+	if err != nil {
+		logger.Error("unable to init WARC HTTP client", "err", err.Error(), "func", "archiver.startWARCWriter")
+		os.Exit(1)
 	}
 
-	// Even if a proxied client has been set, we want to create an non-proxied one
-	if config.Get().Proxy == "" {
-		globalArchiver.Client, err = warc.NewWARCWritingHTTPClient(WARCSettings)
+	go func() {
+		for err := range globalArchiver.Client.ErrChan {
+			logger.Error("WARC writer error", "err", err.Err.Error(), "func", err.Func)
+		}
+	}()
+
+	// Instantiate one proxied WARC client per proxy of the pools
+	globalArchiver.ProxyClients = make(map[string]*warc.CustomHTTPClient)
+	for _, proxyURL := range globalProxyRouter.Proxies() {
+		proxiedWARCSettings := WARCSettings
+		proxiedWARCSettings.Proxy = proxyURL
+		client, err := warc.NewWARCWritingHTTPClient(proxiedWARCSettings)
 		if err != nil {
-			logger.Error("unable to init WARC HTTP client", "err", err.Error(), "func", "archiver.startWARCWriter")
+			logger.Error("unable to init proxied WARC HTTP client", "proxy", proxypool.Redact(proxyURL), "err", err.Error(), "func", "archiver.startWARCWriter")
 			os.Exit(1)
 		}
 
 		go func() {
-			for err := range globalArchiver.Client.ErrChan {
-				logger.Error("WARC writer error", "err", err.Err.Error(), "func", err.Func)
+			for err := range client.ErrChan {
+				logger.Error("WARC writer error", "proxy", proxypool.Redact(proxyURL), "err", err.Err.Error(), "func", err.Func)
 			}
 		}()
+
+		globalArchiver.ProxyClients[proxyURL] = client
 	}
 
 	// Set the timeouts
 	if config.Get().HTTPTimeout > 0 {
-		if globalArchiver.Client != nil {
-			globalArchiver.Client.Timeout = time.Duration(config.Get().HTTPTimeout) * time.Second
-		}
-
-		if globalArchiver.ClientWithProxy != nil {
-			globalArchiver.ClientWithProxy.Timeout = time.Duration(config.Get().HTTPTimeout) * time.Second
+		for _, c := range GetClients() {
+			c.Timeout = time.Duration(config.Get().HTTPTimeout) * time.Second
 		}
 	}
 }
 
+// GetClients returns the WARC-writing clients, the non-proxied one first
 func GetClients() (clients []*warc.CustomHTTPClient) {
-	for _, c := range []*warc.CustomHTTPClient{globalArchiver.Client, globalArchiver.ClientWithProxy} {
-		if c != nil {
-			clients = append(clients, c)
-		}
+	if globalArchiver.Client != nil {
+		clients = append(clients, globalArchiver.Client)
+	}
+
+	for _, c := range globalArchiver.ProxyClients {
+		clients = append(clients, c)
 	}
 
 	return clients
 }
 
 func GetWARCWritingQueueSize() (total int) {
-	for _, c := range []*warc.CustomHTTPClient{globalArchiver.Client, globalArchiver.ClientWithProxy} {
-		if c != nil {
-			total += c.WaitGroup.Size()
-		}
+	for _, c := range GetClients() {
+		total += c.WaitGroup.Size()
 	}
 
 	return total
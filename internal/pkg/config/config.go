@@ -29,11 +29,25 @@ type Config struct {
 
 	// UseSeencheck exists just for convenience of not checking
 	// !DisableSeencheck in the rest of the code, to make the code clearer
-	DisableSeencheck bool `mapstructure:"disable-seencheck"`
-	UseSeencheck     bool
+	DisableSeencheck        bool `mapstructure:"disable-seencheck"`
+	UseSeencheck            bool
+	SeencheckTTL            time.Duration `mapstructure:"seencheck-ttl"`
+	SeencheckTTLRules       string        `mapstructure:"seencheck-ttl-rules"`
+	SeencheckHash           string        `mapstructure:"seencheck-hash"`
+	SeencheckFilterCapacity int           `mapstructure:"seencheck-filter-capacity"`
+	SeencheckFilterFPRate   float64       `mapstructure:"seencheck-filter-fp-rate"`
+	SeencheckFrom           []string      `mapstructure:"seencheck-from"`
+
+	// URL canonicalization
+	Canonicalize            bool     `mapstructure:"canonicalize"`
+	CanonicalizeStripParams []string `mapstructure:"canonicalize-strip-params"`
+	CanonicalizeRules       string   `mapstructure:"canonicalize-rules"`
 
 	UserAgent              string   `mapstructure:"user-agent"`
 	Cookies                string   `mapstructure:"cookies"`
+	CookiesPersist         bool     `mapstructure:"cookies-persist"`
+	HeaderProfiles         string   `mapstructure:"header-profiles"`
+	DisableDefaultProfiles bool     `mapstructure:"disable-default-header-profiles"`
 	WARCPrefix             string   `mapstructure:"warc-prefix"`
 	WARCOperator           string   `mapstructure:"warc-operator"`
 	WARCTempDir            string   `mapstructure:"warc-temp-dir"`
@@ -44,6 +58,11 @@ type Config struct {
 	WARCDedupeSize         int      `mapstructure:"warc-dedupe-size"`
 	WARCWriteAsync         bool     `mapstructure:"async-warc-write"`
 	WARCDiscardStatus      []int    `mapstructure:"warc-discard-status"`
+	WARCMetadataRecords    bool     `mapstructure:"warc-metadata-records"`
+	WARCCDXJ               bool     `mapstructure:"warc-cdxj"`
+	WARCCDXJMerge          bool     `mapstructure:"warc-cdxj-merge"`
+	WARCDedupeIndex        bool     `mapstructure:"warc-dedupe-index"`
+	WARCDedupeIndexSeeds   []string `mapstructure:"warc-dedupe-index-seed"`
 	CDXDedupeServer        string   `mapstructure:"warc-cdx-dedupe-server"`
 	CDXCookie              string   `mapstructure:"warc-cdx-cookie"`
 	HQAddress              string   `mapstructure:"hq-address"`
@@ -60,6 +79,7 @@ type Config struct {
 	ExclusionFile          []string `mapstructure:"exclusion-file"`
 	WorkersCount           int      `mapstructure:"workers"`
 	MaxConcurrentAssets    int      `mapstructure:"max-concurrent-assets"`
+	MaxConcurrentPerHost   int      `mapstructure:"max-concurrent-per-host"`
 	MaxHops                int      `mapstructure:"max-hops"`
 	MaxRedirect            int      `mapstructure:"max-redirect"`
 	MaxRetry               int      `mapstructure:"max-retry"`
@@ -76,18 +96,60 @@ type Config struct {
 	UseHQ                  bool     // Special field to check if HQ is enabled depending on the command called
 	HQRateLimitingSendBack bool     `mapstructure:"hq-rate-limiting-send-back"`
 
+	// Local queue
+	LQLease time.Duration `mapstructure:"lq-lease"`
+
+	// Retry policy
+	RetryStatusCodes   []string      `mapstructure:"retry-status-codes"`
+	RetryNetworkErrors []string      `mapstructure:"retry-network-errors"`
+	RetryBackoff       string        `mapstructure:"retry-backoff"`
+	RetryBaseDelay     time.Duration `mapstructure:"retry-base-delay"`
+	RetryMaxDelay      time.Duration `mapstructure:"retry-max-delay"`
+	RetryJitter        float64       `mapstructure:"retry-jitter"`
+	RetryMaxTime       time.Duration `mapstructure:"retry-max-time"`
+
+	// Robots.txt
+	Robots              string `mapstructure:"robots"`
+	RobotsSitemaps      bool   `mapstructure:"robots-sitemaps"`
+	RobotsMaxCrawlDelay int    `mapstructure:"robots-max-crawl-delay"`
+
 	// Network
-	Proxy         string `mapstructure:"proxy"`
-	RandomLocalIP bool   `mapstructure:"random-local-ip"`
-	DisableIPv4   bool   `mapstructure:"disable-ipv4"`
-	DisableIPv6   bool   `mapstructure:"disable-ipv6"`
-	IPv6AnyIP     bool   `mapstructure:"ipv6-anyip"`
+	Proxy              []string      `mapstructure:"proxy"`
+	ProxySelection     string        `mapstructure:"proxy-selection"`
+	ProxyRules         string        `mapstructure:"proxy-rules"`
+	ProxyMaxFailures   int           `mapstructure:"proxy-max-failures"`
+	ProxyEjectDuration time.Duration `mapstructure:"proxy-eject-duration"`
+	RandomLocalIP      bool          `mapstructure:"random-local-ip"`
+	DisableIPv4        bool          `mapstructure:"disable-ipv4"`
+	DisableIPv6        bool          `mapstructure:"disable-ipv6"`
+	IPv6AnyIP          bool          `mapstructure:"ipv6-anyip"`
+
+	// DNS
+	DNSServers          []string      `mapstructure:"dns-servers"`
+	DNSTimeout          time.Duration `mapstructure:"dns-timeout"`
+	DNSCacheTTL         time.Duration `mapstructure:"dns-cache-ttl"`
+	DNSCacheSize        int           `mapstructure:"dns-cache-size"`
+	DNSNegativeCacheTTL time.Duration `mapstructure:"dns-negative-cache-ttl"`
+	DNSPinFile          string        `mapstructure:"dns-pin-file"`
+
+	// S3 upload
+	S3Endpoint    string  `mapstructure:"s3-endpoint"`
+	S3Region      string  `mapstructure:"s3-region"`
+	S3Bucket      string  `mapstructure:"s3-bucket"`
+	S3Prefix      string  `mapstructure:"s3-prefix"`
+	S3AccessKey   string  `mapstructure:"s3-access-key"`
+	S3SecretKey   string  `mapstructure:"s3-secret-key"`
+	S3PartSize    int     `mapstructure:"s3-part-size"`
+	S3Concurrency int     `mapstructure:"s3-concurrency"`
+	S3MaxRetries  int     `mapstructure:"s3-max-retries"`
+	S3RateLimit   float64 `mapstructure:"s3-rate-limit"`
 
 	// Rate limiting
 	DisableRateLimit          bool          `mapstructure:"disable-rate-limit"`
 	RateLimitCapacity         float64       `mapstructure:"rate-limit-capacity"`
 	RateLimitRefillRate       float64       `mapstructure:"rate-limit-refill-rate"`
 	RateLimitCleanupFrequency time.Duration `mapstructure:"rate-limit-cleanup-frequency"`
+	RateLimitRules            string        `mapstructure:"rate-limit-rules"`
 
 	// Logging
 	NoStdoutLogging  bool   `mapstructure:"no-stdout-log"`
@@ -101,6 +163,10 @@ type Config struct {
 	LogFilePrefix    string `mapstructure:"log-file-prefix"`
 	LogFileRotation  string `mapstructure:"log-file-rotation"`
 
+	// Crawl log
+	NoCrawlLog       bool          `mapstructure:"no-crawl-log"`
+	CrawlLogRotation time.Duration `mapstructure:"crawl-log-rotation"`
+
 	// Profiling
 	PyroscopeAddress string `mapstructure:"pyroscope-address"`
 
@@ -227,6 +293,49 @@ func GenerateCrawlConfig() error {
 		config.CrawlMaxTimeLimit = config.CrawlTimeLimit + (config.CrawlTimeLimit / 10)
 	}
 
+	if config.LQLease < 0 {
+		return fmt.Errorf("invalid --lq-lease %s, must be positive or 0 to disable leases", config.LQLease)
+	}
+
+	// Stripping parameters requires the canonicalization
+	if len(config.CanonicalizeStripParams) > 0 || config.CanonicalizeRules != "" {
+		config.Canonicalize = true
+	}
+
+	switch config.SeencheckHash {
+	case "":
+		config.SeencheckHash = "fnv64"
+	case "fnv64", "sha256":
+	default:
+		return fmt.Errorf("invalid --seencheck-hash value %q, must be one of: fnv64, sha256", config.SeencheckHash)
+	}
+
+	if config.SeencheckFilterCapacity < 0 {
+		return fmt.Errorf("invalid --seencheck-filter-capacity %d, must be positive or 0 to disable the filter", config.SeencheckFilterCapacity)
+	}
+
+	if config.SeencheckFilterCapacity > 0 && (config.SeencheckFilterFPRate <= 0 || config.SeencheckFilterFPRate >= 1) {
+		return fmt.Errorf("invalid --seencheck-filter-fp-rate %g, must be between 0 and 1", config.SeencheckFilterFPRate)
+	}
+
+	if config.SeencheckTTL < 0 {
+		return fmt.Errorf("invalid --seencheck-ttl %s, must be positive or 0 to never crawl seen URIs again", config.SeencheckTTL)
+	}
+
+	for _, job := range config.SeencheckFrom {
+		if job == "" || job == config.Job {
+			return fmt.Errorf("invalid --seencheck-from job %q, must be the name of another job", job)
+		}
+	}
+
+	switch config.Robots {
+	case "":
+		config.Robots = "ignore"
+	case "ignore", "obey", "obey-outlinks":
+	default:
+		return fmt.Errorf("invalid --robots value %q, must be one of: obey, ignore, obey-outlinks", config.Robots)
+	}
+
 	// We exclude some hosts by default
 	config.ExcludeHosts = utils.DedupeStrings(append(config.ExcludeHosts, "archive.org", "archive-it.org"))
 
@@ -234,6 +343,31 @@ func GenerateCrawlConfig() error {
 		config.WARCTempDir = path.Join(config.JobPath, "temp")
 	}
 
+	if config.S3Endpoint != "" {
+		if config.S3Bucket == "" {
+			return fmt.Errorf("--s3-bucket is required to upload the WARC files")
+		}
+
+		// S3 rejects the parts smaller than 5MB, but the last one
+		if config.S3PartSize < 5 {
+			return fmt.Errorf("invalid --s3-part-size %d, must be at least 5MB", config.S3PartSize)
+		}
+	}
+
+	// Seeding the dedupe index requires the dedupe index
+	if len(config.WARCDedupeIndexSeeds) > 0 {
+		config.WARCDedupeIndex = true
+	}
+
+	if config.WARCDedupeIndex && config.DisableLocalDedupe {
+		return fmt.Errorf("--warc-dedupe-index can't be used with --disable-local-dedupe")
+	}
+
+	// Merging the CDXJ indexes and feeding the dedupe index require indexing the WARC files
+	if config.WARCCDXJMerge || config.WARCDedupeIndex {
+		config.WARCCDXJ = true
+	}
+
 	if config.UserAgent == "" {
 		version := utils.GetVersion()
 
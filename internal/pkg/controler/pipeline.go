@@ -1,8 +1,10 @@
 package controler
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,16 +15,25 @@ import (
 	"github.com/internetarchive/Zeno/internal/pkg/controler/watchers"
 	"github.com/internetarchive/Zeno/internal/pkg/finisher"
 	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/log/crawllog"
 	"github.com/internetarchive/Zeno/internal/pkg/postprocessor"
 	"github.com/internetarchive/Zeno/internal/pkg/preprocessor"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/cookies"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/headers"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/robots"
 	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/seencheck"
 	"github.com/internetarchive/Zeno/internal/pkg/reactor"
 	"github.com/internetarchive/Zeno/internal/pkg/source/hq"
 	"github.com/internetarchive/Zeno/internal/pkg/source/lq"
 	"github.com/internetarchive/Zeno/internal/pkg/stats"
+	"github.com/internetarchive/Zeno/internal/pkg/uploader"
 	"github.com/internetarchive/Zeno/pkg/models"
 )
 
+// sitemapsCtx is canceled when the pipeline stops, so that the preprocessor workers don't block on enqueuing sitemaps
+var sitemapsCtx, sitemapsCancel = context.WithCancel(context.Background())
+
 func startPipeline() {
 	if err := os.MkdirAll(config.Get().JobPath, 0755); err != nil {
 		fmt.Printf("can't create job directory: %s\n", err)
@@ -55,7 +66,7 @@ func startPipeline() {
 
 	// Start the API server if needed
 	if config.Get().API {
-		api.Start()
+		api.Start(stopAndExit)
 	}
 
 	// Register Zeno as Consul service if needed
@@ -75,15 +86,80 @@ func startPipeline() {
 		panic(err)
 	}
 
+	// If needed, canonicalize the keys of the local seencheck and queue, unless started to load the seeds already
+	if config.Get().Canonicalize && !config.Get().UseHQ && !canonicalizer.Enabled() {
+		settings := canonicalizer.Settings{StripParams: config.Get().CanonicalizeStripParams}
+		if config.Get().CanonicalizeRules != "" {
+			settings.Rules, err = canonicalizer.LoadRules(config.Get().CanonicalizeRules)
+			if err != nil {
+				logger.Error("unable to load canonicalization rules", "err", err.Error())
+				panic(err)
+			}
+		}
+
+		err = canonicalizer.Start(settings)
+		if err != nil {
+			logger.Error("unable to start canonicalizer", "err", err.Error())
+			panic(err)
+		}
+	}
+
 	// If needed, create the seencheck DB (only if not using HQ)
 	if config.Get().UseSeencheck && !config.Get().UseHQ {
-		err := seencheck.Start(config.Get().JobPath)
+		settings := seencheck.Settings{
+			TTL:            config.Get().SeencheckTTL,
+			Hash:           config.Get().SeencheckHash,
+			FilterCapacity: config.Get().SeencheckFilterCapacity,
+			FilterFPRate:   config.Get().SeencheckFilterFPRate,
+		}
+		for _, job := range config.Get().SeencheckFrom {
+			settings.Overlays = append(settings.Overlays, path.Join("jobs", job))
+		}
+		if config.Get().SeencheckTTLRules != "" {
+			settings.TTLRules, err = seencheck.LoadTTLRules(config.Get().SeencheckTTLRules)
+			if err != nil {
+				logger.Error("unable to load seencheck TTL rules", "err", err.Error())
+				panic(err)
+			}
+		}
+
+		err = seencheck.Start(config.Get().JobPath, settings)
 		if err != nil {
 			logger.Error("unable to start seencheck", "err", err.Error())
 			panic(err)
 		}
 	}
 
+	// If needed, load the cookie jar that will be used to build the requests
+	if config.Get().Cookies != "" || config.Get().CookiesPersist {
+		err := cookies.Start(config.Get().Cookies, config.Get().CookiesPersist)
+		if err != nil {
+			logger.Error("unable to load cookies", "err", err.Error())
+			panic(err)
+		}
+	}
+
+	// Load the header profiles applied to the requests built by the preprocessor
+	err = headers.Start(config.Get().HeaderProfiles, config.Get().DisableDefaultProfiles)
+	if err != nil {
+		logger.Error("unable to load header profiles", "err", err.Error())
+		panic(err)
+	}
+
+	// Open the crawl log, written next to the log files
+	if !config.Get().NoCrawlLog {
+		crawlLogDir := config.Get().LogFileOutputDir
+		if crawlLogDir == "" {
+			crawlLogDir = path.Join(config.Get().JobPath, "logs")
+		}
+
+		err = crawllog.Start(crawlLogDir, config.Get().CrawlLogRotation)
+		if err != nil {
+			logger.Error("unable to open the crawl log", "err", err.Error())
+			panic(err)
+		}
+	}
+
 	preprocessorOutputChan := makeStageChannel(config.Get().WorkersCount)
 	err = preprocessor.Start(reactorOutputChan, preprocessorOutputChan)
 	if err != nil {
@@ -98,6 +174,29 @@ func startPipeline() {
 		panic(err)
 	}
 
+	// Start shipping the closed WARC files to S3 if needed
+	if config.Get().S3Endpoint != "" {
+		err = uploader.Start(uploader.Settings{
+			Endpoint:     config.Get().S3Endpoint,
+			Region:       config.Get().S3Region,
+			Bucket:       config.Get().S3Bucket,
+			Prefix:       config.Get().S3Prefix,
+			AccessKey:    config.Get().S3AccessKey,
+			SecretKey:    config.Get().S3SecretKey,
+			PartSize:     int64(config.Get().S3PartSize) * 1024 * 1024,
+			Concurrency:  config.Get().S3Concurrency,
+			MaxRetries:   config.Get().S3MaxRetries,
+			RateLimit:    config.Get().S3RateLimit * 1024 * 1024,
+			WARCsDir:     path.Join(config.Get().JobPath, "warcs"),
+			ManifestPath: path.Join(config.Get().JobPath, "uploads.jsonl"),
+			WaitForIndex: config.Get().WARCCDXJ,
+		})
+		if err != nil {
+			logger.Error("error starting uploader", "err", err.Error())
+			panic(err)
+		}
+	}
+
 	// Start the WARC writing queue watcher
 	watchers.StartWatchWARCWritingQueue(1*time.Second, 2*time.Second, 250*time.Millisecond)
 
@@ -111,6 +210,20 @@ func startPipeline() {
 	finisherFinishChan := makeStageChannel(config.Get().WorkersCount)
 	finisherProduceChan := makeStageChannel(config.Get().WorkersCount)
 
+	// Start the robots.txt handling, robots.txt files are fetched by the preprocessor through the archiver's WARC-writing client
+	err = robots.Start(robots.Settings{
+		Mode:          robots.Mode(config.Get().Robots),
+		UserAgent:     config.Get().UserAgent,
+		MaxCrawlDelay: time.Duration(config.Get().RobotsMaxCrawlDelay) * time.Second,
+		Fetch:         archiver.Do,
+		OnCrawlDelay:  archiver.SetCrawlDelay,
+		OnSitemaps:    sitemapsProducer(finisherProduceChan),
+	})
+	if err != nil {
+		logger.Error("error starting robots.txt handling", "err", err.Error())
+		panic(err)
+	}
+
 	if config.Get().UseHQ {
 		logger.Info("starting hq")
 		err = hq.Start(finisherFinishChan, finisherProduceChan)
@@ -133,6 +246,17 @@ func startPipeline() {
 		panic(err)
 	}
 
+	// Start the crawl time limit watcher if needed
+	if config.Get().CrawlTimeLimit > 0 || config.Get().CrawlMaxTimeLimit > 0 {
+		watchers.StartWatchCrawlTimeLimit(
+			time.Duration(config.Get().CrawlTimeLimit)*time.Second,
+			time.Duration(config.Get().CrawlMaxTimeLimit)*time.Second,
+			stopConsumers,
+			stopAndExit,
+			resetSeeds,
+		)
+	}
+
 	// Pipe in the reactor the input seeds if any
 	if len(config.Get().InputSeeds) > 0 {
 		for _, seed := range config.Get().InputSeeds {
@@ -164,10 +288,17 @@ func stopPipeline() {
 
 	reactor.Freeze()
 
+	sitemapsCancel()
 	preprocessor.Stop()
 	archiver.Stop()
 	postprocessor.Stop()
 	finisher.Stop()
+	crawllog.Stop()
+
+	// Once the WARC files are closed (and indexed), the remaining ones are uploaded
+	if config.Get().S3Endpoint != "" {
+		uploader.Stop()
+	}
 
 	if config.Get().UseSeencheck && !config.Get().UseHQ {
 		seencheck.Close()
@@ -196,7 +327,60 @@ func stopPipeline() {
 		consul.Stop()
 	}
 
+	// Stopped last so that the max time limit still applies while stopping
+	watchers.StopCrawlTimeLimitWatcher()
+
 	logger.Info("done, logs are flushing and will be closed")
 
 	log.Stop()
 }
+
+// stopAndExit gracefully stops the pipeline and exits, it is given to the components
+// that can trigger a stop (crawl time limit watcher, API) but can't import the controler
+func stopAndExit() {
+	Stop()
+	os.Exit(0)
+}
+
+// stopConsumers stops the source from claiming new seeds, before draining the reactor
+func stopConsumers() {
+	if config.Get().UseHQ {
+		hq.StopConsumer()
+	} else {
+		lq.StopConsumer()
+	}
+}
+
+// resetSeeds gives back the seeds claimed by the reactor to the source they come from
+func resetSeeds() {
+	if config.Get().UseHQ {
+		hq.ResetSeeds()
+	} else {
+		lq.ResetSeeds()
+	}
+}
+
+// sitemapsProducer returns the function used to enqueue the sitemaps found in robots.txt files as outlinks, if enabled
+func sitemapsProducer(produceChan chan *models.Item) func([]*models.URL, string) {
+	if !config.Get().RobotsSitemaps {
+		return nil
+	}
+
+	return func(sitemaps []*models.URL, via string) {
+		for _, sitemap := range sitemaps {
+			if sitemap.GetHops() > config.Get().MaxHops {
+				continue
+			}
+
+			if err := sitemap.Parse(); err != nil {
+				continue
+			}
+
+			select {
+			case <-sitemapsCtx.Done():
+				return
+			case produceChan <- models.NewItem(uuid.New().String(), sitemap, via):
+			}
+		}
+	}
+}
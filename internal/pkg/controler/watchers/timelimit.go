@@ -0,0 +1,85 @@
+package watchers
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/reactor"
+)
+
+var (
+	timeLimitCtx, timeLimitCancel = context.WithCancel(context.Background())
+	timeLimitWg                   sync.WaitGroup
+)
+
+// StartWatchCrawlTimeLimit bounds the duration of the crawl.
+// When softLimit elapses, stopSourcesFunc is called so that no more seeds are claimed, the reactor stops accepting
+// new seeds, the seeds in flight are drained and stopFunc is called once the reactor is empty.
+// If hardLimit elapses first (or while stopping), forceResetFunc is called to give back the claimed seeds and Zeno exits with a non-zero code.
+// A zero limit disables the corresponding check.
+func StartWatchCrawlTimeLimit(softLimit, hardLimit time.Duration, stopSourcesFunc, stopFunc, forceResetFunc func()) {
+	if softLimit <= 0 && hardLimit <= 0 {
+		return
+	}
+
+	timeLimitWg.Add(1)
+	go func() {
+		defer timeLimitWg.Done()
+
+		logger := log.NewFieldedLogger(&log.Fields{
+			"component": "controler.crawlTimeLimitWatcher",
+		})
+		defer logger.Debug("closed")
+
+		var softLimitCh, hardLimitCh, drainCh <-chan time.Time
+
+		if softLimit > 0 {
+			softLimitTimer := time.NewTimer(softLimit)
+			defer softLimitTimer.Stop()
+			softLimitCh = softLimitTimer.C
+		}
+
+		if hardLimit > 0 {
+			hardLimitTimer := time.NewTimer(hardLimit)
+			defer hardLimitTimer.Stop()
+			hardLimitCh = hardLimitTimer.C
+		}
+
+		for {
+			select {
+			case <-timeLimitCtx.Done():
+				return
+			case <-softLimitCh:
+				logger.Info("crawl time limit reached, draining the seeds in flight before stopping", "limit", softLimit.String())
+				stopSourcesFunc()
+				reactor.Drain()
+				softLimitCh = nil
+
+				drainTicker := time.NewTicker(time.Second)
+				defer drainTicker.Stop()
+				drainCh = drainTicker.C
+			case <-drainCh:
+				if reactor.IsDrained() {
+					logger.Info("all seeds in flight are finished, stopping")
+					drainCh = nil
+
+					// stopFunc is expected to stop this watcher, so it can't be called synchronously
+					go stopFunc()
+				}
+			case <-hardLimitCh:
+				logger.Error("crawl max time limit reached, resetting claimed seeds and exiting", "limit", hardLimit.String())
+				forceResetFunc()
+				os.Exit(1)
+			}
+		}
+	}()
+}
+
+// StopCrawlTimeLimitWatcher stops the crawl time limit watcher by canceling the context and waiting for the goroutine to finish.
+func StopCrawlTimeLimitWatcher() {
+	timeLimitCancel()
+	timeLimitWg.Wait()
+}
@@ -0,0 +1,200 @@
+// Package crawllog writes the crawl log, a Heritrix-style log with one line per fetch outcome:
+//
+//	timestamp status size URL hop-path via MIME #worker fetch-start+duration digest source annotations
+//
+// The current file is <dir>/crawl.log, renamed crawl.log.<timestamp> when rotated.
+package crawllog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+)
+
+const (
+	fileName      = "crawl.log"
+	flushInterval = time.Second
+)
+
+var (
+	// ErrCrawlLogAlreadyInitialized is the error returned when the crawl log is already initialized
+	ErrCrawlLogAlreadyInitialized = errors.New("crawl log already initialized")
+
+	globalCrawlLog *crawlLog
+	once           sync.Once
+	logger         *log.FieldedLogger
+)
+
+type crawlLog struct {
+	mu       sync.Mutex
+	dir      string
+	rotation time.Duration
+	file     *os.File
+	writer   *bufio.Writer
+	opened   time.Time
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	nowFunc  func() time.Time
+}
+
+// Start opens the crawl log in the given directory, appending to the current file if any.
+// If rotation is positive, the file is rotated at that interval.
+func Start(dir string, rotation time.Duration) error {
+	var done bool
+	var startErr error
+
+	log.Start()
+	logger = log.NewFieldedLogger(&log.Fields{
+		"component": "crawllog",
+	})
+
+	once.Do(func() {
+		done = true
+
+		c := &crawlLog{
+			dir:      dir,
+			rotation: rotation,
+			stopCh:   make(chan struct{}),
+			nowFunc:  time.Now,
+		}
+
+		if err := c.open(); err != nil {
+			startErr = err
+			return
+		}
+
+		c.wg.Add(1)
+		go c.run()
+
+		globalCrawlLog = c
+		logger.Info("started", "path", filepath.Join(dir, fileName))
+	})
+
+	if !done {
+		return ErrCrawlLogAlreadyInitialized
+	}
+
+	return startErr
+}
+
+// Stop flushes and closes the crawl log
+func Stop() {
+	if globalCrawlLog == nil {
+		return
+	}
+
+	close(globalCrawlLog.stopCh)
+	globalCrawlLog.wg.Wait()
+
+	globalCrawlLog.mu.Lock()
+	if err := globalCrawlLog.close(); err != nil {
+		logger.Error("unable to close the crawl log", "err", err.Error())
+	}
+	globalCrawlLog.mu.Unlock()
+
+	globalCrawlLog = nil
+	once = sync.Once{}
+}
+
+// Enabled returns true if the crawl log is started
+func Enabled() bool {
+	return globalCrawlLog != nil
+}
+
+// Write appends the entry to the crawl log, if started
+func Write(entry *Entry) {
+	c := globalCrawlLog
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.writer == nil {
+		return
+	}
+
+	c.writer.WriteString(entry.format(c.nowFunc()))
+	c.writer.WriteByte('\n')
+}
+
+// open opens the current file of the crawl log
+func (c *crawlLog) open() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(c.dir, fileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	c.file = file
+	c.writer = bufio.NewWriterSize(file, 64*1024)
+	c.opened = c.nowFunc()
+
+	return nil
+}
+
+// close flushes and closes the current file, c.mu must be held
+func (c *crawlLog) close() error {
+	if c.file == nil {
+		return nil
+	}
+
+	flushErr := c.writer.Flush()
+	closeErr := c.file.Close()
+	c.file = nil
+	c.writer = nil
+
+	return errors.Join(flushErr, closeErr)
+}
+
+// rotate renames the current file with the rotation time as suffix and opens a new one.
+// If the file can't be renamed, the new lines are still appended to it.
+func (c *crawlLog) rotate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	closeErr := c.close()
+
+	current := filepath.Join(c.dir, fileName)
+	renameErr := os.Rename(current, fmt.Sprintf("%s.%s", current, c.nowFunc().UTC().Format("20060102150405")))
+
+	return errors.Join(closeErr, renameErr, c.open())
+}
+
+// run flushes the crawl log regularly so it can be followed, and rotates it when needed
+func (c *crawlLog) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+			if c.rotation > 0 && c.nowFunc().Sub(c.opened) >= c.rotation {
+				if err := c.rotate(); err != nil {
+					logger.Error("unable to rotate the crawl log", "err", err.Error())
+				}
+				continue
+			}
+
+			c.mu.Lock()
+			if c.writer != nil {
+				c.writer.Flush()
+			}
+			c.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package crawllog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+func TestEntryFormat(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 45, 123e6, time.UTC)
+
+	seed := models.NewItem("seed", &models.URL{Raw: "https://example.com/", Hops: 1}, "https://example.org/")
+	seed.SetStatus(models.ItemGotChildren)
+	asset := models.NewItem("asset", &models.URL{Raw: "https://example.com/style.css", Hops: 1}, "")
+	if err := seed.AddChild(asset, models.ItemGotChildren); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []*models.Item{seed, asset} {
+		if err := item.GetURL().Parse(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		entry    func() *Entry
+		expected string
+	}{
+		{
+			name: "fetched",
+			entry: func() *Entry {
+				entry := NewEntry(seed, 200, "7")
+				entry.Size = 1234
+				entry.MIME = "text/HTML; charset=utf-8"
+				entry.FetchStart = now.Add(-250 * time.Millisecond)
+				entry.FetchDuration = 200 * time.Millisecond
+				entry.Digest = "sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ"
+				return entry
+			},
+			expected: "2024-03-01T12:30:45.123Z   200       1234 https://example.com/ L https://example.org/ text/html #007 20240301123044873+200 sha1:3I42H3S6NNFQ2MSVX7XZKYAYSCX5QBYJ - -",
+		},
+		{
+			name: "retried revisit",
+			entry: func() *Entry {
+				entry := NewEntry(asset, 200, "12")
+				entry.Size = 10
+				entry.FetchStart = now
+				entry.AnnotateTries(3)
+				entry.Annotate(AnnotationRevisit)
+				return entry
+			},
+			expected: "2024-03-01T12:30:45.123Z   200         10 https://example.com/style.css LE https://example.com/ - #012 20240301123045123+0 - - 3t,duplicate:digest",
+		},
+		{
+			name: "excluded",
+			entry: func() *Entry {
+				entry := NewEntry(asset, StatusBlockedByUser, "preprocessor")
+				entry.AnnotateTries(1)
+				entry.Annotate(AnnotationExcludedByRule)
+				return entry
+			},
+			expected: "2024-03-01T12:30:45.123Z -5001          0 https://example.com/style.css LE https://example.com/ - #preprocessor - - - excluded-by-rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry().format(now); got != tt.expected {
+				t.Errorf("expected\n%q\ngot\n%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteAndRotate(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := Start(dir, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Start(dir, time.Hour); err != ErrCrawlLogAlreadyInitialized {
+		t.Errorf("expected ErrCrawlLogAlreadyInitialized, got %v", err)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	globalCrawlLog.nowFunc = func() time.Time { return now }
+
+	Write(&Entry{Status: 200, URL: "https://example.com/a"})
+
+	if err := globalCrawlLog.rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	Write(&Entry{Status: 404, URL: "https://example.com/b"})
+	Stop()
+
+	// Written after the stop, ignored
+	Write(&Entry{Status: 200, URL: "https://example.com/c"})
+
+	rotated, err := os.ReadFile(filepath.Join(dir, "crawl.log.20240301120000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := os.ReadFile(filepath.Join(dir, "crawl.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(string(rotated)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "https://example.com/a") {
+		t.Errorf("unexpected rotated file %q", rotated)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(string(current)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "https://example.com/b") {
+		t.Errorf("unexpected current file %q", current)
+	}
+
+	// A restarted crawl log appends to the current file
+	if err := Start(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	Write(&Entry{Status: 200, URL: "https://example.com/d"})
+	Stop()
+
+	current, _ = os.ReadFile(filepath.Join(dir, "crawl.log"))
+	if strings.Count(string(current), "\n") != 2 {
+		t.Errorf("expected the lines to be appended, got %q", current)
+	}
+}
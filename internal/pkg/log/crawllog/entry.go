@@ -0,0 +1,119 @@
+package crawllog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+// Negative status codes of the outcomes without a response, as in Heritrix
+const (
+	StatusConnectFailed      = -2    // unable to connect to the host
+	StatusConnectionLost     = -3    // connection lost during the fetch
+	StatusTimeout            = -4    // the fetch timed out
+	StatusUnexpectedError    = -5    // any other error
+	StatusDomainLookupFailed = -6    // unable to resolve the host
+	StatusOutOfScope         = -5000 // doesn't match the include filters
+	StatusBlockedByUser      = -5001 // matches the exclusion filters
+	StatusRobotsPrecluded    = -9998 // disallowed by robots.txt
+)
+
+// Annotations of the entries
+const (
+	AnnotationRevisit        = "duplicate:digest"
+	AnnotationExcludedByRule = "excluded-by-rule"
+)
+
+// Entry is a line of the crawl log
+type Entry struct {
+	Status        int   // HTTP status code or negative status code
+	Size          int64 // Size of the response body
+	URL           string
+	HopPath       string // Hops from the seed, e.g. LLE
+	Via           string // URL the URL was discovered from
+	MIME          string // Content type of the response
+	Worker        string // ID of the worker that processed the URL
+	FetchStart    time.Time
+	FetchDuration time.Duration
+	Digest        string // Payload digest, e.g. sha1:...
+	Annotations   []string
+}
+
+// NewEntry returns an entry about the item, with its URL, hop path and via
+func NewEntry(item *models.Item, status int, worker string) *Entry {
+	return &Entry{
+		Status:  status,
+		URL:     item.GetURL().String(),
+		HopPath: item.GetHopPath(),
+		Via:     item.GetVia(),
+		Worker:  worker,
+	}
+}
+
+// Annotate adds an annotation to the entry
+func (e *Entry) Annotate(annotation string) {
+	e.Annotations = append(e.Annotations, annotation)
+}
+
+// AnnotateTries adds the number of tries of the fetch, e.g. 3t, if it was retried
+func (e *Entry) AnnotateTries(tries int) {
+	if tries > 1 {
+		e.Annotate(strconv.Itoa(tries) + "t")
+	}
+}
+
+// format returns the line of the entry, logged at the given time
+func (e *Entry) format(now time.Time) string {
+	fetch := "-"
+	if !e.FetchStart.IsZero() {
+		fetch = e.FetchStart.UTC().Format("20060102150405.000")
+		fetch = strings.Replace(fetch, ".", "", 1) + "+" + strconv.FormatInt(e.FetchDuration.Milliseconds(), 10)
+	}
+
+	worker := "-"
+	if e.Worker != "" {
+		if id, err := strconv.Atoi(e.Worker); err == nil {
+			worker = fmt.Sprintf("#%03d", id)
+		} else {
+			worker = "#" + e.Worker
+		}
+	}
+
+	annotations := "-"
+	if len(e.Annotations) > 0 {
+		annotations = strings.Join(e.Annotations, ",")
+	}
+
+	// The source tag column is kept empty for compatibility with the Heritrix tools
+	return fmt.Sprintf("%s %5d %10d %s %s %s %s %s %s %s - %s",
+		now.UTC().Format("2006-01-02T15:04:05.000Z"),
+		e.Status,
+		e.Size,
+		orDash(e.URL),
+		orDash(e.HopPath),
+		orDash(e.Via),
+		orDash(mediaType(e.MIME)),
+		worker,
+		fetch,
+		orDash(e.Digest),
+		annotations,
+	)
+}
+
+// mediaType returns the MIME type without its parameters, e.g. text/html for text/html; charset=utf-8
+func mediaType(mime string) string {
+	mediaType, _, _ := strings.Cut(mime, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// orDash returns s, or - if it's empty, so that the columns can be split on spaces
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return strings.ReplaceAll(s, " ", "%20")
+}
@@ -11,9 +11,9 @@ import (
 	"github.com/internetarchive/Zeno/pkg/models"
 )
 
-// extractAssets extracts assets from the item's body and returns them.
+// extractAssets extracts assets from the item's body and returns them, with the name of the extractor used.
 // It also potentially returns outlinks if the body contains URLs that are not assets.
-func extractAssets(item *models.Item) (assets, outlinks []*models.URL, err error) {
+func extractAssets(item *models.Item) (assets, outlinks []*models.URL, extractorName string, err error) {
 	var (
 		contentType = item.GetURL().GetResponse().Header.Get("Content-Type")
 		logger      = log.NewFieldedLogger(&log.Fields{
@@ -26,52 +26,58 @@ func extractAssets(item *models.Item) (assets, outlinks []*models.URL, err error
 	// Order is important, we want to check for more specific things first,
 	// as they may trigger more general extractors (e.g. HTML)
 	case ina.IsAPIURL(item.GetURL()):
+		extractorName = "INA"
 		INAAssets, err := ina.ExtractMedias(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract medias from INA", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 
 		HTMLAssets, err := extractor.HTMLAssets(item)
 		if err != nil {
 			logger.Error("unable to extract assets", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 
 		assets = append(INAAssets, HTMLAssets...)
 	case truthsocial.NeedExtraction(item.GetURL()):
+		extractorName = "truthsocial.ExtractAssets"
 		assets, outlinks, err = truthsocial.ExtractAssets(item)
 		if err != nil {
 			logger.Error("unable to extract assets from TruthSocial", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 	case extractor.IsM3U8(item.GetURL()):
+		extractorName = "M3U8"
 		assets, err = extractor.M3U8(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract assets", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 	case extractor.IsJSON(item.GetURL()):
+		extractorName = "JSON"
 		assets, outlinks, err = extractor.JSON(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract assets", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 	case extractor.IsXML(item.GetURL()):
+		extractorName = "XML"
 		assets, outlinks, err = extractor.XML(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract assets", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 	case extractor.IsHTML(item.GetURL()):
+		extractorName = "HTMLAssets"
 		assets, err = extractor.HTMLAssets(item)
 		if err != nil {
 			logger.Error("unable to extract assets", "err", err.Error(), "item", item.GetShortID())
-			return assets, outlinks, err
+			return assets, outlinks, extractorName, err
 		}
 	default:
 		logger.Debug("no extractor used for page", "content-type", contentType, "item", item.GetShortID())
-		return assets, outlinks, nil
+		return assets, outlinks, extractorName, nil
 	}
 
 	for i := 0; i < len(assets); {
@@ -107,7 +113,7 @@ func extractAssets(item *models.Item) (assets, outlinks []*models.URL, err error
 		outlink.SetHops(item.GetURL().GetHops() + 1)
 	}
 
-	return assets, outlinks, nil
+	return assets, outlinks, extractorName, nil
 }
 
 func shouldExtractAssets(item *models.Item) bool {
@@ -28,6 +28,14 @@ func postprocessItem(item *models.Item) []*models.Item {
 
 	logger.Debug("postprocessing item", "item_id", item.GetShortID())
 
+	// Once done, write the links discovered in the item to its WARC metadata record, if enabled
+	var links []discoveredLink
+	defer func() {
+		if err := writeMetadataRecord(item, links); err != nil {
+			logger.Error("unable to write metadata record", "err", err.Error(), "item_id", item.GetShortID())
+		}
+	}()
+
 	// Verify if there is any redirection
 	if isStatusCodeRedirect(item.GetURL().GetResponse().StatusCode) {
 		logger.Debug("item is a redirection", "item_id", item.GetShortID())
@@ -46,6 +54,8 @@ func postprocessItem(item *models.Item) []*models.Item {
 			Hops:      item.GetURL().GetHops(),
 		}
 
+		links = append(links, discoveredLink{URL: newURL.Raw, hopType: models.HopRedirect, extractor: "Location"})
+
 		newChild := models.NewItem(uuid.New().String(), newURL, "")
 		err := item.AddChild(newChild, models.ItemGotRedirected)
 		if err != nil {
@@ -91,14 +101,17 @@ func postprocessItem(item *models.Item) []*models.Item {
 	if item.GetURL().GetResponse() != nil && item.GetURL().GetResponse().StatusCode == 200 {
 		logger.Debug("item is a success", "item_id", item.GetShortID())
 
-		var outlinksFromAssets []*models.URL
+		var (
+			outlinksFromAssets []*models.URL
+			assetsExtractor    string
+		)
 
 		// Extract assets from the page
 		if shouldExtractAssets(item) {
 			var assets []*models.URL
 			var err error
 
-			assets, outlinksFromAssets, err = extractAssets(item)
+			assets, outlinksFromAssets, assetsExtractor, err = extractAssets(item)
 			if err != nil {
 				logger.Error("unable to extract assets", "err", err.Error(), "item_id", item.GetShortID())
 			} else {
@@ -123,6 +136,8 @@ func postprocessItem(item *models.Item) []*models.Item {
 						}
 					}
 
+					links = append(links, discoveredLink{URL: assets[i].Raw, hopType: models.HopEmbed, extractor: assetsExtractor})
+
 					newChild := models.NewItem(uuid.New().String(), assets[i], "")
 					err = item.AddChild(newChild, models.ItemGotChildren)
 					if err != nil {
@@ -136,12 +151,15 @@ func postprocessItem(item *models.Item) []*models.Item {
 
 		// Extract outlinks from the page
 		if shouldExtractOutlinks(item) {
-			newOutlinks, err := extractOutlinks(item)
+			newOutlinks, extractors, err := extractOutlinks(item)
 			if err != nil {
 				logger.Error("unable to extract outlinks", "err", err.Error(), "item_id", item.GetShortID())
 			} else {
 				// Append the outlinks found from the assets
 				newOutlinks = append(newOutlinks, outlinksFromAssets...)
+				for range outlinksFromAssets {
+					extractors = append(extractors, assetsExtractor)
+				}
 
 				for i := range newOutlinks {
 					if newOutlinks[i] == nil {
@@ -149,6 +167,8 @@ func postprocessItem(item *models.Item) []*models.Item {
 						continue
 					}
 
+					links = append(links, discoveredLink{URL: newOutlinks[i].Raw, hopType: models.HopLink, extractor: extractors[i]})
+
 					// If domains crawl, and if the host of the new outlinks match the host of its parent
 					// and if its parent is at hop 0, then we need to set the hop count to 0.
 					// TODO: maybe be more flexible than a strict match
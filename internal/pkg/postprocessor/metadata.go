@@ -0,0 +1,43 @@
+package postprocessor
+
+import (
+	"strings"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver"
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+// discoveredLink is a link discovered from an item, listed in the item's WARC metadata record
+type discoveredLink struct {
+	URL       string
+	hopType   string
+	extractor string
+}
+
+// writeMetadataRecord writes a WARC metadata record listing the hop path and via of the item, and the links discovered in it.
+// The record is concurrent to the response record of the item, and is only written if links were discovered.
+func writeMetadataRecord(item *models.Item, links []discoveredLink) error {
+	if !config.Get().WARCMetadataRecords || len(links) == 0 {
+		return nil
+	}
+
+	return archiver.WriteMetadataRecord(item.GetURL().String(), item.GetURL().GetResponseRecordID(), metadataContent(item, links))
+}
+
+// metadataContent returns the application/warc-fields content of the item's metadata record
+func metadataContent(item *models.Item, links []discoveredLink) string {
+	var content strings.Builder
+
+	content.WriteString("hopsFromSeed: " + item.GetHopPath() + "\r\n")
+
+	if via := item.GetVia(); via != "" {
+		content.WriteString("via: " + via + "\r\n")
+	}
+
+	for _, link := range links {
+		content.WriteString("outlink: " + link.URL + " " + link.hopType + " " + link.extractor + "\r\n")
+	}
+
+	return content.String()
+}
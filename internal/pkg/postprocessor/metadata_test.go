@@ -0,0 +1,63 @@
+package postprocessor
+
+import (
+	"testing"
+
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+func TestMetadataContent(t *testing.T) {
+	seed := models.NewItem("seed", &models.URL{Raw: "https://example.com/", Hops: 2}, "https://example.org/")
+
+	redirection := models.NewItem("redirection", &models.URL{Raw: "https://www.example.com/", Hops: 2}, "")
+	if err := seed.AddChild(redirection, models.ItemGotRedirected); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := models.NewItem("asset", &models.URL{Raw: "https://www.example.com/style.css", Hops: 2}, "")
+	if err := redirection.AddChild(asset, models.ItemGotChildren); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []*models.Item{seed, redirection, asset} {
+		if err := item.GetURL().Parse(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		item     *models.Item
+		links    []discoveredLink
+		expected string
+	}{
+		{
+			name:     "seed",
+			item:     seed,
+			links:    []discoveredLink{{URL: "https://www.example.com/", hopType: models.HopRedirect, extractor: "Location"}},
+			expected: "hopsFromSeed: LL\r\nvia: https://example.org/\r\noutlink: https://www.example.com/ R Location\r\n",
+		},
+		{
+			name: "redirection",
+			item: redirection,
+			links: []discoveredLink{
+				{URL: "https://www.example.com/style.css", hopType: models.HopEmbed, extractor: "HTMLAssets"},
+				{URL: "https://www.example.com/about", hopType: models.HopLink, extractor: "HTMLOutlinks"},
+			},
+			expected: "hopsFromSeed: LLR\r\nvia: https://example.com/\r\noutlink: https://www.example.com/style.css E HTMLAssets\r\noutlink: https://www.example.com/about L HTMLOutlinks\r\n",
+		},
+		{
+			name:     "asset of a redirection",
+			item:     asset,
+			expected: "hopsFromSeed: LLRE\r\nvia: https://www.example.com/\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metadataContent(tt.item, tt.links); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -14,83 +14,104 @@ import (
 	"github.com/internetarchive/Zeno/pkg/models"
 )
 
-func extractOutlinks(item *models.Item) (outlinks []*models.URL, err error) {
+// extractOutlinks extracts outlinks from the item's body and returns them, with the name of the extractor of each outlink
+func extractOutlinks(item *models.Item) (outlinks []*models.URL, extractors []string, err error) {
 	var (
-		contentType = item.GetURL().GetResponse().Header.Get("Content-Type")
-		logger      = log.NewFieldedLogger(&log.Fields{
+		contentType   = item.GetURL().GetResponse().Header.Get("Content-Type")
+		extractorName string
+		logger        = log.NewFieldedLogger(&log.Fields{
 			"component": "postprocessor.extractOutlinks",
 		})
 	)
 
 	if item.GetURL().GetBody() == nil {
 		logger.Error("no body to extract outlinks from", "url", item.GetURL().String(), "item", item.GetShortID())
-		return
+		return outlinks, extractors, nil
 	}
 
 	// Run specific extractors
 	switch {
 	case truthsocial.IsAccountURL(item.GetURL()):
+		extractorName = "truthsocial.GenerateAccountLookupURL"
 		outlinks, err = truthsocial.GenerateAccountLookupURL(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract outlinks", "extractor", "truthsocial.GenerateAccountLookupURL", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 	case truthsocial.IsAccountLookupURL(item.GetURL()):
+		extractorName = "truthsocial.GenerateOutlinksURLsFromLookup"
 		outlinks, err = truthsocial.GenerateOutlinksURLsFromLookup(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract outlinks", "extractor", "truthsocial.GenerateOutlinksURLsFromLookup", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 	case extractor.IsS3(item.GetURL()):
+		extractorName = "S3"
 		outlinks, err = extractor.S3(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract outlinks from S3", "extractor", "S3", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 	case extractor.IsSitemapXML(item.GetURL()):
+		extractorName = "XML"
 		var assets []*models.URL
 
 		assets, outlinks, err = extractor.XML(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract outlinks", "extractor", "XML", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 
 		// Here we don't care about the difference between assets and outlinks,
 		// we just want to extract all the URLs from the sitemap
 		outlinks = append(outlinks, assets...)
 	case extractor.IsHTML(item.GetURL()):
+		extractorName = "HTMLOutlinks"
 		outlinks, err = extractor.HTMLOutlinks(item)
 		if err != nil {
 			logger.Error("unable to extract outlinks", "extractor", "HTMLOutlinks", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 	case extractor.IsPDF(item.GetURL()):
+		extractorName = "PDF"
 		outlinks, err = extractor.PDF(item.GetURL())
 		if err != nil {
 			logger.Error("unable to extract outlinks", "extractor", "PDF", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 	case reddit.IsPostAPI(item.GetURL()):
+		extractorName = "reddit.ExtractAPIPostPermalinks"
 		outlinks, err = reddit.ExtractAPIPostPermalinks(item)
 		if err != nil {
 			logger.Error("unable to extract outlinks", "extractor", "reddit.ExtractAPIPostPermalinks", "err", err.Error(), "item", item.GetShortID(), "url", item.GetURL().String())
-			return outlinks, err
+			return outlinks, nil, err
 		}
 	default:
 		logger.Debug("no extractor used for page", "content-type", contentType, "item", item.GetShortID(), "url", item.GetURL().String())
-		return outlinks, nil
+		return outlinks, extractors, nil
+	}
+
+	// Keep track of the extractor that found each outlink
+	for range outlinks {
+		extractors = append(extractors, extractorName)
 	}
 
 	// Try to extract links from link headers
 	linksFromLinkHeader := extractor.ExtractURLsFromHeader(item.GetURL())
 	if linksFromLinkHeader != nil {
 		outlinks = append(outlinks, linksFromLinkHeader...)
+		for range linksFromLinkHeader {
+			extractors = append(extractors, "LinkHeader")
+		}
 	}
 
 	// If the page is a text/* content type, extract links from the body (aggressively)
 	if strings.Contains(contentType, "text/") {
-		outlinks = append(outlinks, extractLinksFromPage(item.GetURL())...)
+		linksFromPage := extractLinksFromPage(item.GetURL())
+		outlinks = append(outlinks, linksFromPage...)
+		for range linksFromPage {
+			extractors = append(extractors, "TextLinks")
+		}
 	}
 
 	// Set the hops level to the item's level + 1
@@ -98,7 +119,7 @@ func extractOutlinks(item *models.Item) (outlinks []*models.URL, err error) {
 		outlink.SetHops(item.GetURL().GetHops() + 1)
 	}
 
-	return outlinks, nil
+	return outlinks, extractors, nil
 }
 
 func extractLinksFromPage(URL *models.URL) (links []*models.URL) {
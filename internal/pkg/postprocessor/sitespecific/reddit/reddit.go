@@ -33,14 +33,14 @@ func AddCookies(req *http.Request) {
 		},
 	}
 
+	// Cookies parsed from a request only carry a name and a value, so we match on
+	// the name to avoid overwriting cookies already set (e.g. from the cookie jar)
 	existingCookies := req.Cookies()
 
 	for _, newCookie := range newCookies {
 		exists := false
 		for _, existingCookie := range existingCookies {
-			if existingCookie.Name == newCookie.Name &&
-				existingCookie.Domain == newCookie.Domain &&
-				existingCookie.Path == newCookie.Path {
+			if existingCookie.Name == newCookie.Name {
 				exists = true
 				break
 			}
@@ -0,0 +1,196 @@
+// Package canonicalizer computes the canonical keys of the URLs, used to deduplicate them in the local seencheck
+// and the local queue while the original URLs are still the ones fetched.
+//
+// The canonicalization follows the Heritrix rules: the user info, session IDs, tracking parameters and the
+// parameters blocklisted for the host are stripped, then the URL is turned into its SURT form, that lowercases
+// it, strips the www prefix and the default port, and sorts the query.
+package canonicalizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/internetarchive/Zeno/internal/pkg/utils"
+)
+
+// Settings configures the canonicalization
+type Settings struct {
+	// StripParams are the query parameters stripped from every URL, on top of the session and tracking ones
+	StripParams []string
+	// Rules strip more query parameters from the URLs they match, the first matching rule wins
+	Rules []Rule
+}
+
+type canonicalizer struct {
+	stripParams []string
+	rules       []compiledRule
+	mode        string
+}
+
+// ModeNone is the mode of the keys while the canonicalization is disabled, the URLs as they are
+const ModeNone = "none"
+
+var (
+	globalCanonicalizer *canonicalizer
+
+	// sessionParams are the query parameters carrying session IDs
+	sessionParams = []string{"jsessionid", "phpsessid", "aspsessionid*", "sid", "sessionid", "session_id", "cfid", "cftoken"}
+
+	// trackingParams are the query parameters added by analytics and advertising platforms
+	trackingParams = []string{"utm_*", "fbclid", "gclid", "gclsrc", "dclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi"}
+
+	// pathSessionID matches the session IDs appended to the path, e.g. /page;jsessionid=0123
+	pathSessionID = regexp.MustCompile(`(?i);(jsessionid|phpsessid|sid)=[^/?]*`)
+)
+
+// Start enables the canonicalization of the keys. Until then, the keys are the URLs as they are.
+func Start(settings Settings) error {
+	rules, err := compileRules(settings.Rules)
+	if err != nil {
+		return err
+	}
+
+	stripParams := append(append(append([]string{}, sessionParams...), trackingParams...), settings.StripParams...)
+	for i := range stripParams {
+		stripParams[i] = strings.ToLower(stripParams[i])
+	}
+
+	globalCanonicalizer = &canonicalizer{
+		stripParams: stripParams,
+		rules:       rules,
+		mode:        mode(settings),
+	}
+
+	return nil
+}
+
+// mode returns "surt-" followed by a hash of everything the keys depend on: the built-in and given stripped
+// parameters, the path session IDs and the rules, so that changing the built-in lists changes the mode too
+func mode(settings Settings) string {
+	// The parameters are stripped whatever their order, but the first matching rule wins
+	stripParams := make([]string, 0, len(sessionParams)+len(trackingParams)+len(settings.StripParams))
+	for _, param := range slices.Concat(sessionParams, trackingParams, settings.StripParams) {
+		stripParams = append(stripParams, strings.ToLower(param))
+	}
+	slices.Sort(stripParams)
+
+	encoded, _ := json.Marshal(struct {
+		StripParams   []string `json:"strip_params"`
+		PathSessionID string   `json:"path_session_id"`
+		Rules         []Rule   `json:"rules"`
+	}{slices.Compact(stripParams), pathSessionID.String(), settings.Rules})
+	sum := sha256.Sum256(encoded)
+
+	return "surt-" + hex.EncodeToString(sum[:4])
+}
+
+// Stop disables the canonicalization
+func Stop() {
+	globalCanonicalizer = nil
+}
+
+// Enabled returns true if the keys are canonicalized
+func Enabled() bool {
+	return globalCanonicalizer != nil
+}
+
+// Mode identifies how the keys are computed, for the databases keyed with them to refuse the keys computed
+// with other settings: ModeNone while disabled, "surt-<hash of the settings>" otherwise
+func Mode() string {
+	if globalCanonicalizer == nil {
+		return ModeNone
+	}
+
+	return globalCanonicalizer.mode
+}
+
+// Key returns the key of the URL used to deduplicate it: its canonical SURT form if the canonicalization
+// is enabled, the URL as is otherwise or if it can't be canonicalized
+func Key(rawURL string) string {
+	if globalCanonicalizer == nil {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	key, err := utils.SURT(globalCanonicalizer.canonicalize(u).String())
+	if err != nil {
+		return rawURL
+	}
+
+	return key
+}
+
+// canonicalize returns a copy of the URL without its user info, fragment, session IDs and stripped parameters
+func (c *canonicalizer) canonicalize(u *url.URL) *url.URL {
+	canonical := *u
+	canonical.User = nil
+	canonical.Fragment = ""
+	canonical.RawFragment = ""
+
+	if strings.Contains(canonical.Path, ";") {
+		canonical.Path = pathSessionID.ReplaceAllString(canonical.Path, "")
+		canonical.RawPath = pathSessionID.ReplaceAllString(canonical.RawPath, "")
+	}
+
+	stripParams := c.stripParams
+	if rule := c.match(&canonical); rule != nil {
+		stripParams = append(append([]string{}, stripParams...), rule.stripParams...)
+	}
+
+	var params []string
+	for _, param := range strings.Split(canonical.RawQuery, "&") {
+		if param == "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if !matchParam(strings.ToLower(name), stripParams) {
+			params = append(params, param)
+		}
+	}
+
+	canonical.RawQuery = strings.Join(params, "&")
+	canonical.ForceQuery = false
+
+	return &canonical
+}
+
+// match returns the first rule matching the URL, if any
+func (c *canonicalizer) match(u *url.URL) *compiledRule {
+	for i := range c.rules {
+		if c.rules[i].match(u) {
+			return &c.rules[i]
+		}
+	}
+
+	return nil
+}
+
+// matchParam returns true if the lowercased parameter name is one of the patterns,
+// a pattern ending with * matching all the names starting with it
+func matchParam(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package canonicalizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule strips query parameters from the URLs it matches.
+// Exactly one of Host, Domain or Regex must be set.
+type Rule struct {
+	// Host matches this exact host
+	Host string `json:"host,omitempty"`
+	// Domain matches this domain and all its subdomains
+	Domain string `json:"domain,omitempty"`
+	// Regex matches the URLs matching this regular expression
+	Regex string `json:"regex,omitempty"`
+
+	// StripParams are the query parameters stripped, a name ending with * matching all the names starting with it
+	StripParams []string `json:"strip_params"`
+}
+
+// compiledRule is a validated Rule ready to be matched
+type compiledRule struct {
+	Rule
+	regex       *regexp.Regexp
+	stripParams []string
+}
+
+// LoadRules reads a JSON canonicalization rules file, a list of rules that are evaluated in order, the first matching rule wins
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid canonicalization rules file: %w", err)
+	}
+
+	if _, err := compileRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func compileRules(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+
+	for i, rule := range rules {
+		matchers := 0
+		for _, matcher := range []string{rule.Host, rule.Domain, rule.Regex} {
+			if matcher != "" {
+				matchers++
+			}
+		}
+		if matchers != 1 {
+			return nil, fmt.Errorf("canonicalization rule %d: exactly one of host, domain or regex must be set", i)
+		}
+
+		c := compiledRule{Rule: rule}
+		c.Host = strings.ToLower(rule.Host)
+		c.Domain = strings.ToLower(strings.TrimPrefix(rule.Domain, "."))
+
+		if rule.Regex != "" {
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("canonicalization rule %d: %w", i, err)
+			}
+			c.regex = regex
+		}
+
+		for _, param := range rule.StripParams {
+			c.stripParams = append(c.stripParams, strings.ToLower(param))
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// match returns true if the rule applies to the URL
+func (r *compiledRule) match(u *url.URL) bool {
+	hostname := strings.ToLower(u.Hostname())
+
+	switch {
+	case r.Host != "":
+		return hostname == r.Host
+	case r.Domain != "":
+		return hostname == r.Domain || strings.HasSuffix(hostname, "."+r.Domain)
+	case r.regex != nil:
+		return r.regex.MatchString(u.String())
+	}
+
+	return false
+}
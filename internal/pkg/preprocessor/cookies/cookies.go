@@ -0,0 +1,120 @@
+// Package cookies holds the cookie jar used to attach cookies to the requests built by the preprocessor.
+// The jar can be loaded from a Netscape/Mozilla cookies.txt file or from a JSON export (as produced by
+// most browser extensions), and can optionally be updated with the cookies set by the crawled servers.
+package cookies
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+type cookieJar struct {
+	sync.RWMutex
+	enabled bool
+	persist bool
+	jar     *cookiejar.Jar
+}
+
+var (
+	globalJar = &cookieJar{}
+)
+
+// Start initializes the cookie jar and loads the cookies contained in the given file, if any.
+// If persist is true, the cookies received in responses will be added to the jar.
+func Start(path string, persist bool) error {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		fileCookies, err := parse(f)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range fileCookies {
+			jar.SetCookies(c.url(), []*http.Cookie{c.cookie})
+		}
+	}
+
+	globalJar.Lock()
+	defer globalJar.Unlock()
+
+	globalJar.enabled = true
+	globalJar.persist = persist
+	globalJar.jar = jar
+
+	return nil
+}
+
+// Reset the jar to its initial state
+func Reset() {
+	globalJar.Lock()
+	defer globalJar.Unlock()
+
+	globalJar.enabled = false
+	globalJar.persist = false
+	globalJar.jar = nil
+}
+
+// Enabled returns true if the cookie jar is enabled
+func Enabled() bool {
+	globalJar.RLock()
+	defer globalJar.RUnlock()
+
+	return globalJar.enabled
+}
+
+// AddCookies attaches the cookies of the jar that match the request's URL to the request.
+// Cookies already present on the request are kept as is, the jar never overwrites them.
+func AddCookies(req *http.Request) {
+	globalJar.RLock()
+	defer globalJar.RUnlock()
+
+	if !globalJar.enabled {
+		return
+	}
+
+	existingCookies := req.Cookies()
+
+	for _, newCookie := range globalJar.jar.Cookies(req.URL) {
+		exists := false
+		for _, existingCookie := range existingCookies {
+			if existingCookie.Name == newCookie.Name {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			req.AddCookie(newCookie)
+		}
+	}
+}
+
+// SetFromResponse stores the cookies set by the response (Set-Cookie headers) in the jar,
+// if the jar is enabled and was started with persistence turned on.
+func SetFromResponse(URL *url.URL, resp *http.Response) {
+	globalJar.RLock()
+	defer globalJar.RUnlock()
+
+	if !globalJar.enabled || !globalJar.persist || resp == nil {
+		return
+	}
+
+	if cookies := resp.Cookies(); len(cookies) > 0 {
+		globalJar.jar.SetCookies(URL, cookies)
+	}
+}
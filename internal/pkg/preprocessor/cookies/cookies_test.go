@@ -0,0 +1,183 @@
+package cookies
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const netscapeFile = `# Netscape HTTP Cookie File
+# This is a generated file! Do not edit.
+
+.example.com	TRUE	/	FALSE	0	session	abc
+www.example.org	FALSE	/	TRUE	4102444800	secure	def
+#HttpOnly_.example.net	TRUE	/private	FALSE	4102444800	httponly	ghi
+.example.com	TRUE	/	FALSE	946684800	expired	jkl
+`
+
+const jsonFile = `[
+  {"domain": ".example.com", "name": "session", "value": "abc", "path": "/", "secure": false, "hostOnly": false, "session": true},
+  {"domain": "www.example.org", "name": "secure", "value": "def", "path": "/", "secure": true, "hostOnly": true, "expirationDate": 4102444800.5}
+]`
+
+func TestParseNetscape(t *testing.T) {
+	cookies, err := parse(strings.NewReader(netscapeFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cookies) != 4 {
+		t.Fatalf("expected 4 cookies, got %d", len(cookies))
+	}
+
+	if cookies[0].host != "example.com" || cookies[0].hostOnly || cookies[0].cookie.Domain != "example.com" || !cookies[0].cookie.Expires.IsZero() {
+		t.Errorf("unexpected session cookie: %+v %+v", cookies[0], cookies[0].cookie)
+	}
+
+	if !cookies[1].hostOnly || cookies[1].cookie.Domain != "" || !cookies[1].cookie.Secure || cookies[1].url().Scheme != "https" {
+		t.Errorf("unexpected host-only secure cookie: %+v %+v", cookies[1], cookies[1].cookie)
+	}
+
+	if !cookies[2].cookie.HttpOnly || cookies[2].cookie.Path != "/private" || cookies[2].host != "example.net" {
+		t.Errorf("unexpected HttpOnly cookie: %+v %+v", cookies[2], cookies[2].cookie)
+	}
+}
+
+func TestParseNetscapeInvalid(t *testing.T) {
+	_, err := parse(strings.NewReader("example.com\tTRUE\t/\n"))
+	if err == nil {
+		t.Fatal("expected an error on a malformed line")
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	cookies, err := parse(strings.NewReader(jsonFile))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cookies) != 2 {
+		t.Fatalf("expected 2 cookies, got %d", len(cookies))
+	}
+
+	if cookies[0].hostOnly || !cookies[0].cookie.Expires.IsZero() {
+		t.Errorf("unexpected session cookie: %+v %+v", cookies[0], cookies[0].cookie)
+	}
+
+	if !cookies[1].hostOnly || cookies[1].cookie.Expires.Unix() != 4102444800 {
+		t.Errorf("unexpected host-only cookie: %+v %+v", cookies[1], cookies[1].cookie)
+	}
+}
+
+func TestAddCookies(t *testing.T) {
+	defer Reset()
+
+	cookiesPath := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(cookiesPath, []byte(netscapeFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Start(cookiesPath, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{"http://www.example.com/page", []string{"session=abc"}},
+		{"http://example.com/", []string{"session=abc"}},
+		{"http://www.example.org/", nil},
+		{"https://www.example.org/", []string{"secure=def"}},
+		{"https://sub.www.example.org/", nil},
+		{"http://example.net/", nil},
+		{"http://example.net/private/page", []string{"httponly=ghi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			AddCookies(req)
+
+			got := req.Cookies()
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+
+			for i := range got {
+				if got[i].String() != tt.expected[i] {
+					t.Errorf("expected %s, got %s", tt.expected[i], got[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestAddCookiesDoesNotOverwrite(t *testing.T) {
+	defer Reset()
+
+	cookiesPath := filepath.Join(t.TempDir(), "cookies.json")
+	if err := os.WriteFile(cookiesPath, []byte(jsonFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Start(cookiesPath, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "already-set"})
+	AddCookies(req)
+
+	got := req.Cookies()
+	if len(got) != 1 || got[0].Value != "already-set" {
+		t.Errorf("expected existing cookie to be kept, got %v", got)
+	}
+}
+
+func TestSetFromResponse(t *testing.T) {
+	defer Reset()
+
+	URL, _ := url.Parse("http://example.com/login")
+	resp := &http.Response{Header: http.Header{"Set-Cookie": []string{"token=xyz; Path=/"}}}
+
+	// Without persistence, the cookies set by the server are ignored
+	if err := Start("", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	SetFromResponse(URL, resp)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	AddCookies(req)
+	if len(req.Cookies()) != 0 {
+		t.Fatalf("expected no cookie, got %v", req.Cookies())
+	}
+
+	// With persistence, they are added to the jar
+	if err := Start("", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	SetFromResponse(URL, resp)
+
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	AddCookies(req)
+	if len(req.Cookies()) != 1 || req.Cookies()[0].String() != "token=xyz" {
+		t.Fatalf("expected token cookie, got %v", req.Cookies())
+	}
+}
+
+func TestAddCookiesDisabled(t *testing.T) {
+	Reset()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	AddCookies(req)
+
+	if len(req.Cookies()) != 0 {
+		t.Errorf("expected no cookie when the jar is disabled, got %v", req.Cookies())
+	}
+}
@@ -0,0 +1,168 @@
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileCookie is a cookie read from a cookies file, along with the host it belongs to.
+// If hostOnly is true, the cookie is only sent to this exact host, else it is also sent to its subdomains.
+type fileCookie struct {
+	host     string
+	hostOnly bool
+	cookie   *http.Cookie
+}
+
+// jsonCookie is the format used by most browser extensions to export cookies
+type jsonCookie struct {
+	Domain         string  `json:"domain"`
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Path           string  `json:"path"`
+	Secure         bool    `json:"secure"`
+	HTTPOnly       bool    `json:"httpOnly"`
+	HostOnly       bool    `json:"hostOnly"`
+	Session        bool    `json:"session"`
+	ExpirationDate float64 `json:"expirationDate"`
+}
+
+// url returns the URL used to store the cookie in the jar
+func (c *fileCookie) url() *url.URL {
+	scheme := "http"
+	if c.cookie.Secure {
+		scheme = "https"
+	}
+
+	path := c.cookie.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return &url.URL{Scheme: scheme, Host: c.host, Path: path}
+}
+
+// parse reads cookies from r, detecting whether it is a JSON export or a Netscape cookies.txt file
+func parse(r io.Reader) ([]*fileCookie, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseJSON(trimmed)
+	}
+
+	return parseNetscape(bytes.NewReader(data))
+}
+
+// parseNetscape reads cookies in the Netscape/Mozilla cookies.txt format:
+// domain, include subdomains, path, secure, expiration, name, value; separated by tabs.
+func parseNetscape(r io.Reader) (cookies []*fileCookie, err error) {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+
+		// curl and browsers prefix HttpOnly cookies with this marker, other lines starting with # are comments
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// Some exporters omit the value of empty cookies
+			fields = append(fields, "")
+		}
+
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("invalid cookies file line %d: expected 7 tab-separated fields, got %d", lineNumber, len(fields))
+		}
+
+		expiration, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookies file line %d: invalid expiration: %w", lineNumber, err)
+		}
+
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+
+		// An expiration of 0 means that it's a session cookie
+		if expiration > 0 {
+			cookie.Expires = time.Unix(expiration, 0)
+		}
+
+		cookies = append(cookies, newFileCookie(fields[0], !strings.EqualFold(fields[1], "TRUE"), cookie))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cookies, nil
+}
+
+// parseJSON reads cookies from a JSON array of cookie objects
+func parseJSON(data []byte) (cookies []*fileCookie, err error) {
+	var jsonCookies []jsonCookie
+
+	if err := json.Unmarshal(data, &jsonCookies); err != nil {
+		return nil, fmt.Errorf("invalid JSON cookies file: %w", err)
+	}
+
+	for _, c := range jsonCookies {
+		if c.Domain == "" || c.Name == "" {
+			continue
+		}
+
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+
+		if !c.Session && c.ExpirationDate > 0 {
+			cookie.Expires = time.Unix(int64(c.ExpirationDate), 0)
+		}
+
+		cookies = append(cookies, newFileCookie(c.Domain, c.HostOnly, cookie))
+	}
+
+	return cookies, nil
+}
+
+func newFileCookie(domain string, hostOnly bool, cookie *http.Cookie) *fileCookie {
+	host := strings.TrimPrefix(domain, ".")
+
+	// Leaving the domain empty makes the jar treat the cookie as host-only
+	if !hostOnly {
+		cookie.Domain = host
+	}
+
+	return &fileCookie{
+		host:     host,
+		hostOnly: hostOnly,
+		cookie:   cookie,
+	}
+}
@@ -2,6 +2,7 @@ package preprocessor
 
 import (
 	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/internal/pkg/log/crawllog"
 	"github.com/internetarchive/Zeno/pkg/models"
 )
 
@@ -14,3 +15,10 @@ func matchRegexExclusion(item *models.Item) bool {
 
 	return false
 }
+
+// logExclusion writes the crawl log line of an item excluded by the include or exclusion filters
+func logExclusion(item *models.Item, status int, workerID string) {
+	entry := crawllog.NewEntry(item, status, workerID)
+	entry.Annotate(crawllog.AnnotationExcludedByRule)
+	crawllog.Write(entry)
+}
@@ -0,0 +1,240 @@
+// Package headers applies per-site header profiles to the requests built by the preprocessor.
+// A profile matches a host, a domain or a URL regular expression and sets (or removes) headers on the
+// matching requests, e.g. to override the User-Agent or send browser-like Accept and Sec-Fetch-* headers.
+// Built-in profiles for the sites that need them are embedded in Zeno, more can be loaded from a JSON file.
+package headers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+//go:embed default.json
+var defaultProfiles []byte
+
+// Profile is a set of headers applied to the requests it matches.
+// Exactly one of Host, Domain or URLRegex must be set.
+type Profile struct {
+	// Name identifies the profile in logs and errors
+	Name string `json:"name,omitempty"`
+	// Host matches this exact host
+	Host string `json:"host,omitempty"`
+	// Domain matches this domain and all its subdomains
+	Domain string `json:"domain,omitempty"`
+	// URLRegex matches the URLs matching this regular expression
+	URLRegex string `json:"url_regex,omitempty"`
+
+	// Headers are set on the matching requests, an empty value removes the header
+	Headers map[string]string `json:"headers"`
+}
+
+// compiledProfile is a validated Profile ready to be matched
+type compiledProfile struct {
+	Profile
+	regex *regexp.Regexp
+}
+
+type profiles struct {
+	sync.RWMutex
+	profiles []compiledProfile
+}
+
+var (
+	globalProfiles = &profiles{}
+)
+
+// Start loads the header profiles: the ones of the given file, if any, are evaluated first,
+// followed by the built-in profiles unless disableDefaults is true. The first matching profile wins.
+func Start(path string, disableDefaults bool) error {
+	var all []Profile
+
+	if path != "" {
+		fileProfiles, err := LoadProfiles(path)
+		if err != nil {
+			return err
+		}
+		all = append(all, fileProfiles...)
+	}
+
+	if !disableDefaults {
+		builtins, err := DefaultProfiles()
+		if err != nil {
+			return err
+		}
+		all = append(all, builtins...)
+	}
+
+	compiled, err := compileProfiles(all)
+	if err != nil {
+		return err
+	}
+
+	globalProfiles.Lock()
+	defer globalProfiles.Unlock()
+
+	globalProfiles.profiles = compiled
+
+	return nil
+}
+
+// Reset removes all the loaded profiles
+func Reset() {
+	globalProfiles.Lock()
+	defer globalProfiles.Unlock()
+
+	globalProfiles.profiles = nil
+}
+
+// Profiles returns the profiles currently loaded, in evaluation order
+func Profiles() []Profile {
+	globalProfiles.RLock()
+	defer globalProfiles.RUnlock()
+
+	loaded := make([]Profile, len(globalProfiles.profiles))
+	for i := range globalProfiles.profiles {
+		loaded[i] = globalProfiles.profiles[i].Profile
+	}
+
+	return loaded
+}
+
+// DefaultProfiles returns the built-in profiles
+func DefaultProfiles() ([]Profile, error) {
+	var builtins []Profile
+	if err := json.Unmarshal(defaultProfiles, &builtins); err != nil {
+		return nil, fmt.Errorf("invalid built-in header profiles: %w", err)
+	}
+
+	return builtins, nil
+}
+
+// LoadProfiles reads a JSON header profiles file, a list of profiles that are evaluated in order
+func LoadProfiles(path string) ([]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileProfiles []Profile
+	if err := json.Unmarshal(data, &fileProfiles); err != nil {
+		return nil, fmt.Errorf("invalid header profiles file: %w", err)
+	}
+
+	if _, err := compileProfiles(fileProfiles); err != nil {
+		return nil, err
+	}
+
+	return fileProfiles, nil
+}
+
+// AddHeaders sets the headers of the first profile matching the request's URL, if any.
+// It returns the name of the applied profile, or an empty string if no profile matched.
+func AddHeaders(req *http.Request) string {
+	globalProfiles.RLock()
+	defer globalProfiles.RUnlock()
+
+	if len(globalProfiles.profiles) == 0 {
+		return ""
+	}
+
+	host := hostname(req.URL.Host)
+	URL := req.URL.String()
+
+	for i := range globalProfiles.profiles {
+		profile := &globalProfiles.profiles[i]
+		if !profile.match(host, URL) {
+			continue
+		}
+
+		for name, value := range profile.Headers {
+			if value == "" {
+				req.Header.Del(name)
+				continue
+			}
+			req.Header.Set(name, value)
+		}
+
+		return profile.Name
+	}
+
+	return ""
+}
+
+func compileProfiles(toCompile []Profile) ([]compiledProfile, error) {
+	compiled := make([]compiledProfile, 0, len(toCompile))
+
+	for i, profile := range toCompile {
+		id := fmt.Sprint(i)
+		if profile.Name != "" {
+			id = profile.Name
+		}
+
+		matchers := 0
+		for _, matcher := range []string{profile.Host, profile.Domain, profile.URLRegex} {
+			if matcher != "" {
+				matchers++
+			}
+		}
+		if matchers != 1 {
+			return nil, fmt.Errorf("header profile %s: exactly one of host, domain or url_regex must be set", id)
+		}
+
+		if len(profile.Headers) == 0 {
+			return nil, fmt.Errorf("header profile %s: no headers", id)
+		}
+
+		for name, value := range profile.Headers {
+			if !httpguts.ValidHeaderFieldName(name) {
+				return nil, fmt.Errorf("header profile %s: invalid header name %q", id, name)
+			}
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return nil, fmt.Errorf("header profile %s: invalid value for header %s", id, name)
+			}
+		}
+
+		c := compiledProfile{Profile: profile}
+		c.Host = strings.ToLower(profile.Host)
+		c.Domain = strings.ToLower(strings.TrimPrefix(profile.Domain, "."))
+
+		if profile.URLRegex != "" {
+			regex, err := regexp.Compile(profile.URLRegex)
+			if err != nil {
+				return nil, fmt.Errorf("header profile %s: invalid url_regex: %w", id, err)
+			}
+			c.regex = regex
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+func (p *compiledProfile) match(host, URL string) bool {
+	switch {
+	case p.Host != "":
+		return host == p.Host
+	case p.Domain != "":
+		return host == p.Domain || strings.HasSuffix(host, "."+p.Domain)
+	default:
+		return p.regex.MatchString(URL)
+	}
+}
+
+// hostname returns the lowercased host without its port
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
@@ -0,0 +1,122 @@
+package headers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProfiles(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestDefaultProfiles(t *testing.T) {
+	builtins, err := DefaultProfiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := compileProfiles(builtins); err != nil {
+		t.Fatalf("invalid built-in profiles: %v", err)
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectError bool
+	}{
+		{"valid", `[{"host": "example.com", "headers": {"User-Agent": "test"}}, {"domain": "example.org", "headers": {"Referer": ""}}, {"url_regex": "^https://example\\.net/api/", "headers": {"Accept": "application/json"}}]`, false},
+		{"empty", `[]`, false},
+		{"invalid JSON", `{"host": "example.com"}`, true},
+		{"no matcher", `[{"headers": {"User-Agent": "test"}}]`, true},
+		{"two matchers", `[{"host": "example.com", "domain": "example.com", "headers": {"User-Agent": "test"}}]`, true},
+		{"no headers", `[{"host": "example.com"}]`, true},
+		{"invalid regex", `[{"url_regex": "(", "headers": {"User-Agent": "test"}}]`, true},
+		{"invalid header name", `[{"host": "example.com", "headers": {"User Agent": "test"}}]`, true},
+		{"invalid header value", `[{"host": "example.com", "headers": {"User-Agent": "test\r\nX-Injected: 1"}}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadProfiles(writeProfiles(t, tt.content))
+			if (err != nil) != tt.expectError {
+				t.Errorf("expected error: %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestAddHeaders(t *testing.T) {
+	path := writeProfiles(t, `[
+		{"name": "override", "host": "www.npr.org", "headers": {"Referer": "https://example.com/", "Cache-Control": ""}},
+		{"name": "example", "domain": "example.com", "headers": {"User-Agent": "custom", "Accept-Language": "de"}}
+	]`)
+
+	if err := Start(path, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer Reset()
+
+	tests := []struct {
+		URL             string
+		expectedProfile string
+		expectedHeaders map[string]string
+	}{
+		{"https://www.tiktok.com/@user/video/1", "tiktok", map[string]string{"Sec-Fetch-Mode": "navigate", "Authority": "www.tiktok.com"}},
+		{"https://text.npr.org/1", "npr", map[string]string{"Referer": "https://www.npr.org/", "Cache-Control": "no-cache"}},
+		{"https://www.npr.org/", "override", map[string]string{"Referer": "https://example.com/", "Cache-Control": "", "User-Agent": "default"}},
+		{"https://static.EXAMPLE.com:8443/a", "example", map[string]string{"User-Agent": "custom", "Accept-Language": "de"}},
+		{"https://notexample.com/", "", map[string]string{"User-Agent": "default"}},
+		{"https://truthsocial.com/api/v1/statuses/123", "truthsocial-status-api", map[string]string{"Sec-Fetch-Mode": "navigate"}},
+		{"https://truthsocial.com/api/v1/accounts/lookup?acct=user", "truthsocial-status-api", map[string]string{"Sec-Fetch-Mode": "navigate"}},
+		{"https://truthsocial.com/api/v1/accounts/123/statuses", "truthsocial-accounts-api", map[string]string{"Sec-Fetch-Mode": "cors", "Accept": "application/json, text/plain, */*"}},
+		{"https://truthsocial.com/@user", "", map[string]string{"User-Agent": "default"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.URL, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("User-Agent", "default")
+			req.Header.Set("Cache-Control", "max-age=0")
+
+			if profile := AddHeaders(req); profile != tt.expectedProfile {
+				t.Errorf("expected profile %q, got %q", tt.expectedProfile, profile)
+			}
+
+			for name, expected := range tt.expectedHeaders {
+				if got := req.Header.Get(name); got != expected {
+					t.Errorf("expected %s: %q, got %q", name, expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDisableDefaults(t *testing.T) {
+	if err := Start("", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer Reset()
+
+	if len(Profiles()) != 0 {
+		t.Fatalf("expected no profiles, got %d", len(Profiles()))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.tiktok.com/", nil)
+	if profile := AddHeaders(req); profile != "" {
+		t.Errorf("expected no profile to be applied, got %q", profile)
+	}
+}
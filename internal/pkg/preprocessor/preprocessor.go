@@ -3,7 +3,7 @@
 // 1. Checks that the received seed is consistent and has the correct status
 // 2. Normalizes the seed's lowest level URLs
 // 3. Checks if the URLs should be excluded
-// 4. Removes any false-positive assets
+// 4. Removes any false-positive assets and the URLs disallowed by robots.txt
 // 5. Deduplicate the items
 // 6. Seencheck the items
 // 7. Builds the requests before handling them to the archiver
@@ -19,12 +19,13 @@ import (
 	"github.com/internetarchive/Zeno/internal/pkg/config"
 	"github.com/internetarchive/Zeno/internal/pkg/controler/pause"
 	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/log/crawllog"
 	"github.com/internetarchive/Zeno/internal/pkg/log/dumper"
 	"github.com/internetarchive/Zeno/internal/pkg/postprocessor/sitespecific/reddit"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/cookies"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/headers"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/robots"
 	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/seencheck"
-	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/sitespecific/npr"
-	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/sitespecific/tiktok"
-	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/sitespecific/truthsocial"
 	"github.com/internetarchive/Zeno/internal/pkg/source/hq"
 	"github.com/internetarchive/Zeno/internal/pkg/stats"
 	"github.com/internetarchive/Zeno/internal/pkg/utils"
@@ -186,6 +187,7 @@ func preprocess(workerID string, seed *models.Item) {
 					"item_id", items[i].GetShortID(),
 					"seed_id", seed.GetShortID(),
 					"url", items[i].GetURL().String())
+				logExclusion(items[i], crawllog.StatusOutOfScope, workerID)
 
 				if items[i].IsChild() || items[i].IsRedirection() {
 					items[i].GetParent().RemoveChild(items[i])
@@ -206,6 +208,7 @@ func preprocess(workerID string, seed *models.Item) {
 				"item_id", items[i].GetShortID(),
 				"seed_id", seed.GetShortID(),
 				"url", items[i].GetURL().String())
+			logExclusion(items[i], crawllog.StatusBlockedByUser, workerID)
 
 			if items[i].IsChild() || items[i].IsRedirection() {
 				items[i].GetParent().RemoveChild(items[i])
@@ -222,8 +225,26 @@ func preprocess(workerID string, seed *models.Item) {
 			if items[i].GetURL().GetParsed().Path == "" || items[i].GetURL().GetParsed().Path == "/" {
 				logger.Debug("removing child with empty path", "item_id", items[i].GetShortID(), "url", items[i].GetURL().Raw)
 				items[i].GetParent().RemoveChild(items[i])
+				continue
 			}
 		}
+
+		// Apply the robots.txt rules of the host, if enabled
+		if !robots.Allowed(items[i]) {
+			logger.Debug("URL excluded (disallowed by robots.txt)",
+				"item_id", items[i].GetShortID(),
+				"seed_id", seed.GetShortID(),
+				"url", items[i].GetURL().String())
+			crawllog.Write(crawllog.NewEntry(items[i], crawllog.StatusRobotsPrecluded, workerID))
+
+			if items[i].IsChild() || items[i].IsRedirection() {
+				items[i].GetParent().RemoveChild(items[i])
+				continue
+			}
+
+			items[i].SetStatus(models.ItemCompleted)
+			return
+		}
 	}
 
 	// Deduplicate items based on their URL and remove duplicates
@@ -246,7 +267,7 @@ func preprocess(workerID string, seed *models.Item) {
 		if err != nil {
 			logger.Warn("unable to seencheck seed", "seed_id", seed.GetShortID(), "err", err.Error(), "func", "preprocessor.preprocess")
 		}
-	} else {
+	} else if config.Get().UseSeencheck {
 		err = seencheck.SeencheckItem(seed)
 		if err != nil {
 			logger.Warn("unable to seencheck seed", "seed_id", seed.GetShortID(), "err", err.Error(), "func", "preprocessor.preprocess")
@@ -284,19 +305,16 @@ func preprocess(workerID string, seed *models.Item) {
 		// Apply configured User-Agent
 		req.Header.Set("User-Agent", config.Get().UserAgent)
 
-		switch {
-		case tiktok.IsTikTokURL(items[i].GetURL()):
-			tiktok.AddHeaders(req)
-		case npr.IsNPRURL(items[i].GetURL()):
-			npr.AddHeaders(req)
-		case reddit.IsRedditURL(items[i].GetURL()):
+		// Apply the cookies from the cookie jar, site-specific cookies are merged afterward
+		cookies.AddCookies(req)
+
+		if reddit.IsRedditURL(items[i].GetURL()) {
 			reddit.AddCookies(req)
-		case truthsocial.IsStatusAPIURL(items[i].GetURL()) ||
-			truthsocial.IsVideoAPIURL(items[i].GetURL()) ||
-			truthsocial.IsLookupURL(items[i].GetURL()):
-			truthsocial.AddStatusAPIHeaders(req)
-		case truthsocial.IsAccountsAPIURL(items[i].GetURL()):
-			truthsocial.AddAccountsAPIHeaders(req)
+		}
+
+		// Apply the headers of the matching site profile, if any
+		if profile := headers.AddHeaders(req); profile != "" {
+			logger.Debug("header profile applied", "item_id", items[i].GetShortID(), "seed_id", seed.GetShortID(), "url", items[i].GetURL().String(), "profile", profile)
 		}
 
 		items[i].GetURL().SetRequest(req)
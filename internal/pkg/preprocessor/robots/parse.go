@@ -0,0 +1,222 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rule is an Allow or Disallow line of a robots.txt group
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// group is a set of rules that apply to one or more user agents
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// robotsData is a parsed robots.txt file
+type robotsData struct {
+	groups   []*group
+	sitemaps []string
+}
+
+// rules is the result of a robots.txt evaluation for a given user agent
+type rules struct {
+	rules       []rule
+	crawlDelay  time.Duration
+	sitemaps    []string
+	allowAll    bool
+	disallowAll bool
+}
+
+// parse reads a robots.txt file as described in RFC 9309, unknown lines are ignored
+func parse(r io.Reader) *robotsData {
+	var (
+		data         = &robotsData{}
+		currentGroup *group
+		// Consecutive user-agent lines belong to the same group
+		lastWasAgent bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRobotsSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent || currentGroup == nil {
+				currentGroup = &group{}
+				data.groups = append(data.groups, currentGroup)
+			}
+			currentGroup.agents = append(currentGroup.agents, strings.ToLower(value))
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if currentGroup == nil {
+				continue
+			}
+
+			// An empty Disallow allows everything, which is the default
+			if value == "" {
+				continue
+			}
+
+			currentGroup.rules = append(currentGroup.rules, rule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			lastWasAgent = false
+			if currentGroup == nil {
+				continue
+			}
+
+			delay, err := strconv.ParseFloat(value, 64)
+			if err != nil || delay < 0 {
+				continue
+			}
+
+			currentGroup.crawlDelay = time.Duration(delay * float64(time.Second))
+		case "sitemap":
+			// Sitemaps are not tied to any group
+			if value != "" {
+				data.sitemaps = append(data.sitemaps, value)
+			}
+		default:
+			lastWasAgent = false
+		}
+	}
+
+	return data
+}
+
+// rulesFor returns the rules that apply to the given user agent.
+// A group applies if its user agent token is contained in the user agent (case-insensitive), the longest token wins
+// and all the groups with that token are merged. If no group applies, the "*" groups are used.
+func (d *robotsData) rulesFor(userAgent string) *rules {
+	userAgent = strings.ToLower(userAgent)
+
+	var (
+		bestToken string
+		matched   []*group
+		wildcard  []*group
+	)
+
+	for _, g := range d.groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				wildcard = append(wildcard, g)
+				continue
+			}
+
+			if agent == "" || !strings.Contains(userAgent, agent) {
+				continue
+			}
+
+			if len(agent) > len(bestToken) {
+				bestToken = agent
+				matched = []*group{g}
+			} else if agent == bestToken {
+				matched = append(matched, g)
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		matched = wildcard
+	}
+
+	r := &rules{sitemaps: d.sitemaps}
+	for _, g := range matched {
+		r.rules = append(r.rules, g.rules...)
+		if g.crawlDelay > r.crawlDelay {
+			r.crawlDelay = g.crawlDelay
+		}
+	}
+
+	return r
+}
+
+// allowed returns true if the path (including the query) can be crawled.
+// The longest matching rule wins, Allow wins on ties.
+func (r *rules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+
+	if r.allowAll || path == "/robots.txt" {
+		return true
+	}
+
+	if path == "" {
+		path = "/"
+	}
+
+	var (
+		bestLength = -1
+		allow      = true
+	)
+
+	for _, rule := range r.rules {
+		if !match(rule.pattern, path) {
+			continue
+		}
+
+		if len(rule.pattern) > bestLength || (len(rule.pattern) == bestLength && rule.allow) {
+			bestLength = len(rule.pattern)
+			allow = rule.allow
+		}
+	}
+
+	return allow
+}
+
+// match reports whether path matches the robots.txt pattern, where * matches any sequence
+// of characters and a trailing $ anchors the pattern at the end of the path
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+
+	// The first part must be a prefix of the path
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	position := len(parts[0])
+
+	for i := 1; i < len(parts); i++ {
+		// The last part has to match the end of the path if the pattern is anchored
+		if i == len(parts)-1 && anchored {
+			return len(path)-position >= len(parts[i]) && strings.HasSuffix(path, parts[i])
+		}
+
+		index := strings.Index(path[position:], parts[i])
+		if index < 0 {
+			return false
+		}
+		position += index + len(parts[i])
+	}
+
+	return !anchored || position == len(path)
+}
@@ -0,0 +1,286 @@
+// Package robots fetches, caches and evaluates the robots.txt files of the crawled hosts.
+// The robots.txt files are fetched with the WARC-writing client, so they end up archived like any other capture.
+package robots
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+// Mode defines how robots.txt files are used
+type Mode string
+
+const (
+	// ModeIgnore never fetches robots.txt files
+	ModeIgnore Mode = "ignore"
+	// ModeObey applies the robots.txt rules to every URL
+	ModeObey Mode = "obey"
+	// ModeObeyOutlinks applies the robots.txt rules to seeds, outlinks and redirections but always fetches the page assets
+	ModeObeyOutlinks Mode = "obey-outlinks"
+)
+
+const (
+	// maxRobotsSize is the maximum number of bytes of a robots.txt file that are parsed, as recommended by RFC 9309
+	maxRobotsSize = 500 * 1024
+	// maxRedirects is the maximum number of redirections followed to get a robots.txt file
+	maxRedirects = 5
+	// cacheTTL is how long a robots.txt file is cached
+	cacheTTL = 24 * time.Hour
+	// errorCacheTTL is how long an unreachable robots.txt file (server error) is cached
+	errorCacheTTL = 30 * time.Minute
+	// maxCacheSize is the number of hosts kept in the cache before evicting entries
+	maxCacheSize = 100000
+)
+
+// Settings configures the robots.txt handling
+type Settings struct {
+	Mode Mode
+	// UserAgent is matched against the User-agent lines of the robots.txt files
+	UserAgent string
+	// MaxCrawlDelay caps the Crawl-delay directives, 0 means no cap
+	MaxCrawlDelay time.Duration
+	// Fetch executes the robots.txt requests
+	Fetch func(req *http.Request) (*http.Response, error)
+	// OnCrawlDelay is called with the Crawl-delay of a host when its robots.txt is fetched, if any
+	OnCrawlDelay func(host string, delay time.Duration)
+	// OnSitemaps is called with the Sitemap URLs of a robots.txt when it is fetched, if any
+	OnSitemaps func(sitemaps []*models.URL, via string)
+}
+
+type cacheEntry struct {
+	ready   chan struct{}
+	rules   *rules
+	expires time.Time
+}
+
+type robotsManager struct {
+	sync.Mutex
+	settings Settings
+	cache    map[string]*cacheEntry
+}
+
+var (
+	globalRobots *robotsManager
+	logger       *log.FieldedLogger
+
+	// ErrInvalidMode is returned when the robots mode is unknown
+	ErrInvalidMode = errors.New("invalid robots mode, must be one of: obey, ignore, obey-outlinks")
+)
+
+// ValidMode returns true if the mode is one of the supported modes
+func ValidMode(mode string) bool {
+	switch Mode(mode) {
+	case ModeIgnore, ModeObey, ModeObeyOutlinks:
+		return true
+	}
+	return false
+}
+
+// Start initializes the robots.txt handling with the given settings
+func Start(settings Settings) error {
+	if !ValidMode(string(settings.Mode)) {
+		return ErrInvalidMode
+	}
+
+	if settings.Mode != ModeIgnore && settings.Fetch == nil {
+		return errors.New("robots fetch function is required")
+	}
+
+	log.Start()
+	logger = log.NewFieldedLogger(&log.Fields{
+		"component": "preprocessor.robots",
+	})
+
+	globalRobots = &robotsManager{
+		settings: settings,
+		cache:    make(map[string]*cacheEntry),
+	}
+
+	return nil
+}
+
+// Reset disables the robots.txt handling and empties the cache
+func Reset() {
+	globalRobots = nil
+}
+
+// Enabled returns true if robots.txt files are fetched and obeyed
+func Enabled() bool {
+	return globalRobots != nil && globalRobots.settings.Mode != ModeIgnore
+}
+
+// Allowed returns true if the item can be crawled according to the robots.txt of its host.
+// The robots.txt file is fetched on the first URL of a host, and then kept in cache.
+func Allowed(item *models.Item) bool {
+	if !Enabled() {
+		return true
+	}
+
+	// Assets are always fetched in obey-outlinks mode
+	if globalRobots.settings.Mode == ModeObeyOutlinks && item.IsChild() {
+		return true
+	}
+
+	URL := item.GetURL().GetParsed()
+	if URL == nil || (URL.Scheme != "http" && URL.Scheme != "https") {
+		return true
+	}
+
+	rules := globalRobots.get(item.GetURL())
+
+	return rules.allowed(URL.EscapedPath() + queryString(URL))
+}
+
+func queryString(URL *url.URL) string {
+	if URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + URL.RawQuery
+}
+
+// get returns the rules of the URL's host, fetching its robots.txt if it is not cached.
+// Concurrent calls for the same host wait for the same fetch.
+func (m *robotsManager) get(URL *models.URL) *rules {
+	parsed := URL.GetParsed()
+	key := parsed.Scheme + "://" + parsed.Host
+
+	m.Lock()
+	entry, ok := m.cache[key]
+	if ok {
+		select {
+		case <-entry.ready:
+			if time.Now().Before(entry.expires) {
+				m.Unlock()
+				return entry.rules
+			}
+		default:
+			// Fetch in progress
+			m.Unlock()
+			<-entry.ready
+			return entry.rules
+		}
+	}
+
+	entry = &cacheEntry{ready: make(chan struct{})}
+	m.evict()
+	m.cache[key] = entry
+	m.Unlock()
+
+	robotsURL := &url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/robots.txt"}
+
+	hostRules, ttl := m.fetch(robotsURL)
+	entry.rules = hostRules
+	entry.expires = time.Now().Add(ttl)
+	close(entry.ready)
+
+	if hostRules.crawlDelay > 0 && m.settings.OnCrawlDelay != nil {
+		delay := hostRules.crawlDelay
+		if m.settings.MaxCrawlDelay > 0 && delay > m.settings.MaxCrawlDelay {
+			delay = m.settings.MaxCrawlDelay
+		}
+		m.settings.OnCrawlDelay(parsed.Host, delay)
+	}
+
+	if len(hostRules.sitemaps) > 0 && m.settings.OnSitemaps != nil {
+		var sitemaps []*models.URL
+		for _, sitemap := range hostRules.sitemaps {
+			sitemaps = append(sitemaps, &models.URL{Raw: sitemap, Hops: URL.GetHops() + 1})
+		}
+		m.settings.OnSitemaps(sitemaps, robotsURL.String())
+	}
+
+	return entry.rules
+}
+
+// evict removes the expired entries when the cache is full, then arbitrary entries if it is still full.
+// Must be called with the lock held.
+func (m *robotsManager) evict() {
+	if len(m.cache) < maxCacheSize {
+		return
+	}
+
+	now := time.Now()
+	for key, entry := range m.cache {
+		select {
+		case <-entry.ready:
+			if now.After(entry.expires) {
+				delete(m.cache, key)
+			}
+		default:
+		}
+	}
+
+	for key, entry := range m.cache {
+		if len(m.cache) < maxCacheSize {
+			return
+		}
+
+		select {
+		case <-entry.ready:
+			delete(m.cache, key)
+		default:
+		}
+	}
+}
+
+// fetch gets and parses a robots.txt file, following redirections.
+// As per RFC 9309, a 4xx means that everything is allowed and a 5xx that everything is disallowed.
+// Network errors allow everything, the URLs themselves will fail to be fetched anyway.
+func (m *robotsManager) fetch(robotsURL *url.URL) (*rules, time.Duration) {
+	currentURL := robotsURL
+
+	for redirects := 0; redirects <= maxRedirects; redirects++ {
+		req, err := http.NewRequest(http.MethodGet, currentURL.String(), nil)
+		if err != nil {
+			logger.Debug("unable to create robots.txt request", "url", currentURL.String(), "err", err.Error())
+			return &rules{allowAll: true}, errorCacheTTL
+		}
+		req.Header.Set("User-Agent", m.settings.UserAgent)
+
+		resp, err := m.settings.Fetch(req)
+		if err != nil {
+			logger.Debug("unable to fetch robots.txt", "url", currentURL.String(), "err", err.Error())
+			return &rules{allowAll: true}, errorCacheTTL
+		}
+
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "":
+			location, err := currentURL.Parse(resp.Header.Get("Location"))
+			drain(resp)
+			if err != nil {
+				return &rules{allowAll: true}, cacheTTL
+			}
+			currentURL = location
+			continue
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			data := parse(io.LimitReader(resp.Body, maxRobotsSize))
+			drain(resp)
+			logger.Debug("fetched robots.txt", "url", currentURL.String(), "groups", len(data.groups), "sitemaps", len(data.sitemaps))
+			return data.rulesFor(m.settings.UserAgent), cacheTTL
+		case resp.StatusCode >= 500:
+			drain(resp)
+			logger.Debug("robots.txt unreachable, disallowing the host", "url", currentURL.String(), "status_code", resp.StatusCode)
+			return &rules{disallowAll: true}, errorCacheTTL
+		default:
+			drain(resp)
+			return &rules{allowAll: true}, cacheTTL
+		}
+	}
+
+	logger.Debug("too many redirections for robots.txt, allowing the host", "url", robotsURL.String())
+
+	return &rules{allowAll: true}, cacheTTL
+}
+
+// drain consumes and closes the body, needed for the WARC writer to finish writing the record
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,252 @@
+package robots
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+const robotsFile = `# robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 1
+
+User-agent: archive.org_bot
+User-agent: otherbot
+Disallow: /no-archive
+Crawl-delay: 2.5
+
+User-agent: archive.org_bot
+Disallow: /also-no-archive # comment
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+const userAgent = "Mozilla/5.0 (compatible; archive.org_bot +http://archive.org/details/archive.org_bot) Zeno/v2.0.0 warc/v0.8.73"
+
+func TestRulesFor(t *testing.T) {
+	data := parse(strings.NewReader(robotsFile))
+
+	if len(data.groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(data.groups))
+	}
+
+	if len(data.sitemaps) != 1 || data.sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("unexpected sitemaps: %v", data.sitemaps)
+	}
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		path       string
+		allowed    bool
+		crawlDelay time.Duration
+	}{
+		{"specific group applies", userAgent, "/no-archive/page", false, 2500 * time.Millisecond},
+		{"specific groups are merged", userAgent, "/also-no-archive", false, 2500 * time.Millisecond},
+		{"wildcard group ignored when a specific group applies", userAgent, "/private/page", true, 2500 * time.Millisecond},
+		{"wildcard group", "SomeBot/1.0", "/private/page", false, time.Second},
+		{"longest match wins", "SomeBot/1.0", "/private/public/page", true, time.Second},
+		{"anchored wildcard", "SomeBot/1.0", "/docs/file.pdf", false, time.Second},
+		{"anchored wildcard does not match a longer path", "SomeBot/1.0", "/docs/file.pdf?download=1", true, time.Second},
+		{"robots.txt is always allowed", "SomeBot/1.0", "/robots.txt", true, time.Second},
+		{"root", "SomeBot/1.0", "/", true, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := data.rulesFor(tt.userAgent)
+
+			if r.allowed(tt.path) != tt.allowed {
+				t.Errorf("expected allowed=%v for %s", tt.allowed, tt.path)
+			}
+
+			if r.crawlDelay != tt.crawlDelay {
+				t.Errorf("expected crawl delay %s, got %s", tt.crawlDelay, r.crawlDelay)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish.asp", false},
+		{"/fish*", "/fishheads/yummy.html", true},
+		{"/*.php", "/folder/filename.php?parameters", true},
+		{"/*.php", "/windows.PHP", false},
+		{"/*.php$", "/filename.php", true},
+		{"/*.php$", "/filename.php/", false},
+		{"/fish*.php", "/fishheads/catfish.php?parameters", true},
+		{"/fish*.php", "/Fish.PHP", false},
+		{"/a*b*c$", "/a-b-c", true},
+		{"/a*b*c$", "/a-b-c-d", false},
+		{"/exact$", "/exact", true},
+		{"/exact$", "/exactly", false},
+	}
+
+	for _, tt := range tests {
+		if got := match(tt.pattern, tt.path); got != tt.match {
+			t.Errorf("match(%q, %q) = %v, expected %v", tt.pattern, tt.path, got, tt.match)
+		}
+	}
+}
+
+func newResponse(status int, body string, headers map[string]string) *http.Response {
+	resp := &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func newItem(t *testing.T, raw string) *models.Item {
+	t.Helper()
+
+	URL := &models.URL{Raw: raw}
+	if err := URL.Parse(); err != nil {
+		t.Fatalf("unable to parse %s: %v", raw, err)
+	}
+
+	return models.NewItem("id", URL, "")
+}
+
+func TestAllowed(t *testing.T) {
+	defer Reset()
+
+	var (
+		fetches     atomic.Int64
+		crawlDelays = map[string]time.Duration{}
+		sitemaps    []*models.URL
+	)
+
+	err := Start(Settings{
+		Mode:          ModeObey,
+		UserAgent:     userAgent,
+		MaxCrawlDelay: 2 * time.Second,
+		Fetch: func(req *http.Request) (*http.Response, error) {
+			fetches.Add(1)
+
+			switch req.URL.Host {
+			case "example.com":
+				return newResponse(http.StatusOK, robotsFile, nil), nil
+			case "redirect.example.com":
+				if req.URL.Path == "/robots.txt" {
+					return newResponse(http.StatusMovedPermanently, "", map[string]string{"Location": "/real-robots.txt"}), nil
+				}
+				return newResponse(http.StatusOK, "User-agent: *\nDisallow: /", nil), nil
+			case "missing.example.com":
+				return newResponse(http.StatusNotFound, "", nil), nil
+			case "broken.example.com":
+				return newResponse(http.StatusServiceUnavailable, "", nil), nil
+			}
+
+			return nil, io.ErrUnexpectedEOF
+		},
+		OnCrawlDelay: func(host string, delay time.Duration) {
+			crawlDelays[host] = delay
+		},
+		OnSitemaps: func(URLs []*models.URL, via string) {
+			sitemaps = append(sitemaps, URLs...)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://example.com/page", true},
+		{"https://example.com/no-archive", false},
+		{"https://example.com/also-no-archive?a=b", false},
+		{"https://redirect.example.com/page", false},
+		{"https://missing.example.com/page", true},
+		{"https://broken.example.com/page", false},
+		{"https://unreachable.example.com/page", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := Allowed(newItem(t, tt.url)); got != tt.allowed {
+				t.Errorf("expected allowed=%v, got %v", tt.allowed, got)
+			}
+		})
+	}
+
+	// example.com robots.txt must have been fetched once, redirect.example.com twice (redirection)
+	if fetches.Load() != 6 {
+		t.Errorf("expected 6 fetches, got %d", fetches.Load())
+	}
+
+	if crawlDelays["example.com"] != 2*time.Second {
+		t.Errorf("expected the crawl delay to be capped to 2s, got %s", crawlDelays["example.com"])
+	}
+
+	if len(sitemaps) != 1 || sitemaps[0].Raw != "https://example.com/sitemap.xml" || sitemaps[0].GetHops() != 1 {
+		t.Errorf("unexpected sitemaps: %+v", sitemaps)
+	}
+}
+
+func TestAllowedObeyOutlinks(t *testing.T) {
+	defer Reset()
+
+	err := Start(Settings{
+		Mode:      ModeObeyOutlinks,
+		UserAgent: userAgent,
+		Fetch: func(req *http.Request) (*http.Response, error) {
+			return newResponse(http.StatusOK, "User-agent: *\nDisallow: /", nil), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	seed := newItem(t, "https://example.com/page")
+	if Allowed(seed) {
+		t.Error("expected the seed to be disallowed")
+	}
+
+	assetURL := &models.URL{Raw: "https://example.com/style.css"}
+	if err := assetURL.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := models.NewItem("asset", assetURL, "")
+	if err := seed.AddChild(asset, models.ItemGotChildren); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Allowed(asset) {
+		t.Error("expected the asset to be allowed in obey-outlinks mode")
+	}
+}
+
+func TestStartInvalidMode(t *testing.T) {
+	defer Reset()
+
+	if err := Start(Settings{Mode: "sometimes"}); err != ErrInvalidMode {
+		t.Errorf("expected ErrInvalidMode, got %v", err)
+	}
+
+	if Enabled() {
+		t.Error("robots should not be enabled")
+	}
+}
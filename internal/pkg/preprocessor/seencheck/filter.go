@@ -0,0 +1,144 @@
+package seencheck
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"sync/atomic"
+)
+
+// filterMagic starts the files the filters are persisted to, followed by the format version
+const (
+	filterMagic   = "ZSBF"
+	filterVersion = 1
+)
+
+var errFilterMismatch = errors.New("persisted filter doesn't match the configured size")
+
+// filter is a bloom filter of the seencheck keys, answering "definitely not seen" without reading the database.
+// It's safe for concurrent use.
+type filter struct {
+	words []uint64
+	m     uint64 // number of bits
+	k     uint32 // number of hash functions
+}
+
+// newFilter returns a filter sized for capacity keys at the false positive rate fpRate
+func newFilter(capacity int, fpRate float64) *filter {
+	n := math.Max(float64(capacity), 1)
+	m := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint32(math.Max(math.Round(float64(m)/n*math.Ln2), 1))
+
+	return &filter{
+		words: make([]uint64, m/64),
+		m:     m,
+		k:     k,
+	}
+}
+
+// locations returns the two base hashes of the key, combined to get the k bit locations
+func locations(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (f *filter) add(key string) {
+	h1, h2 := locations(key)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		atomic.OrUint64(&f.words[bit/64], 1<<(bit%64))
+	}
+}
+
+// mayContain returns false if the key was never added, true if it probably was
+func (f *filter) mayContain(key string) bool {
+	h1, h2 := locations(key)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		if atomic.LoadUint64(&f.words[bit/64])&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// save writes the filter to the file, atomically
+func (f *filter) save(path string) error {
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	w.WriteString(filterMagic)
+	binary.Write(w, binary.LittleEndian, uint32(filterVersion))
+	binary.Write(w, binary.LittleEndian, f.m)
+	binary.Write(w, binary.LittleEndian, f.k)
+	var word [8]byte
+	for i := range f.words {
+		binary.LittleEndian.PutUint64(word[:], atomic.LoadUint64(&f.words[i]))
+		w.Write(word[:])
+	}
+
+	err = errors.Join(w.Flush(), file.Sync(), file.Close())
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// load reads the filter persisted in the file, that must have the same size
+func (f *filter) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+		M       uint64
+		K       uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	if string(header.Magic[:]) != filterMagic || header.Version != filterVersion {
+		return errors.New("invalid filter file")
+	}
+
+	if header.M != f.m || header.K != f.k {
+		return errFilterMismatch
+	}
+
+	var word [8]byte
+	for i := range f.words {
+		if _, err := io.ReadFull(r, word[:]); err != nil {
+			clear(f.words)
+			return err
+		}
+		f.words[i] = binary.LittleEndian.Uint64(word[:])
+	}
+
+	if _, err := r.ReadByte(); err != io.EOF {
+		clear(f.words)
+		return errors.New("invalid filter file")
+	}
+
+	return nil
+}
@@ -1,48 +1,424 @@
 package seencheck
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"hash/fnv"
+	"os"
 	"path"
 	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	"github.com/internetarchive/Zeno/internal/pkg/stats"
 	"github.com/internetarchive/Zeno/pkg/models"
 	"github.com/philippgille/gokv/leveldb"
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
+const (
+	// HashFNV64 keys the URLs with their 64-bit FNV-1a hash, the historical default
+	HashFNV64 = "fnv64"
+	// HashSHA256 keys the URLs with their SHA-256 hash truncated to 128 bits, for crawls large enough for FNV-64 to collide
+	HashSHA256 = "sha256"
+
+	// hashKey stores the hash function of the database, that can't be changed once URLs are seen
+	hashKey = "_hash"
+	// canonicalizationKey stores the canonicalization mode of the URLs hashed, that can't be changed either
+	canonicalizationKey = "_canonicalization"
+)
+
+// Settings configures the local seencheck
+type Settings struct {
+	// TTL is how long a URL is considered seen after its capture, 0 meaning forever
+	TTL time.Duration
+	// TTLRules override the TTL of the URLs they match, the first matching rule wins
+	TTLRules []TTLRule
+	// Hash is the hash function the URLs are keyed with, HashFNV64 if empty
+	Hash string
+	// FilterCapacity is the number of URLs the in-memory filter is sized for, 0 disables the filter
+	FilterCapacity int
+	// FilterFPRate is the false positive rate of the filter at its capacity
+	FilterFPRate float64
+	// Overlays are the paths of other jobs whose seencheck databases are read, but never written, to
+	// skip the URLs they already captured
+	Overlays []string
+}
+
 // Seencheck holds the Seencheck database and the seen counter
 type Seencheck struct {
 	Count *int64
 	DB    leveldb.Store
+
+	ttl        ttlPolicy
+	hash       string
+	overlays   []*goleveldb.DB
+	filter     *filter
+	filterPath string
+	startTime  time.Time
+	nowFunc    func() time.Time
 }
 
 var (
 	globalSeencheck *Seencheck
+	logger          *log.FieldedLogger
+
+	// ErrHashMismatch is returned when the configured hash function isn't the one of the existing database
+	ErrHashMismatch = errors.New("seencheck hash function doesn't match the one of the database")
+	// ErrCanonicalizationMismatch is returned when the configured canonicalization isn't the one of the existing database
+	ErrCanonicalizationMismatch = errors.New("seencheck canonicalization doesn't match the one of the database")
 )
 
-func Start(jobPath string) (err error) {
-	count := int64(0)
-	globalSeencheck = new(Seencheck)
-	globalSeencheck.Count = &count
-	globalSeencheck.DB, err = leveldb.NewStore(leveldb.Options{Path: path.Join(jobPath, "seencheck")})
-	return err
+// Start opens the seencheck database of the job. The URLs seen are crawled again once their capture is older
+// than their TTL. If enabled, the in-memory filter persisted when the seencheck was last closed is loaded,
+// or rebuilt from the database if it's missing or doesn't match the settings.
+func Start(jobPath string, settings Settings) (err error) {
+	log.Start()
+	logger = log.NewFieldedLogger(&log.Fields{
+		"component": "preprocessor.seencheck",
+	})
+
+	compiledRules, err := compileTTLRules(settings.TTLRules)
+	if err != nil {
+		return err
+	}
+
+	if settings.Hash == "" {
+		settings.Hash = HashFNV64
+	}
+	if settings.Hash != HashFNV64 && settings.Hash != HashSHA256 {
+		return fmt.Errorf("invalid seencheck hash %q, must be one of: %s, %s", settings.Hash, HashFNV64, HashSHA256)
+	}
+
+	sc := &Seencheck{
+		Count:      new(int64),
+		ttl:        ttlPolicy{defaultTTL: settings.TTL, rules: compiledRules},
+		hash:       settings.Hash,
+		filterPath: path.Join(jobPath, "seencheck.filter"),
+		startTime:  time.Now(),
+		nowFunc:    time.Now,
+	}
+
+	dbPath := path.Join(jobPath, "seencheck")
+
+	var rebuild bool
+	if settings.FilterCapacity > 0 {
+		sc.filter = newFilter(settings.FilterCapacity, settings.FilterFPRate)
+
+		// The file is removed once loaded, so that the filter is rebuilt if the crawl doesn't stop cleanly.
+		// The persisted filter doesn't have the keys of the overlays, that may have changed since.
+		if len(settings.Overlays) > 0 {
+			rebuild = true
+		} else if err := sc.filter.load(sc.filterPath); err != nil {
+			if !os.IsNotExist(err) {
+				logger.Warn("unable to load the persisted filter, rebuilding it", "err", err.Error())
+			}
+			rebuild = true
+		}
+		os.Remove(sc.filterPath)
+	}
+
+	canonicalization := canonicalizer.Mode()
+
+	for _, overlayPath := range settings.Overlays {
+		overlay, err := openOverlay(overlayPath, sc.hash, canonicalization, sc.filter, rebuild)
+		if err != nil {
+			sc.closeOverlays()
+			return fmt.Errorf("unable to open the seencheck of %s: %w", overlayPath, err)
+		}
+		sc.overlays = append(sc.overlays, overlay)
+	}
+
+	db, err := goleveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		sc.closeOverlays()
+		return err
+	}
+
+	dbHash, dbCanonicalization, keys, err := scan(db, sc.filter, rebuild)
+	db.Close()
+	if err != nil {
+		sc.closeOverlays()
+		return err
+	}
+
+	if rebuild {
+		logger.Info("seencheck filter rebuilt", "keys", keys, "overlays", len(sc.overlays))
+	}
+
+	sc.DB, err = leveldb.NewStore(leveldb.Options{Path: dbPath})
+	if err != nil {
+		sc.closeOverlays()
+		return err
+	}
+
+	if err := checkHash(dbHash, keys, sc.hash); err != nil {
+		sc.DB.Close()
+		sc.closeOverlays()
+		return err
+	}
+
+	if err := checkCanonicalization(dbCanonicalization, keys, canonicalization); err != nil {
+		sc.DB.Close()
+		sc.closeOverlays()
+		return err
+	}
+
+	if dbHash == "" {
+		if err := sc.DB.Set(hashKey, sc.hash); err != nil {
+			sc.DB.Close()
+			sc.closeOverlays()
+			return err
+		}
+	}
+
+	if dbCanonicalization != canonicalization {
+		if err := sc.DB.Set(canonicalizationKey, canonicalization); err != nil {
+			sc.DB.Close()
+			sc.closeOverlays()
+			return err
+		}
+	}
+
+	globalSeencheck = sc
+
+	return nil
+}
+
+// openOverlay opens the seencheck database of another job read-only, adding its keys to the filter if rebuild is true
+func openOverlay(jobPath, hash, canonicalization string, f *filter, rebuild bool) (*goleveldb.DB, error) {
+	db, err := goleveldb.OpenFile(path.Join(jobPath, "seencheck"), &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		return nil, err
+	}
+
+	dbHash, dbCanonicalization, keys, err := scan(db, f, rebuild)
+	if err == nil {
+		err = checkHash(dbHash, keys, hash)
+	}
+	if err == nil {
+		err = checkCanonicalization(dbCanonicalization, keys, canonicalization)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (sc *Seencheck) closeOverlays() {
+	for _, overlay := range sc.overlays {
+		overlay.Close()
+	}
+	sc.overlays = nil
+}
+
+// checkHash returns an error if the hash function of a database with keys isn't the expected one.
+// The databases written before the hash function was stored were keyed with FNV-64.
+func checkHash(dbHash string, keys int, expected string) error {
+	if dbHash == "" && keys > 0 {
+		dbHash = HashFNV64
+	}
+
+	if dbHash != "" && dbHash != expected {
+		return fmt.Errorf("%w: %s is configured but the database uses %s", ErrHashMismatch, expected, dbHash)
+	}
+
+	return nil
+}
+
+// checkCanonicalization returns an error if the canonicalization mode of a database with keys isn't the expected one.
+// The databases written before the mode was stored weren't canonicalized.
+func checkCanonicalization(dbCanonicalization string, keys int, expected string) error {
+	if keys == 0 {
+		return nil
+	}
+
+	if dbCanonicalization == "" {
+		dbCanonicalization = canonicalizer.ModeNone
+	}
+
+	if dbCanonicalization != expected {
+		return fmt.Errorf("%w: %s is configured but the database uses %s", ErrCanonicalizationMismatch, expected, dbCanonicalization)
+	}
+
+	return nil
+}
+
+// scan reads the hash function and canonicalization mode stored in the database and counts its keys, adding them
+// to the filter if rebuild is true. Without rebuild, it stops at the first key.
+func scan(db *goleveldb.DB, f *filter, rebuild bool) (dbHash, dbCanonicalization string, keys int, err error) {
+	if err := getMeta(db, hashKey, &dbHash); err != nil {
+		return "", "", 0, fmt.Errorf("invalid seencheck hash: %w", err)
+	}
+
+	if err := getMeta(db, canonicalizationKey, &dbCanonicalization); err != nil {
+		return "", "", 0, fmt.Errorf("invalid seencheck canonicalization: %w", err)
+	}
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := string(iter.Key())
+		if isMetaKey(key) {
+			continue
+		}
+
+		keys++
+		if !rebuild {
+			break
+		}
+		f.add(key)
+	}
+
+	return dbHash, dbCanonicalization, keys, iter.Error()
+}
+
+// getMeta reads a setting stored in the database, value being left untouched if it isn't stored
+func getMeta(db *goleveldb.DB, key string, value *string) error {
+	raw, err := db.Get([]byte(key), nil)
+	if errors.Is(err, goleveldb.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, value)
+}
+
+// isMetaKey returns true for the keys storing the settings of the database, the keys of the URLs being hashes
+func isMetaKey(key string) bool {
+	return strings.HasPrefix(key, "_")
 }
 
+// Close persists the filter, if enabled, and closes the seencheck database
 func Close() {
+	if globalSeencheck.filter != nil {
+		if err := globalSeencheck.filter.save(globalSeencheck.filterPath); err != nil {
+			logger.Error("unable to persist the seencheck filter", "err", err.Error(), "func", "seencheck.Close")
+		}
+	}
+
 	globalSeencheck.DB.Close()
+	globalSeencheck.closeOverlays()
+}
+
+// Key returns the key of the URL in the seencheck database
+func Key(URL string) string {
+	return keyFor(globalSeencheck.hash, URL)
+}
+
+// keyFor returns the key of the URL with the given hash function
+func keyFor(hash, URL string) string {
+	URL = canonicalizer.Key(URL)
+
+	if hash == HashSHA256 {
+		sum := sha256.Sum256([]byte(URL))
+		return hex.EncodeToString(sum[:16])
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(URL))
+
+	return strconv.FormatUint(h.Sum64(), 10)
+}
+
+// formatValue returns the seencheck value of a URL: "<URL type>:<capture time as a Unix timestamp>"
+func formatValue(URLType string, capturedAt time.Time) string {
+	return URLType + ":" + strconv.FormatInt(capturedAt.Unix(), 10)
 }
 
-func isSeen(hash string) (found bool, value string) {
+func isSeen(hash string) (found bool, URLType string, capturedAt time.Time) {
+	// The URLs that aren't in the filter were never seen
+	if globalSeencheck.filter != nil {
+		if !globalSeencheck.filter.mayContain(hash) {
+			stats.SeencheckFilterHitsIncr()
+			return false, "", time.Time{}
+		}
+		stats.SeencheckFilterMissesIncr()
+	}
+
+	var value string
 	found, err := globalSeencheck.DB.Get(hash, &value)
 	if err != nil {
 		panic(err)
 	}
 
-	return found, value
+	if !found {
+		if found, URLType, capturedAt := lookupOverlays(hash); found {
+			return true, URLType, capturedAt
+		}
+
+		if globalSeencheck.filter != nil {
+			stats.SeencheckFilterFalsePositivesIncr()
+		}
+		return false, "", time.Time{}
+	}
+
+	URLType, capturedAt, ok := parseValue(value)
+	if ok {
+		return true, URLType, capturedAt
+	}
+
+	// Seen by a version without the capture time: considered captured when the job was started
+	// and updated, so that it doesn't get a new TTL on every restart
+	globalSeencheck.DB.Set(hash, formatValue(URLType, globalSeencheck.startTime))
+
+	return true, URLType, globalSeencheck.startTime
+}
+
+// parseValue returns the URL type and capture time of a seencheck value, ok being false
+// for the values written without the capture time
+func parseValue(value string) (URLType string, capturedAt time.Time, ok bool) {
+	URLType, timestamp, found := strings.Cut(value, ":")
+	if unix, err := strconv.ParseInt(timestamp, 10, 64); found && err == nil {
+		return URLType, time.Unix(unix, 0), true
+	}
+
+	return URLType, time.Time{}, false
 }
 
-func seen(hash, value string) {
-	globalSeencheck.DB.Set(hash, value)
+// lookupOverlays looks the key up in the seencheck databases of the other jobs
+func lookupOverlays(hash string) (found bool, URLType string, capturedAt time.Time) {
+	for _, overlay := range globalSeencheck.overlays {
+		raw, err := overlay.Get([]byte(hash), nil)
+		if errors.Is(err, goleveldb.ErrNotFound) {
+			continue
+		} else if err != nil {
+			panic(err)
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			continue
+		}
+
+		URLType, capturedAt, ok := parseValue(value)
+		if !ok {
+			capturedAt = globalSeencheck.startTime
+		}
+
+		return true, URLType, capturedAt
+	}
+
+	return false, "", time.Time{}
+}
+
+func seen(hash, URLType string, capturedAt time.Time) {
+	if globalSeencheck.filter != nil {
+		globalSeencheck.filter.add(hash)
+	}
+
+	globalSeencheck.DB.Set(hash, formatValue(URLType, capturedAt))
 	atomic.AddInt64(globalSeencheck.Count, 1)
 }
 
@@ -70,10 +446,10 @@ func seen(hash, value string) {
 // }
 
 // SeencheckItem gets the MaxDepth children of the given item and seencheck them locally.
-// The items that were seen before will be marked as seen.
+// The items that were seen before will be marked as seen, unless their capture is older than their TTL.
 // Different from the HQ seencheck, the local seencheck performs seencheck on top level seeds.
 func SeencheckItem(item *models.Item) error {
-	h := fnv.New64a()
+	now := globalSeencheck.nowFunc()
 
 	items, err := item.GetNodesAtLevel(item.GetMaxDepth())
 	if err != nil {
@@ -81,12 +457,7 @@ func SeencheckItem(item *models.Item) error {
 	}
 
 	for i := range items {
-		_, err = h.Write([]byte(items[i].GetURL().String()))
-		if err != nil {
-			return err
-		}
-
-		hash := strconv.FormatUint(h.Sum64(), 10)
+		hash := Key(items[i].GetURL().String())
 
 		var URLType string
 		if items[i].IsChild() {
@@ -95,25 +466,31 @@ func SeencheckItem(item *models.Item) error {
 			URLType = "seed"
 		}
 
-		found, foundType := isSeen(hash)
+		found, foundType, capturedAt := isSeen(hash)
 
 		if !found {
 			// First time seen: mark and process
-			seen(hash, URLType)
-			h.Reset()
+			seen(hash, URLType, now)
 			continue
 		}
 
 		if foundType == "asset" && URLType == "seed" {
 			// Promotion: allow processing again as seed
-			seen(hash, "seed")
-			h.Reset()
+			seen(hash, "seed", now)
+			continue
+		}
+
+		if globalSeencheck.ttl.expired(items[i].GetURL().GetParsed(), capturedAt, now) {
+			// Captured too long ago: process again, keeping the seed type if it was one
+			if foundType == "seed" {
+				URLType = foundType
+			}
+			seen(hash, URLType, now)
 			continue
 		}
 
 		// All other cases: already seen, skip
 		items[i].SetStatus(models.ItemSeen)
-		h.Reset()
 	}
 
 	return nil
@@ -0,0 +1,593 @@
+package seencheck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	"github.com/internetarchive/Zeno/internal/pkg/stats"
+	"github.com/internetarchive/Zeno/pkg/models"
+)
+
+func TestLoadTTLRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectError bool
+	}{
+		{"valid", `[{"host": "example.com", "ttl": "1h"}, {"domain": "example.org", "ttl": "0"}, {"regex": "/news/", "ttl": "10m"}]`, false},
+		{"empty", `[]`, false},
+		{"invalid JSON", `{"host": "example.com"}`, true},
+		{"no matcher", `[{"ttl": "1h"}]`, true},
+		{"two matchers", `[{"host": "example.com", "domain": "example.com", "ttl": "1h"}]`, true},
+		{"invalid regex", `[{"regex": "(", "ttl": "1h"}]`, true},
+		{"missing ttl", `[{"host": "example.com"}]`, true},
+		{"negative ttl", `[{"host": "example.com", "ttl": "-1h"}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesPath := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(rulesPath, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadTTLRules(rulesPath)
+			if (err != nil) != tt.expectError {
+				t.Errorf("expected error: %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func startTestSeencheck(t *testing.T, ttl time.Duration, rules []TTLRule) *time.Time {
+	t.Helper()
+
+	if err := Start(t.TempDir(), Settings{TTL: ttl, TTLRules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Close)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	globalSeencheck.startTime = now
+	globalSeencheck.nowFunc = func() time.Time { return now }
+
+	return &now
+}
+
+// isSeenItem seenchecks a seed of the URL and returns true if it was marked as seen
+func isSeenItem(t *testing.T, URL string) bool {
+	t.Helper()
+
+	item := models.NewItem("seed", &models.URL{Raw: URL}, "")
+	if err := item.GetURL().Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SeencheckItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	return item.GetStatus() == models.ItemSeen
+}
+
+func TestSeencheckTTL(t *testing.T) {
+	now := startTestSeencheck(t, 24*time.Hour, []TTLRule{
+		{Regex: `^https://example\.com/news/`, TTL: "10m"},
+		{Domain: "example.org", TTL: "0"},
+	})
+
+	urls := []string{"https://example.com/", "https://example.com/news/", "https://www.example.org/"}
+	for _, URL := range urls {
+		if isSeenItem(t, URL) {
+			t.Errorf("%s: expected the first capture not to be seen", URL)
+		}
+		if !isSeenItem(t, URL) {
+			t.Errorf("%s: expected the second capture to be seen", URL)
+		}
+	}
+
+	tests := []struct {
+		elapsed  time.Duration
+		URL      string
+		expected bool
+	}{
+		{5 * time.Minute, "https://example.com/news/", true},
+		{10 * time.Minute, "https://example.com/news/", false},
+		// Seen again at 10 minutes, the TTL starts over
+		{15 * time.Minute, "https://example.com/news/", true},
+		{12 * time.Hour, "https://example.com/", true},
+		{24 * time.Hour, "https://example.com/", false},
+		{24 * time.Hour, "https://example.com/", true},
+		{365 * 24 * time.Hour, "https://www.example.org/", true},
+	}
+
+	start := *now
+	for _, tt := range tests {
+		*now = start.Add(tt.elapsed)
+		if seen := isSeenItem(t, tt.URL); seen != tt.expected {
+			t.Errorf("%s after %s: expected seen to be %v, got %v", tt.URL, tt.elapsed, tt.expected, seen)
+		}
+	}
+}
+
+func TestSeencheckLegacyValues(t *testing.T) {
+	now := startTestSeencheck(t, time.Hour, nil)
+
+	// Values written by the versions without the capture time
+	for URL, URLType := range map[string]string{"https://example.com/a": "seed", "https://example.com/b": "asset"} {
+		h := fnv.New64a()
+		h.Write([]byte(URL))
+		globalSeencheck.DB.Set(strconv.FormatUint(h.Sum64(), 10), URLType)
+	}
+
+	if !isSeenItem(t, "https://example.com/a") {
+		t.Error("expected the legacy seed to be seen")
+	}
+
+	// The asset is promoted to a seed
+	if isSeenItem(t, "https://example.com/b") {
+		t.Error("expected the legacy asset not to be seen as a seed")
+	}
+
+	// Considered captured when the job was started
+	*now = now.Add(time.Hour)
+	if isSeenItem(t, "https://example.com/a") {
+		t.Error("expected the legacy seed to expire after the TTL")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := newFilter(10000, 0.01)
+
+	for i := 0; i < 10000; i++ {
+		f.add(fmt.Sprintf("seen-%d", i))
+	}
+
+	for i := 0; i < 10000; i++ {
+		if !f.mayContain(fmt.Sprintf("seen-%d", i)) {
+			t.Fatalf("expected key %d to be in the filter", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.mayContain(fmt.Sprintf("unseen-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Errorf("expected a false positive rate around 1%%, got %d false positives out of 10000", falsePositives)
+	}
+
+	filterPath := filepath.Join(t.TempDir(), "seencheck.filter")
+	if err := f.save(filterPath); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newFilter(10000, 0.01)
+	if err := loaded.load(filterPath); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10000; i++ {
+		if !loaded.mayContain(fmt.Sprintf("seen-%d", i)) {
+			t.Fatalf("expected key %d to be in the loaded filter", i)
+		}
+	}
+
+	if err := newFilter(20000, 0.01).load(filterPath); !errors.Is(err, errFilterMismatch) {
+		t.Errorf("expected a mismatch error, got %v", err)
+	}
+}
+
+func TestSeencheckFilterPersistence(t *testing.T) {
+	stats.Init()
+
+	jobPath := t.TempDir()
+	filterPath := filepath.Join(jobPath, "seencheck.filter")
+	settings := Settings{FilterCapacity: 1000, FilterFPRate: 0.01}
+
+	if err := Start(jobPath, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	hits := stats.SeencheckFilterHitsGet()
+	if isSeenItem(t, "https://example.com/a") {
+		t.Error("expected the first capture not to be seen")
+	}
+	if stats.SeencheckFilterHitsGet() != hits+1 {
+		t.Error("expected the first lookup to be answered by the filter")
+	}
+	Close()
+
+	if _, err := os.Stat(filterPath); err != nil {
+		t.Fatalf("expected the filter to be persisted: %v", err)
+	}
+
+	if err := Start(jobPath, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filterPath); !os.IsNotExist(err) {
+		t.Error("expected the persisted filter to be removed once loaded")
+	}
+
+	misses := stats.SeencheckFilterMissesGet()
+	if !isSeenItem(t, "https://example.com/a") {
+		t.Error("expected the URL to be seen with the loaded filter")
+	}
+	if stats.SeencheckFilterMissesGet() != misses+1 {
+		t.Error("expected the lookup to go through the filter to the database")
+	}
+	isSeenItem(t, "https://example.com/b")
+
+	// Killed without persisting the filter, it's rebuilt from the database
+	globalSeencheck.DB.Close()
+
+	if err := Start(jobPath, settings); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	for _, URL := range []string{"https://example.com/a", "https://example.com/b"} {
+		if !isSeenItem(t, URL) {
+			t.Errorf("%s: expected the URL to be seen with the rebuilt filter", URL)
+		}
+	}
+}
+
+func TestSeencheckHash(t *testing.T) {
+	jobPath := t.TempDir()
+
+	if err := Start(jobPath, Settings{Hash: HashSHA256}); err != nil {
+		t.Fatal(err)
+	}
+	isSeenItem(t, "https://example.com/a")
+	Close()
+
+	if err := Start(jobPath, Settings{Hash: HashFNV64}); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("expected a hash mismatch, got %v", err)
+	}
+
+	if err := Start(jobPath, Settings{Hash: HashSHA256}); err != nil {
+		t.Fatal(err)
+	}
+	if !isSeenItem(t, "https://example.com/a") {
+		t.Error("expected the URL to be seen")
+	}
+	Close()
+
+	// A database written before the hash function was stored is keyed with FNV-64
+	legacyPath := t.TempDir()
+	if err := Start(legacyPath, Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	isSeenItem(t, "https://example.com/a")
+	globalSeencheck.DB.Delete(hashKey)
+	Close()
+
+	if err := Start(legacyPath, Settings{Hash: HashSHA256}); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("expected a hash mismatch on the legacy database, got %v", err)
+	}
+
+	if err := Start(legacyPath, Settings{Hash: HashFNV64}); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	if !isSeenItem(t, "https://example.com/a") {
+		t.Error("expected the URL to be seen in the legacy database")
+	}
+}
+
+func TestSeencheckCanonicalization(t *testing.T) {
+	jobPath := t.TempDir()
+	seeURLs(t, jobPath, Settings{}, "https://example.com/a")
+
+	if err := canonicalizer.Start(canonicalizer.Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	err := Start(jobPath, Settings{})
+	canonicalizer.Stop()
+	if !errors.Is(err, ErrCanonicalizationMismatch) {
+		t.Fatalf("expected a canonicalization mismatch, got %v", err)
+	}
+
+	// A database written before the mode was stored wasn't canonicalized
+	if err := Start(jobPath, Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	globalSeencheck.DB.Delete(canonicalizationKey)
+	Close()
+
+	if seen := seenURLs(t, jobPath, Settings{}, "https://example.com/a"); len(seen) != 1 {
+		t.Errorf("expected the URL to be seen in the legacy database, got %v", seen)
+	}
+
+	// An empty database takes the mode of the crawl
+	if err := canonicalizer.Start(canonicalizer.Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	defer canonicalizer.Stop()
+
+	seen := seenURLs(t, t.TempDir(), Settings{}, "https://www.example.com/?utm_source=feed", "https://example.com/")
+	if len(seen) != 1 {
+		t.Errorf("expected the canonical URLs to match, got %v", seen)
+	}
+}
+
+// seeURLs marks the URLs as seen in the seencheck of the job
+func seeURLs(t *testing.T, jobPath string, settings Settings, URLs ...string) {
+	t.Helper()
+
+	if err := Start(jobPath, settings); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	for _, URL := range URLs {
+		isSeenItem(t, URL)
+	}
+}
+
+// seenURLs returns the URLs seen by the seencheck of the job
+func seenURLs(t *testing.T, jobPath string, settings Settings, URLs ...string) (seen []string) {
+	t.Helper()
+
+	if err := Start(jobPath, settings); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	for _, URL := range URLs {
+		if isSeenItem(t, URL) {
+			seen = append(seen, URL)
+		}
+	}
+
+	return seen
+}
+
+func TestDBExportImport(t *testing.T) {
+	settings := Settings{Hash: HashSHA256}
+	source := t.TempDir()
+	seeURLs(t, source, settings, "https://example.com/a", "https://example.com/b")
+
+	db, err := OpenDB(source, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var export bytes.Buffer
+	exported, err := db.Export(&export)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 2 {
+		t.Errorf("expected 2 URLs exported, got %d", exported)
+	}
+
+	// A new database uses FNV-64 by default
+	mismatched, err := OpenDB(t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mismatched.Import(bytes.NewReader(export.Bytes())); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+	if _, err := mismatched.Import(strings.NewReader("key\tseed:0\n")); !errors.Is(err, ErrInvalidExport) {
+		t.Errorf("expected an invalid export, got %v", err)
+	}
+	mismatched.Close()
+
+	destination := t.TempDir()
+	db, err = OpenDB(destination, HashSHA256, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Import(&export); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	seen := seenURLs(t, destination, settings, "https://example.com/a", "https://example.com/b", "https://example.com/c")
+	if len(seen) != 2 || seen[0] != "https://example.com/a" || seen[1] != "https://example.com/b" {
+		t.Errorf("expected the exported URLs to be seen, got %v", seen)
+	}
+}
+
+func TestDBImportURLsAndCDX(t *testing.T) {
+	jobPath := t.TempDir()
+
+	db, err := OpenDB(jobPath, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := db.ImportURLs(strings.NewReader("# comment\nhttps://example.com/a\n\n  https://example.com/b  \n"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 URLs imported from the list, got %d", read)
+	}
+
+	cdx := `com,example)/c 20240101000000 {"url":"https://example.com/c","mime":"text/html","status":"200","digest":"AAAA","length":"100"}
+ CDX N b a m s k r M S V g
+com,example)/d 20240101000000 https://example.com/d text/html 200 BBBB - - 100 0 a.warc.gz
+`
+	read, err = db.ImportCDX(strings.NewReader(cdx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 captures imported from the CDX, got %d", read)
+	}
+	db.Close()
+
+	// The captures of the CDX expire with the TTL from their date
+	seen := seenURLs(t, jobPath, Settings{TTL: 24 * time.Hour}, "https://example.com/a", "https://example.com/b", "https://example.com/c", "https://example.com/d")
+	if len(seen) != 2 || seen[0] != "https://example.com/a" || seen[1] != "https://example.com/b" {
+		t.Errorf("expected the listed URLs to be seen and the old captures to be expired, got %v", seen)
+	}
+
+	seen = seenURLs(t, jobPath, Settings{}, "https://example.com/c", "https://example.com/d", "https://example.com/e")
+	if len(seen) != 2 {
+		t.Errorf("expected the captures of the CDX to be seen without a TTL, got %v", seen)
+	}
+}
+
+func TestDBMerge(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	seeURLs(t, first, Settings{}, "https://example.com/a")
+	seeURLs(t, second, Settings{}, "https://example.com/b")
+
+	db, err := OpenDB(first, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := OpenDB(second, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Merge(source); err != nil {
+		t.Fatal(err)
+	}
+	source.Close()
+
+	count, err := db.Count()
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 URLs after the merge, got %d", count)
+	}
+
+	if seen := seenURLs(t, first, Settings{}, "https://example.com/a", "https://example.com/b"); len(seen) != 2 {
+		t.Errorf("expected the merged URLs to be seen, got %v", seen)
+	}
+
+	if _, err := OpenDB(first, HashSHA256, true); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+}
+
+func TestDBCanonicalization(t *testing.T) {
+	source := t.TempDir()
+	seeURLs(t, source, Settings{}, "https://example.com/a")
+
+	db, err := OpenDB(source, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var export bytes.Buffer
+	_, err = db.Export(&export)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header, _, _ := strings.Cut(export.String(), "\n"); header != "# zeno seencheck hash=fnv64 canonicalization=none" {
+		t.Errorf("expected the canonicalization in the header, got %q", header)
+	}
+
+	if err := canonicalizer.Start(canonicalizer.Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	defer canonicalizer.Stop()
+
+	// An empty database takes the mode of the first URLs added
+	destination, err := OpenDB(t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+
+	if _, err := destination.ImportURLs(strings.NewReader("https://example.com/b\n"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if destination.Canonicalization() != canonicalizer.Mode() {
+		t.Errorf("expected the surt canonicalization, got %q", destination.Canonicalization())
+	}
+
+	if _, err := destination.Import(&export); !errors.Is(err, ErrCanonicalizationMismatch) {
+		t.Errorf("expected a canonicalization mismatch on import, got %v", err)
+	}
+
+	// The exports written before the mode was stored weren't canonicalized
+	if _, err := destination.Import(strings.NewReader("# zeno seencheck hash=fnv64\n1\tseed:0\n")); !errors.Is(err, ErrCanonicalizationMismatch) {
+		t.Errorf("expected a canonicalization mismatch on a legacy import, got %v", err)
+	}
+
+	other, err := OpenDB(source, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	if _, err := destination.Merge(other); !errors.Is(err, ErrCanonicalizationMismatch) {
+		t.Errorf("expected a canonicalization mismatch on merge, got %v", err)
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"asset:100", "seed:50", "seed:100"},
+		{"seed:50", "asset:100", "seed:100"},
+		{"asset:100", "asset:200", "asset:200"},
+		{"seed", "asset:100", "seed:100"},
+		{"asset", "seed", "seed"},
+	}
+
+	for _, tt := range tests {
+		if merged := mergeValues(tt.a, tt.b); merged != tt.expected {
+			t.Errorf("merging %s and %s: expected %s, got %s", tt.a, tt.b, tt.expected, merged)
+		}
+	}
+}
+
+func TestSeencheckOverlay(t *testing.T) {
+	previous := t.TempDir()
+	seeURLs(t, previous, Settings{}, "https://example.com/a")
+
+	jobPath := t.TempDir()
+	settings := Settings{FilterCapacity: 1000, FilterFPRate: 0.01, Overlays: []string{previous}}
+
+	seen := seenURLs(t, jobPath, settings, "https://example.com/a", "https://example.com/b", "https://example.com/b")
+	if len(seen) != 2 || seen[0] != "https://example.com/a" || seen[1] != "https://example.com/b" {
+		t.Errorf("expected the URL of the overlay and the second capture to be seen, got %v", seen)
+	}
+
+	// The overlay is never written to
+	db, err := OpenDB(previous, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := db.Count()
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected the overlay to keep 1 URL, got %d", count)
+	}
+
+	if err := Start(jobPath, Settings{Overlays: []string{t.TempDir()}}); err == nil {
+		Close()
+		t.Error("expected an error with an overlay without seencheck")
+	}
+}
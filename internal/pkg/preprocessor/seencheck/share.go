@@ -0,0 +1,403 @@
+package seencheck
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/dedupe"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// exportHeader starts the export files, followed by the hash function of the keys and, if the export
+// has URLs, exportCanonicalizationField and their canonicalization mode
+const (
+	exportHeader                = "# zeno seencheck hash="
+	exportCanonicalizationField = " canonicalization="
+)
+
+// ErrInvalidExport is returned when importing a file that isn't a seencheck export
+var ErrInvalidExport = errors.New("not a seencheck export, missing the header")
+
+// DB is the seencheck database of a job opened outside of a crawl, to export, import or merge URLs.
+// It must not be used while a crawl is running on the same job.
+type DB struct {
+	db   *goleveldb.DB
+	hash string
+	// canonicalization is the mode the URLs are canonicalized with, empty while the database has no URL
+	canonicalization string
+}
+
+// OpenDB opens the seencheck database of the job. With readOnly, the database must exist. Otherwise it's created
+// if needed, keyed with hash (HashFNV64 if empty), and the persisted filter of the job is removed so that it's
+// rebuilt with the changes by the next crawl. If hash isn't empty, it must be the one of the existing database.
+// The URLs added must be canonicalized like the ones of the database.
+func OpenDB(jobPath, hash string, readOnly bool) (*DB, error) {
+	if hash != "" && hash != HashFNV64 && hash != HashSHA256 {
+		return nil, fmt.Errorf("invalid seencheck hash %q, must be one of: %s, %s", hash, HashFNV64, HashSHA256)
+	}
+
+	db, err := goleveldb.OpenFile(path.Join(jobPath, "seencheck"), &opt.Options{ReadOnly: readOnly, ErrorIfMissing: readOnly})
+	if err != nil {
+		return nil, err
+	}
+
+	dbHash, dbCanonicalization, keys, err := scan(db, nil, false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if hash == "" {
+		hash = dbHash
+	}
+	if hash == "" {
+		hash = HashFNV64
+	}
+
+	if err := checkHash(dbHash, keys, hash); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if !readOnly {
+		if dbHash == "" {
+			if err := db.Put([]byte(hashKey), encodeValue(hash), nil); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+
+		if err := os.Remove(path.Join(jobPath, "seencheck.filter")); err != nil && !os.IsNotExist(err) {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	d := &DB{db: db, hash: hash}
+	if keys > 0 {
+		// The databases written before the mode was stored weren't canonicalized
+		d.canonicalization = dbCanonicalization
+		if d.canonicalization == "" {
+			d.canonicalization = canonicalizer.ModeNone
+		}
+	}
+
+	return d, nil
+}
+
+// Close closes the database
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Hash returns the hash function the URLs are keyed with
+func (d *DB) Hash() string {
+	return d.hash
+}
+
+// Canonicalization returns the canonicalization mode of the URLs, empty if the database has no URL
+func (d *DB) Canonicalization() string {
+	return d.canonicalization
+}
+
+// Count returns the number of URLs in the database
+func (d *DB) Count() (int, error) {
+	count := 0
+	err := d.iterate(func(string, string) error {
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// Export writes the keys and values of the database, one tab-separated pair per line after a header
+// giving the hash function. It returns the number of URLs exported.
+func (d *DB) Export(w io.Writer) (int, error) {
+	writer := bufio.NewWriter(w)
+
+	header := exportHeader + d.hash
+	if d.canonicalization != "" {
+		header += exportCanonicalizationField + d.canonicalization
+	}
+
+	if _, err := fmt.Fprintln(writer, header); err != nil {
+		return 0, err
+	}
+
+	exported := 0
+	err := d.iterate(func(key, value string) error {
+		exported++
+		_, err := fmt.Fprintf(writer, "%s\t%s\n", key, value)
+		return err
+	})
+	if err != nil {
+		return exported, err
+	}
+
+	return exported, writer.Flush()
+}
+
+// Import adds the URLs of an export to the database, that must use the same hash function and canonicalization.
+// It returns the number of URLs read.
+func (d *DB) Import(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, ErrInvalidExport
+	}
+
+	header, ok := strings.CutPrefix(scanner.Text(), exportHeader)
+	if !ok {
+		return 0, ErrInvalidExport
+	}
+
+	// The exports written before the mode was stored weren't canonicalized
+	hash, canonicalization, ok := strings.Cut(header, exportCanonicalizationField)
+	if !ok {
+		canonicalization = canonicalizer.ModeNone
+	}
+
+	if hash != d.hash {
+		return 0, fmt.Errorf("%w: the export uses %s but the database uses %s", ErrHashMismatch, hash, d.hash)
+	}
+
+	if err := d.useCanonicalization(canonicalization, "the export"); err != nil {
+		return 0, err
+	}
+
+	batch := new(goleveldb.Batch)
+	read := 0
+
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		key, value, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || key == "" {
+			return read, fmt.Errorf("line %d: expected a tab-separated key and value", lineNumber)
+		}
+
+		if err := d.merge(batch, key, value); err != nil {
+			return read, err
+		}
+		read++
+
+		if err := d.flush(batch, false); err != nil {
+			return read, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return read, err
+	}
+
+	return read, d.flush(batch, true)
+}
+
+// ImportURLs adds the URLs listed in the input, one per line, as seeds captured at capturedAt.
+// Empty lines and lines starting with # are ignored. It returns the number of URLs read.
+func (d *DB) ImportURLs(r io.Reader, capturedAt time.Time) (int, error) {
+	if err := d.useCanonicalization(canonicalizer.Mode(), "the import"); err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	batch := new(goleveldb.Batch)
+	read := 0
+
+	for scanner.Scan() {
+		URL := strings.TrimSpace(scanner.Text())
+		if URL == "" || strings.HasPrefix(URL, "#") {
+			continue
+		}
+
+		if err := d.merge(batch, keyFor(d.hash, URL), formatValue("seed", capturedAt)); err != nil {
+			return read, err
+		}
+		read++
+
+		if err := d.flush(batch, false); err != nil {
+			return read, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return read, err
+	}
+
+	return read, d.flush(batch, true)
+}
+
+// ImportCDX adds the URLs of the captures listed in the CDX or CDXJ input, as seeds captured at the date
+// of their capture. It returns the number of captures read.
+func (d *DB) ImportCDX(r io.Reader) (int, error) {
+	if err := d.useCanonicalization(canonicalizer.Mode(), "the import"); err != nil {
+		return 0, err
+	}
+
+	batch := new(goleveldb.Batch)
+
+	read, err := dedupe.ReadCDX(r, func(_ string, capture dedupe.Capture, _ bool) error {
+		if capture.URI == "" {
+			return nil
+		}
+
+		if err := d.merge(batch, keyFor(d.hash, capture.URI), formatValue("seed", capture.Date)); err != nil {
+			return err
+		}
+
+		return d.flush(batch, false)
+	})
+	if err != nil {
+		return read, err
+	}
+
+	return read, d.flush(batch, true)
+}
+
+// Merge adds the URLs of the other database, that must use the same hash function and canonicalization.
+// It returns the number of URLs read.
+func (d *DB) Merge(other *DB) (int, error) {
+	if other.hash != d.hash {
+		return 0, fmt.Errorf("%w: the merged database uses %s but the database uses %s", ErrHashMismatch, other.hash, d.hash)
+	}
+
+	if other.canonicalization != "" {
+		if err := d.useCanonicalization(other.canonicalization, "the merged database"); err != nil {
+			return 0, err
+		}
+	}
+
+	batch := new(goleveldb.Batch)
+	read := 0
+
+	err := other.iterate(func(key, value string) error {
+		if err := d.merge(batch, key, value); err != nil {
+			return err
+		}
+		read++
+
+		return d.flush(batch, false)
+	})
+	if err != nil {
+		return read, err
+	}
+
+	return read, d.flush(batch, true)
+}
+
+// useCanonicalization checks that the URLs added by source are canonicalized like the ones of the database,
+// and stores their mode if the database has no URL yet
+func (d *DB) useCanonicalization(canonicalization, source string) error {
+	if d.canonicalization == "" {
+		if err := d.db.Put([]byte(canonicalizationKey), encodeValue(canonicalization), nil); err != nil {
+			return err
+		}
+		d.canonicalization = canonicalization
+
+		return nil
+	}
+
+	if canonicalization != d.canonicalization {
+		return fmt.Errorf("%w: %s uses %s but the database uses %s", ErrCanonicalizationMismatch, source, canonicalization, d.canonicalization)
+	}
+
+	return nil
+}
+
+// merge adds the key to the batch with the value merged with the existing one, if any:
+// the seed type wins over the asset type, and the latest capture time is kept
+func (d *DB) merge(batch *goleveldb.Batch, key, value string) error {
+	raw, err := d.db.Get([]byte(key), nil)
+	if err == nil {
+		var existing string
+		if err := json.Unmarshal(raw, &existing); err == nil {
+			value = mergeValues(existing, value)
+		}
+	} else if !errors.Is(err, goleveldb.ErrNotFound) {
+		return err
+	}
+
+	batch.Put([]byte(key), encodeValue(value))
+
+	return nil
+}
+
+// flush writes the batch once it's large enough, or if force is true
+func (d *DB) flush(batch *goleveldb.Batch, force bool) error {
+	if batch.Len() == 0 || (!force && batch.Len() < 10000) {
+		return nil
+	}
+
+	if err := d.db.Write(batch, nil); err != nil {
+		return err
+	}
+	batch.Reset()
+
+	return nil
+}
+
+// iterate calls fn with the key and value of each URL of the database
+func (d *DB) iterate(fn func(key, value string) error) error {
+	iter := d.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := string(iter.Key())
+		if isMetaKey(key) {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(iter.Value(), &value); err != nil {
+			return fmt.Errorf("invalid value for key %s: %w", key, err)
+		}
+
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+// mergeValues merges two values of the same URL
+func mergeValues(a, b string) string {
+	typeA, capturedA, _ := parseValue(a)
+	typeB, capturedB, _ := parseValue(b)
+
+	URLType := typeA
+	if typeB == "seed" {
+		URLType = typeB
+	}
+
+	capturedAt := capturedA
+	if capturedB.After(capturedA) {
+		capturedAt = capturedB
+	}
+
+	// Neither has a capture time, it's considered captured when the job using it starts
+	if capturedAt.IsZero() {
+		return URLType
+	}
+
+	return formatValue(URLType, capturedAt)
+}
+
+// encodeValue encodes the value like the database of the crawls does
+func encodeValue(value string) []byte {
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
@@ -0,0 +1,130 @@
+package seencheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TTLRule overrides the recrawl interval of the URLs it matches.
+// Exactly one of Host, Domain or Regex must be set.
+type TTLRule struct {
+	// Host matches this exact host
+	Host string `json:"host,omitempty"`
+	// Domain matches this domain and all its subdomains
+	Domain string `json:"domain,omitempty"`
+	// Regex matches the URLs matching this regular expression
+	Regex string `json:"regex,omitempty"`
+
+	// TTL is how long a URL is considered seen after its capture, e.g. "6h", "0" for forever
+	TTL string `json:"ttl"`
+}
+
+// compiledTTLRule is a validated TTLRule ready to be matched
+type compiledTTLRule struct {
+	TTLRule
+	regex *regexp.Regexp
+	ttl   time.Duration
+}
+
+// ttlPolicy gives the recrawl interval of the URLs
+type ttlPolicy struct {
+	defaultTTL time.Duration
+	rules      []compiledTTLRule
+}
+
+// LoadTTLRules reads a JSON TTL rules file, a list of rules that are evaluated in order, the first matching rule wins
+func LoadTTLRules(path string) ([]TTLRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []TTLRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid seencheck TTL rules file: %w", err)
+	}
+
+	if _, err := compileTTLRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func compileTTLRules(rules []TTLRule) ([]compiledTTLRule, error) {
+	compiled := make([]compiledTTLRule, 0, len(rules))
+
+	for i, rule := range rules {
+		matchers := 0
+		for _, matcher := range []string{rule.Host, rule.Domain, rule.Regex} {
+			if matcher != "" {
+				matchers++
+			}
+		}
+		if matchers != 1 {
+			return nil, fmt.Errorf("seencheck TTL rule %d: exactly one of host, domain or regex must be set", i)
+		}
+
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("seencheck TTL rule %d: invalid ttl %q", i, rule.TTL)
+		}
+
+		c := compiledTTLRule{TTLRule: rule, ttl: ttl}
+		c.Host = strings.ToLower(rule.Host)
+		c.Domain = strings.ToLower(strings.TrimPrefix(rule.Domain, "."))
+
+		if rule.Regex != "" {
+			regex, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("seencheck TTL rule %d: %w", i, err)
+			}
+			c.regex = regex
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// match returns true if the rule applies to the URL
+func (r *compiledTTLRule) match(u *url.URL) bool {
+	hostname := strings.ToLower(u.Hostname())
+
+	switch {
+	case r.Host != "":
+		return hostname == r.Host
+	case r.Domain != "":
+		return hostname == r.Domain || strings.HasSuffix(hostname, "."+r.Domain)
+	case r.regex != nil:
+		return r.regex.MatchString(u.String())
+	}
+
+	return false
+}
+
+// ttl returns the recrawl interval of the URL, 0 meaning it's never crawled again
+func (p *ttlPolicy) ttl(u *url.URL) time.Duration {
+	if u != nil {
+		for i := range p.rules {
+			if p.rules[i].match(u) {
+				return p.rules[i].ttl
+			}
+		}
+	}
+
+	return p.defaultTTL
+}
+
+// expired returns true if the URL captured at capturedAt is due for a recrawl
+func (p *ttlPolicy) expired(u *url.URL, capturedAt, now time.Time) bool {
+	ttl := p.ttl(u)
+
+	return ttl > 0 && now.Sub(capturedAt) >= ttl
+}
@@ -1,28 +0,0 @@
-package tiktok
-
-import (
-	"net/http"
-	"strings"
-
-	"github.com/internetarchive/Zeno/pkg/models"
-)
-
-func IsTikTokURL(URL *models.URL) bool {
-	return strings.Contains(URL.String(), "tiktok.com/")
-}
-
-func AddHeaders(req *http.Request) {
-	req.Header.Set("Authority", "www.tiktok.com")
-	req.Header.Set("Sec-Ch-Ua", "\" Not A;Brand\";v=\"99\", \"Chromium\";v=\"99\", \"Microsoft Edge\";v=\"99\"")
-	req.Header.Set("Sec-Ch-Ua-Mobile", "?0")
-	req.Header.Set("Sec-Ch-Ua-Platform", "\"Linux\"")
-	req.Header.Set("Dnt", "1")
-	req.Header.Set("Upgrade-Insecure-Requests", "1")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.74 Safari/537.36 Edg/99.0.1150.52")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9")
-	req.Header.Set("Sec-Fetch-Site", "none")
-	req.Header.Set("Sec-Fetch-Mode", "navigate")
-	req.Header.Set("Sec-Fetch-User", "?1")
-	req.Header.Set("Sec-Fetch-Dest", "document")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9,fr;q=0.8")
-}
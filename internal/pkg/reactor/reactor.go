@@ -17,6 +17,8 @@ type reactor struct {
 	cancel       context.CancelFunc // Context's cancel func
 	freezeCtx    context.Context    // Context for freezing the reactor
 	freezeCancel context.CancelFunc // Freezing context's cancel func
+	drainCtx     context.Context    // Context for draining the reactor
+	drainCancel  context.CancelFunc // Draining context's cancel func
 	input        chan *models.Item  // Combined input channel for source and feedback
 	output       chan *models.Item  // Output channel
 	stateTable   sync.Map           // State table for tracking seeds by UUID
@@ -43,12 +45,15 @@ func Start(maxTokens int, outputChan chan *models.Item) error {
 	once.Do(func() {
 		ctx, cancel := context.WithCancel(context.Background())
 		freezeCtx, freezeCancel := context.WithCancel(ctx)
+		drainCtx, drainCancel := context.WithCancel(ctx)
 		globalReactor = &reactor{
 			tokenPool:    make(chan struct{}, maxTokens),
 			ctx:          ctx,
 			cancel:       cancel,
 			freezeCtx:    freezeCtx,
 			freezeCancel: freezeCancel,
+			drainCtx:     drainCtx,
+			drainCancel:  drainCancel,
 			input:        make(chan *models.Item, maxTokens),
 			output:       outputChan,
 		}
@@ -88,6 +93,38 @@ func Freeze() {
 	}
 }
 
+// Drain stops the global reactor from accepting new seeds, while the seeds already in
+// the state table keep being processed (feedback included) until they are marked as finished.
+// Inserts received while draining return ErrReactorFrozen, so the sources stop pulling as they do on Freeze.
+func Drain() {
+	if globalReactor != nil {
+		logger.Debug("received drain signal")
+		globalReactor.drainCancel()
+		logger.Info("draining")
+	}
+}
+
+// IsDrained returns true if the reactor is draining and all the seeds it was processing are finished.
+func IsDrained() bool {
+	if globalReactor == nil {
+		return true
+	}
+
+	select {
+	case <-globalReactor.drainCtx.Done():
+	default:
+		return false
+	}
+
+	empty := true
+	globalReactor.stateTable.Range(func(_, _ interface{}) bool {
+		empty = false
+		return false
+	})
+
+	return empty
+}
+
 // ReceiveFeedback sends an item to the feedback channel.
 // If the item is not present on the state table it gets discarded
 func ReceiveFeedback(item *models.Item) error {
@@ -123,6 +160,14 @@ func ReceiveInsert(item *models.Item) error {
 		return ErrReactorNotInitialized
 	}
 
+	// Checked first so that an available token can't win over the drain signal
+	select {
+	case <-globalReactor.drainCtx.Done():
+		logger.Debug("received item on draining reactor", "item", item.GetShortID())
+		return ErrReactorFrozen
+	default:
+	}
+
 	select {
 	case <-globalReactor.ctx.Done():
 		logger.Debug("received item on shutting down reactor", "item", item.GetShortID())
@@ -130,6 +175,9 @@ func ReceiveInsert(item *models.Item) error {
 	case <-globalReactor.freezeCtx.Done():
 		logger.Debug("received item on frozen reactor", "item", item.GetShortID())
 		return ErrReactorFrozen
+	case <-globalReactor.drainCtx.Done():
+		logger.Debug("received item on draining reactor", "item", item.GetShortID())
+		return ErrReactorFrozen
 	case globalReactor.tokenPool <- struct{}{}:
 		logger.Debug("received item", "item", item.GetShortID())
 		if !item.IsSeed() {
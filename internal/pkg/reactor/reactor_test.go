@@ -158,3 +158,52 @@ func _testerFunc(tokens, consumers, seeds int, t testing.TB) {
 		}
 	}
 }
+
+func TestReactor_Drain(t *testing.T) {
+	outputChan := make(chan *models.Item, 1)
+	if err := Start(2, outputChan); err != nil {
+		t.Fatalf("Error starting reactor: %s", err)
+	}
+	defer log.Stop()
+	defer Stop()
+
+	newSeed := func() *models.Item {
+		item := models.NewItem(uuid.New().String(), &models.URL{Raw: "http://example.com/"}, "")
+		item.SetStatus(models.ItemFresh)
+		return item
+	}
+
+	seed := newSeed()
+	if err := ReceiveInsert(seed); err != nil {
+		t.Fatalf("Error inserting seed: %s", err)
+	}
+
+	if IsDrained() {
+		t.Fatal("Reactor should not be drained before Drain is called")
+	}
+
+	Drain()
+
+	if err := ReceiveInsert(newSeed()); err != ErrReactorFrozen {
+		t.Fatalf("Expected ErrReactorFrozen when inserting in a draining reactor, got %v", err)
+	}
+
+	// The in-flight seed must still be able to go through feedback
+	item := <-outputChan
+	if err := ReceiveFeedback(item); err != nil {
+		t.Fatalf("Error sending feedback while draining: %s", err)
+	}
+	item = <-outputChan
+
+	if IsDrained() {
+		t.Fatal("Reactor should not be drained while a seed is in flight")
+	}
+
+	if err := MarkAsFinished(item); err != nil {
+		t.Fatalf("Error marking seed as finished: %s", err)
+	}
+
+	if !IsDrained() {
+		t.Fatalf("Reactor should be drained, state table: %s", GetStateTable())
+	}
+}
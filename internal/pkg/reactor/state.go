@@ -5,6 +5,9 @@ import "github.com/internetarchive/Zeno/pkg/models"
 // GetStateTable returns a slice of all the seeds UUIDs as string in the state table.
 func GetStateTable() []string {
 	keys := []string{}
+	if globalReactor == nil {
+		return keys
+	}
 	globalReactor.stateTable.Range(func(key, _ interface{}) bool {
 		keys = append(keys, key.(string))
 		return true
@@ -15,6 +18,9 @@ func GetStateTable() []string {
 // GetStateTableItems returns a slice of all the seeds in the state table.
 func GetStateTableItems() []*models.Item {
 	items := []*models.Item{}
+	if globalReactor == nil {
+		return items
+	}
 	globalReactor.stateTable.Range(func(_, value interface{}) bool {
 		items = append(items, value.(*models.Item))
 		return true
@@ -21,7 +21,7 @@ func consumer() {
 	})
 
 	// Create a context to manage goroutines
-	ctx, cancel := context.WithCancel(globalHQ.ctx)
+	ctx, cancel := context.WithCancel(globalHQ.consumerCtx)
 	defer cancel()
 
 	// Set the batch size for fetching URLs
@@ -44,7 +44,7 @@ func consumer() {
 	// Wait for shutdown signal
 	for {
 		select {
-		case <-globalHQ.ctx.Done():
+		case <-globalHQ.consumerCtx.Done():
 			logger.Debug("received done signal")
 
 			// Cancel the context to stop all goroutines.
@@ -58,6 +58,11 @@ func consumer() {
 			// Close the urlBuffer to signal consumerSenders to finish
 			close(urlBuffer)
 
+			// URLs left in the buffer were claimed but never reached the reactor, give them back
+			for URL := range urlBuffer {
+				resetURL(URL.ID)
+			}
+
 			globalHQ.wg.Done()
 
 			logger.Debug("closed")
@@ -110,6 +115,9 @@ func consumerFetcher(ctx context.Context, wg *sync.WaitGroup, urlBuffer chan<- *
 		for i := range URLs {
 			select {
 			case <-ctx.Done():
+				for j := i; j < len(URLs); j++ {
+					resetURL(URLs[j].ID)
+				}
 				logger.Debug("closed")
 				return
 			case urlBuffer <- &gocrawlhq.URL{
@@ -182,6 +190,8 @@ func consumerSender(ctx context.Context, wg *sync.WaitGroup, urlBuffer <-chan *g
 			err = reactor.ReceiveInsert(newItem)
 			if err != nil {
 				if err == reactor.ErrReactorFrozen {
+					// The seed was claimed but will not be processed, give it back before waiting for the stop
+					resetURL(URL.ID)
 					select {
 					case <-ctx.Done():
 						logger.Debug("closed while sending to frozen reactor")
@@ -245,6 +255,15 @@ func ensureAllURLsUnique(URLs []gocrawlhq.URL) error {
 	return nil
 }
 
+// resetURL gives back a claimed URL that never made it to the reactor
+func resetURL(ID string) {
+	if err := globalHQ.client.ResetURL(context.TODO(), ID); err != nil {
+		logger.Error("error while reseting", "id", ID, "err", err)
+		return
+	}
+	logger.Debug("reset seed", "id", ID)
+}
+
 func ensureAllIDsNotInReactor(URLs []gocrawlhq.URL) error {
 	reactorIDs := reactor.GetStateTable()
 	reactorIDMap := make(map[string]struct{})
@@ -14,12 +14,15 @@ import (
 )
 
 type hq struct {
-	wg        sync.WaitGroup
-	ctx       context.Context
-	cancel    context.CancelFunc
-	finishCh  chan *models.Item
-	produceCh chan *models.Item
-	client    *gocrawlhq.Client
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	// consumerCtx is canceled to stop claiming URLs while the rest of the HQ source keeps running
+	consumerCtx    context.Context
+	consumerCancel context.CancelFunc
+	finishCh       chan *models.Item
+	produceCh      chan *models.Item
+	client         *gocrawlhq.Client
 }
 
 var (
@@ -51,13 +54,17 @@ func Start(finishChan, produceChan chan *models.Item) error {
 			return
 		}
 
+		consumerCtx, consumerCancel := context.WithCancel(ctx)
+
 		globalHQ = &hq{
-			wg:        sync.WaitGroup{},
-			ctx:       ctx,
-			cancel:    cancel,
-			finishCh:  finishChan,
-			produceCh: produceChan,
-			client:    HQclient,
+			wg:             sync.WaitGroup{},
+			ctx:            ctx,
+			cancel:         cancel,
+			consumerCtx:    consumerCtx,
+			consumerCancel: consumerCancel,
+			finishCh:       finishChan,
+			produceCh:      produceChan,
+			client:         HQclient,
 		}
 
 		globalHQ.wg.Add(4)
@@ -83,14 +90,31 @@ func Stop() {
 	if globalHQ != nil {
 		globalHQ.cancel()
 		globalHQ.wg.Wait()
-		seedsToReset := reactor.GetStateTable()
-		for _, seed := range seedsToReset {
-			if err := globalHQ.client.ResetURL(context.TODO(), seed); err != nil {
-				logger.Error("error while reseting", "id", seed, "err", err)
-			}
-			logger.Debug("reset seed", "id", seed)
-		}
+		ResetSeeds()
 		once = sync.Once{}
 		logger.Info("stopped")
 	}
 }
+
+// StopConsumer stops claiming new URLs, the URLs claimed but not yet sent to the reactor are given back.
+// The seeds in flight are still finished and their outlinks produced, e.g. while draining the reactor.
+func StopConsumer() {
+	if globalHQ != nil {
+		globalHQ.consumerCancel()
+	}
+}
+
+// ResetSeeds gives back to the HQ the seeds currently claimed by the reactor, so they can be crawled again.
+func ResetSeeds() {
+	if globalHQ == nil {
+		return
+	}
+
+	seedsToReset := reactor.GetStateTable()
+	for _, seed := range seedsToReset {
+		if err := globalHQ.client.ResetURL(context.TODO(), seed); err != nil {
+			logger.Error("error while reseting", "id", seed, "err", err)
+		}
+		logger.Debug("reset seed", "id", seed)
+	}
+}
@@ -4,31 +4,71 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"errors"
+	"fmt"
+	"net/url"
 	"path"
+	"strings"
+	"time"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
 
 	"github.com/google/uuid"
 	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
 	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
 )
 
 type LQClient struct {
 	dbWrite     *sql.DB
 	dbWriteSqlc *sqlc_model.Queries
+	// canonicalization is the mode of the canonical keys of the queue, empty while it has no URL
+	canonicalization string
 }
 
+// canonicalizationKey stores the canonicalization mode of the canonical keys in the meta table
+const canonicalizationKey = "canonicalization"
+
 //go:embed schema.sql
 var ddl string
 
 func Init(job string) (*LQClient, error) {
-	dbWrite, err := sql.Open("sqlite3", "file:"+path.Join(config.Get().JobPath, "lq.db"))
+	client, err := Open(path.Join(config.Get().JobPath, "lq.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to start rather than queue URLs that the ones already queued wouldn't deduplicate
+	if err := client.checkCanonicalization(context.Background()); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Open opens (and creates if needed) the LQ database at the given path.
+// It can be used outside of a crawl, e.g. to manage the queue from the CLI.
+func Open(dbPath string) (*LQClient, error) {
+	if logger == nil {
+		logger = log.NewFieldedLogger(&log.Fields{
+			"component": "lq",
+		})
+	}
+
+	dbWrite, err := sql.Open("sqlite3", "file:"+dbPath)
 	if err != nil {
 		return nil, err
 	}
 	dbWrite.SetMaxOpenConns(1)
 
+	if err := migrate(dbWrite); err != nil {
+		logger.Error("error migrating lq database", "err", err.Error(), "func", "lq.Init")
+		return nil, err
+	}
+
 	if _, err := dbWrite.Exec(ddl); err != nil {
 		logger.Error("error creating lq database schema", "err", err.Error(), "func", "lq.Init")
 		return nil, err
@@ -36,24 +76,102 @@ func Init(job string) (*LQClient, error) {
 
 	dbWriteSqlc := sqlc_model.New(dbWrite)
 
-	return &LQClient{
+	client := &LQClient{
 		dbWrite:     dbWrite,
 		dbWriteSqlc: dbWriteSqlc,
+	}
+
+	client.canonicalization, err = client.loadCanonicalization(context.Background())
+	if err != nil {
+		logger.Error("error reading lq canonicalization", "err", err.Error(), "func", "lq.Init")
+		dbWrite.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// loadCanonicalization returns the canonicalization mode stored with the URLs, empty if the queue has no URL.
+// The URLs queued before the mode was stored were keyed with their value.
+func (c *LQClient) loadCanonicalization(ctx context.Context) (string, error) {
+	canonicalization, err := c.dbWriteSqlc.GetMeta(ctx, canonicalizationKey)
+	if err == nil {
+		return canonicalization, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if stats["FRESH"]+stats["CLAIMED"]+stats["DONE"] == 0 {
+		return "", nil
+	}
+
+	return canonicalizer.ModeNone, nil
+}
+
+// checkCanonicalization returns ErrCanonicalizationMismatch if the URLs of the queue weren't keyed with the current
+// canonicalization mode, that is stored if the queue has no URL yet
+func (c *LQClient) checkCanonicalization(ctx context.Context) error {
+	canonicalization := canonicalizer.Mode()
+
+	if c.canonicalization == "" {
+		err := c.dbWriteSqlc.SetMeta(ctx, sqlc_model.SetMetaParams{Key: canonicalizationKey, Value: canonicalization})
+		if err != nil {
+			return err
+		}
+		c.canonicalization = canonicalization
+
+		return nil
+	}
+
+	if c.canonicalization != canonicalization {
+		return fmt.Errorf("%w: %s is configured but the queue uses %s", ErrCanonicalizationMismatch, canonicalization, c.canonicalization)
+	}
+
+	return nil
+}
+
+// OpenReadOnly opens the existing LQ database at the given path read-only, without migrating it or creating
+// its schema, e.g. to read the queue of a finished job from the CLI
+func OpenReadOnly(dbPath string) (*LQClient, error) {
+	dbRead, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	dbRead.SetMaxOpenConns(1)
+
+	if err := dbRead.Ping(); err != nil {
+		dbRead.Close()
+		return nil, err
+	}
+
+	return &LQClient{
+		dbWrite:     dbRead,
+		dbWriteSqlc: sqlc_model.New(dbRead),
 	}, nil
 }
 
+// Close closes the LQ database
+func (c *LQClient) Close() error {
+	return c.dbWrite.Close()
+}
+
 func (c *LQClient) ResetURL(ctx context.Context, seed string) error {
 	return c.dbWriteSqlc.ResetURL(ctx, seed)
 }
 
 func (c *LQClient) Get(ctx context.Context, limit int) ([]sqlc_model.Url, error) {
-	tx, err := globalLQ.client.dbWrite.Begin()
+	tx, err := c.dbWrite.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	qtx := globalLQ.client.dbWriteSqlc.WithTx(tx)
+	qtx := c.dbWriteSqlc.WithTx(tx)
 
 	freshUrls, err := qtx.GetFreshURLs(ctx, int64(limit))
 	if err != nil {
@@ -75,27 +193,35 @@ func (c *LQClient) Get(ctx context.Context, limit int) ([]sqlc_model.Url, error)
 }
 
 func (c *LQClient) Add(ctx context.Context, urls []sqlc_model.Url, bypassSeencheck bool) error {
-	tx, err := globalLQ.client.dbWrite.Begin()
+	if err := c.checkCanonicalization(ctx); err != nil {
+		return err
+	}
+
+	tx, err := c.dbWrite.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	qtx := globalLQ.client.dbWriteSqlc.WithTx(tx)
+	qtx := c.dbWriteSqlc.WithTx(tx)
 
 	for _, url := range urls {
 		if url.ID == "" {
 			url.ID = uuid.New().String()
 		}
+		// URLs are deduplicated on their canonical key, the value being the URL fetched
 		err = qtx.AddURL(ctx, sqlc_model.AddURLParams{
-			ID:    url.ID,
-			Value: url.Value,
-			Via:   url.Via,
-			Hops:  int64(url.Hops),
+			ID:        url.ID,
+			Value:     url.Value,
+			Via:       url.Via,
+			Hops:      int64(url.Hops),
+			Canonical: canonicalizer.Key(url.Value),
+			Host:      hostOf(url.Value),
+			Priority:  url.Priority,
 		})
 		if err != nil {
-			if err.Error() == "sqlite3: constraint failed: UNIQUE constraint failed: urls.value" {
-				logger.Debug("URL.Value already exists in LQ", "value", url.Value, "via", url.Via)
+			if strings.HasPrefix(err.Error(), "sqlite3: constraint failed: UNIQUE constraint failed: urls.") {
+				logger.Debug("URL already exists in LQ", "value", url.Value, "via", url.Via)
 				continue
 			}
 			logger.Error("error adding URL", "err", err.Error(), "func", "lq.Add", "value", url.Value, "via", url.Via)
@@ -111,13 +237,13 @@ func (c *LQClient) Add(ctx context.Context, urls []sqlc_model.Url, bypassSeenche
 }
 
 func (c *LQClient) Delete(ctx context.Context, urls []sqlc_model.Url, bypassSeencheck bool) error {
-	tx, err := globalLQ.client.dbWrite.Begin()
+	tx, err := c.dbWrite.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	qtx := globalLQ.client.dbWriteSqlc.WithTx(tx)
+	qtx := c.dbWriteSqlc.WithTx(tx)
 
 	for _, url := range urls {
 		err = qtx.DeleteURL(ctx, url.ID)
@@ -132,3 +258,81 @@ func (c *LQClient) Delete(ctx context.Context, urls []sqlc_model.Url, bypassSeen
 	}
 	return nil
 }
+
+// Stats returns the number of URLs for each status
+func (c *LQClient) Stats(ctx context.Context) (map[string]int64, error) {
+	rows, err := c.dbWriteSqlc.CountURLsByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]int64{"FRESH": 0, "CLAIMED": 0, "DONE": 0}
+	for _, row := range rows {
+		stats[row.Status] = row.Count
+	}
+
+	return stats, nil
+}
+
+// List returns up to limit URLs ordered by ID, starting after afterID, optionally filtered by status.
+// Passing the ID of the last URL returned as afterID gets the next page.
+func (c *LQClient) List(ctx context.Context, status, afterID string, limit int) ([]sqlc_model.Url, error) {
+	if status == "" {
+		return c.dbWriteSqlc.ListURLs(ctx, sqlc_model.ListURLsParams{
+			ID:    afterID,
+			Limit: int64(limit),
+		})
+	}
+
+	return c.dbWriteSqlc.ListURLsByStatus(ctx, sqlc_model.ListURLsByStatusParams{
+		Status: status,
+		ID:     afterID,
+		Limit:  int64(limit),
+	})
+}
+
+// ResetClaimed sets all the CLAIMED URLs back to FRESH and returns how many were reset
+func (c *LQClient) ResetClaimed(ctx context.Context) (int64, error) {
+	return c.dbWriteSqlc.ResetClaimedURLs(ctx)
+}
+
+// PurgeDone deletes all the DONE URLs and returns how many were deleted
+func (c *LQClient) PurgeDone(ctx context.Context) (int64, error) {
+	return c.dbWriteSqlc.DeleteDoneURLs(ctx)
+}
+
+// ReclaimExpired sets back to FRESH the CLAIMED URLs whose lease wasn't renewed for longer than lease,
+// e.g. because the crawler that claimed them was killed, and returns how many were reclaimed
+func (c *LQClient) ReclaimExpired(ctx context.Context, lease time.Duration) (int64, error) {
+	return c.dbWriteSqlc.ReclaimExpiredURLs(ctx, time.Now().Add(-lease).Unix())
+}
+
+// RenewLeases refreshes the claim timestamp of the given CLAIMED URLs
+func (c *LQClient) RenewLeases(ctx context.Context, IDs []string) error {
+	tx, err := c.dbWrite.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := c.dbWriteSqlc.WithTx(tx)
+
+	for _, ID := range IDs {
+		if err = qtx.RenewURLLease(ctx, ID); err != nil {
+			logger.Error("error renewing URL lease", "err", err.Error(), "func", "lq.RenewLeases", "id", ID)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// hostOf returns the lowercased host of the URL the claims are spread across, empty if it can't be parsed
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
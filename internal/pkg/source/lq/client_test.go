@@ -0,0 +1,379 @@
+package lq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/preprocessor/canonicalizer"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
+)
+
+func TestClientManagement(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := Open(filepath.Join(t.TempDir(), "lq.db"))
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	defer client.Close()
+
+	URLs := []sqlc_model.Url{
+		{ID: "1", Value: "https://example.com/1"},
+		{ID: "2", Value: "https://example.com/2", Hops: 1, Via: "https://example.com/1"},
+		{ID: "3", Value: "https://example.com/3"},
+		{ID: "4", Value: "https://example.com/4"},
+		{ID: "5", Value: "https://example.com/1"}, // duplicate value, ignored
+	}
+
+	if err := client.Add(ctx, URLs, false); err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+
+	claimed, err := client.Get(ctx, 2)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("unable to claim URLs: %v %v", claimed, err)
+	}
+
+	if err := client.dbWriteSqlc.DoneURL(ctx, "4"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := client.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unable to get stats: %v", err)
+	}
+
+	if stats["FRESH"] != 1 || stats["CLAIMED"] != 2 || stats["DONE"] != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+
+	// Paginate over all the URLs
+	var (
+		all     []sqlc_model.Url
+		afterID string
+	)
+	for {
+		page, err := client.List(ctx, "", afterID, 3)
+		if err != nil {
+			t.Fatalf("unable to list URLs: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+		afterID = page[len(page)-1].ID
+	}
+
+	if len(all) != 4 || all[1].Via != "https://example.com/1" || all[1].Hops != 1 {
+		t.Errorf("unexpected URLs: %+v", all)
+	}
+
+	claimedList, err := client.List(ctx, "CLAIMED", "", 10)
+	if err != nil || len(claimedList) != 2 {
+		t.Errorf("expected 2 claimed URLs, got %v %v", claimedList, err)
+	}
+
+	reset, err := client.ResetClaimed(ctx)
+	if err != nil || reset != 2 {
+		t.Errorf("expected 2 URLs reset, got %d %v", reset, err)
+	}
+
+	purged, err := client.PurgeDone(ctx)
+	if err != nil || purged != 1 {
+		t.Errorf("expected 1 URL purged, got %d %v", purged, err)
+	}
+
+	stats, err = client.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unable to get stats: %v", err)
+	}
+
+	if stats["FRESH"] != 3 || stats["CLAIMED"] != 0 || stats["DONE"] != 0 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestClientLeases(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := Open(filepath.Join(t.TempDir(), "lq.db"))
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	defer client.Close()
+
+	URLs := []sqlc_model.Url{
+		{ID: "1", Value: "https://example.com/1"},
+		{ID: "2", Value: "https://example.com/2"},
+		{ID: "3", Value: "https://example.com/3"},
+	}
+
+	if err := client.Add(ctx, URLs, false); err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+
+	if claimed, err := client.Get(ctx, 3); err != nil || len(claimed) != 3 {
+		t.Fatalf("unable to claim URLs: %v %v", claimed, err)
+	}
+
+	// Freshly claimed URLs are not reclaimed
+	reclaimed, err := client.ReclaimExpired(ctx, 10*time.Minute)
+	if err != nil || reclaimed != 0 {
+		t.Fatalf("expected no URL reclaimed, got %d %v", reclaimed, err)
+	}
+
+	// Simulate a crawler killed an hour ago, then a heartbeat renewing the lease of URL 1
+	if _, err := client.dbWrite.Exec("UPDATE urls SET timestamp = timestamp - 3600"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.RenewLeases(ctx, []string{"1"}); err != nil {
+		t.Fatalf("unable to renew leases: %v", err)
+	}
+
+	reclaimed, err = client.ReclaimExpired(ctx, 10*time.Minute)
+	if err != nil || reclaimed != 2 {
+		t.Fatalf("expected 2 URLs reclaimed, got %d %v", reclaimed, err)
+	}
+
+	stats, err := client.Stats(ctx)
+	if err != nil {
+		t.Fatalf("unable to get stats: %v", err)
+	}
+
+	if stats["FRESH"] != 2 || stats["CLAIMED"] != 1 {
+		t.Errorf("unexpected stats: %v", stats)
+	}
+}
+
+func TestClientCanonicalDeduplication(t *testing.T) {
+	ctx := context.Background()
+
+	if err := canonicalizer.Start(canonicalizer.Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	defer canonicalizer.Stop()
+
+	client, err := Open(filepath.Join(t.TempDir(), "lq.db"))
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	defer client.Close()
+
+	URLs := []sqlc_model.Url{
+		{ID: "1", Value: "https://www.example.com/?b=2&a=1"},
+		{ID: "2", Value: "https://example.com/?a=1&b=2&utm_source=feed"}, // same canonical key, ignored
+		{ID: "3", Value: "https://example.com/?a=1&b=3"},
+	}
+
+	if err := client.Add(ctx, URLs, false); err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+
+	all, err := client.List(ctx, "", "", 10)
+	if err != nil {
+		t.Fatalf("unable to list URLs: %v", err)
+	}
+
+	// The original URL is kept as the value to fetch
+	if len(all) != 2 || all[0].Value != "https://www.example.com/?b=2&a=1" || all[0].Canonical != "com,example)/?a=1&b=2" {
+		t.Errorf("unexpected URLs: %+v", all)
+	}
+}
+
+func TestClientCanonicalization(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "lq.db")
+
+	if err := canonicalizer.Start(canonicalizer.Settings{}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	err = client.Add(ctx, []sqlc_model.Url{{ID: "1", Value: "https://example.com/?utm_source=feed"}}, false)
+	client.Close()
+	canonicalizer.Stop()
+	if err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+
+	// The URLs can't be keyed differently than the ones already queued
+	client, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	defer client.Close()
+
+	err = client.Add(ctx, []sqlc_model.Url{{ID: "2", Value: "https://example.com/"}}, false)
+	if !errors.Is(err, ErrCanonicalizationMismatch) {
+		t.Errorf("expected a canonicalization mismatch, got %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "lq.db")
+
+	// Database created by a version without the canonical column
+	db, err := sql.Open("sqlite3", "file:"+dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE urls (
+    id TEXT NOT NULL PRIMARY KEY,
+    value TEXT NOT NULL,
+    via TEXT DEFAULT '' NOT NULL,
+    hops INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'FRESH' CHECK (status IN ('FRESH', 'CLAIMED', 'DONE')),
+    timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+);
+CREATE UNIQUE INDEX urls_value ON urls (value);
+CREATE INDEX urls_status ON urls (status);
+INSERT INTO urls (id, value) VALUES ('1', 'https://example.com/1'), ('2', 'https://Example.org:8080/2');`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("unable to open the old LQ: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Add(ctx, []sqlc_model.Url{{ID: "3", Value: "https://example.com/1"}, {ID: "4", Value: "https://example.com/4"}}, false); err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+
+	all, err := client.List(ctx, "", "", 10)
+	if err != nil {
+		t.Fatalf("unable to list URLs: %v", err)
+	}
+
+	if len(all) != 3 || all[0].Canonical != "https://example.com/1" || all[0].Host != "example.com" || all[1].Host != "example.org" || all[1].HostSeq != 1 || all[2].HostSeq != 2 {
+		t.Errorf("unexpected URLs: %+v", all)
+	}
+
+	// The URLs queued before the canonicalization were keyed with their value
+	if err := canonicalizer.Start(canonicalizer.Settings{}); err != nil {
+		t.Fatal(err)
+	}
+	defer canonicalizer.Stop()
+
+	if err := client.Add(ctx, []sqlc_model.Url{{ID: "5", Value: "https://example.com/5"}}, false); !errors.Is(err, ErrCanonicalizationMismatch) {
+		t.Errorf("expected a canonicalization mismatch, got %v", err)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "lq.db")
+
+	client, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	if err := client.Add(ctx, []sqlc_model.Url{{ID: "1", Value: "https://example.com/1"}}, false); err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+	client.Close()
+
+	readOnly, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("unable to open LQ read-only: %v", err)
+	}
+	defer readOnly.Close()
+
+	if all, err := readOnly.List(ctx, "", "", 10); err != nil || len(all) != 1 {
+		t.Errorf("unexpected URLs: %+v, %v", all, err)
+	}
+
+	if err := readOnly.Add(ctx, []sqlc_model.Url{{ID: "2", Value: "https://example.com/2"}}, false); err == nil {
+		t.Error("expected adding URLs to fail")
+	}
+
+	// A database created by a version without the canonical column isn't migrated
+	oldPath := filepath.Join(t.TempDir(), "lq.db")
+	db, err := sql.Open("sqlite3", "file:"+oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE urls (id TEXT NOT NULL PRIMARY KEY, value TEXT NOT NULL);`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := OpenReadOnly(oldPath)
+	if err != nil {
+		t.Fatalf("unable to open the old LQ read-only: %v", err)
+	}
+	defer old.Close()
+
+	if _, err := old.List(ctx, "", "", 10); err == nil {
+		t.Error("expected listing the URLs of the old LQ to fail")
+	}
+
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("expected opening a missing LQ to fail")
+	}
+}
+
+func TestClientClaimOrder(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := Open(filepath.Join(t.TempDir(), "lq.db"))
+	if err != nil {
+		t.Fatalf("unable to open LQ: %v", err)
+	}
+	defer client.Close()
+
+	// The outlinks of a big host are queued before the ones of the other hosts
+	var URLs []sqlc_model.Url
+	for i := 1; i <= 5; i++ {
+		URLs = append(URLs, sqlc_model.Url{ID: fmt.Sprintf("a%d", i), Value: fmt.Sprintf("https://a.example.com/%d", i), Hops: 1})
+	}
+	URLs = append(URLs,
+		sqlc_model.Url{ID: "b1", Value: "https://b.example.com/1", Hops: 1},
+		sqlc_model.Url{ID: "b2", Value: "https://b.example.com/2", Hops: 1},
+		sqlc_model.Url{ID: "c1", Value: "https://c.example.com/1", Hops: 1},
+		sqlc_model.Url{ID: "a0", Value: "https://a.example.com/", Hops: 0},
+		sqlc_model.Url{ID: "c0", Value: "https://c.example.com/", Hops: 0},
+		sqlc_model.Url{ID: "d2", Value: "https://d.example.com/2", Hops: 2, Priority: 1},
+	)
+
+	if err := client.Add(ctx, URLs, false); err != nil {
+		t.Fatalf("unable to add URLs: %v", err)
+	}
+
+	expected := [][]string{
+		{"d2", "a0", "c0", "a1"},
+		{"b1", "c1", "a2", "b2"},
+		{"a3", "a4", "a5"},
+	}
+
+	for _, batch := range expected {
+		claimed, err := client.Get(ctx, 4)
+		if err != nil {
+			t.Fatalf("unable to claim URLs: %v", err)
+		}
+
+		var IDs []string
+		for _, URL := range claimed {
+			IDs = append(IDs, URL.ID)
+		}
+
+		if strings.Join(IDs, ",") != strings.Join(batch, ",") {
+			t.Errorf("expected %v to be claimed, got %v", batch, IDs)
+		}
+	}
+}
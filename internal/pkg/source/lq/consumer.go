@@ -19,7 +19,7 @@ func consumer() {
 		"component": "lq.consumer",
 	})
 
-	ctx, cancel := context.WithCancel(globalLQ.ctx)
+	ctx, cancel := context.WithCancel(globalLQ.consumerCtx)
 	defer cancel()
 
 	// Set the batch size for fetching URLs
@@ -41,7 +41,7 @@ func consumer() {
 	// Wait for shutdown signal
 	for {
 		select {
-		case <-globalLQ.ctx.Done():
+		case <-globalLQ.consumerCtx.Done():
 			logger.Debug("received done signal")
 
 			// Cancel the context to stop all goroutines.
@@ -55,6 +55,11 @@ func consumer() {
 			// Close the urlBuffer to signal consumerSenders to finish
 			close(urlBuffer)
 
+			// URLs left in the buffer were claimed but never reached the reactor, give them back
+			for URL := range urlBuffer {
+				resetURL(URL.ID)
+			}
+
 			globalLQ.wg.Done()
 
 			logger.Debug("closed")
@@ -101,6 +106,9 @@ func consumerFetcher(ctx context.Context, wg *sync.WaitGroup, urlBuffer chan<- *
 		for i := range URLs {
 			select {
 			case <-ctx.Done():
+				for j := i; j < len(URLs); j++ {
+					resetURL(URLs[j].ID)
+				}
 				logger.Debug("closed")
 				return
 			case urlBuffer <- &sqlc_model.Url{
@@ -110,6 +118,10 @@ func consumerFetcher(ctx context.Context, wg *sync.WaitGroup, urlBuffer chan<- *
 				Hops:      URLs[i].Hops,
 				Status:    URLs[i].Status,
 				Timestamp: URLs[i].Timestamp,
+				Canonical: URLs[i].Canonical,
+				Host:      URLs[i].Host,
+				Priority:  URLs[i].Priority,
+				HostSeq:   URLs[i].HostSeq,
 			}: //Deep copy of the URL to ensure pointer alisaing does not cause issues
 			}
 		}
@@ -169,6 +181,8 @@ func consumerSender(ctx context.Context, wg *sync.WaitGroup, urlBuffer <-chan *s
 			err = reactor.ReceiveInsert(newItem)
 			if err != nil {
 				if err == reactor.ErrReactorFrozen {
+					// The seed was claimed but will not be processed, give it back before waiting for the stop
+					resetURL(URL.ID)
 					select {
 					case <-ctx.Done():
 						logger.Debug("closed while sending to frozen reactor")
@@ -182,7 +196,26 @@ func consumerSender(ctx context.Context, wg *sync.WaitGroup, urlBuffer <-chan *s
 }
 
 func getURLs(batchSize int) ([]sqlc_model.Url, error) {
-	return globalLQ.client.Get(context.TODO(), batchSize)
+	URLs, err := globalLQ.client.Get(context.TODO(), batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range URLs {
+		globalLQ.leases.add(URLs[i].ID)
+	}
+
+	return URLs, nil
+}
+
+// resetURL gives back a claimed URL that never made it to the reactor
+func resetURL(ID string) {
+	if err := globalLQ.client.ResetURL(context.TODO(), ID); err != nil {
+		logger.Error("error while reseting", "id", ID, "err", err)
+		return
+	}
+	globalLQ.leases.remove(ID)
+	logger.Debug("reset seed", "id", ID)
 }
 
 func ensureAllIDsNotInReactor(URLs []sqlc_model.Url) error {
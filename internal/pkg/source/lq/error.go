@@ -5,4 +5,8 @@ import "errors"
 var (
 	//  is the error returned when the postprocessor is already initialized
 	ErrLQAlreadyInitialized = errors.New("lq client already initialized")
+	// ErrLQNotInitialized is the error returned when the LQ is used before being started
+	ErrLQNotInitialized = errors.New("lq client not initialized")
+	// ErrCanonicalizationMismatch is the error returned when the configured canonicalization isn't the one of the queued URLs
+	ErrCanonicalizationMismatch = errors.New("lq canonicalization doesn't match the one of the queued URLs")
 )
@@ -197,6 +197,10 @@ func finisherSender(ctx context.Context, batch *finishBatch, batchUUID string) {
 				time.Sleep(time.Second)
 				continue
 			}
+
+			for i := range batch.URLs {
+				globalLQ.leases.remove(batch.URLs[i].ID)
+			}
 			return
 		}
 	}
@@ -0,0 +1,102 @@
+package lq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/config"
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+)
+
+// leases keeps track of the URLs claimed by this crawler, from the moment they are claimed
+// until they are deleted from the LQ or given back, so that their lease can be renewed
+type leases struct {
+	sync.Mutex
+	IDs map[string]struct{}
+}
+
+func newLeases() *leases {
+	return &leases{IDs: make(map[string]struct{})}
+}
+
+func (l *leases) add(IDs ...string) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, ID := range IDs {
+		l.IDs[ID] = struct{}{}
+	}
+}
+
+func (l *leases) remove(IDs ...string) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, ID := range IDs {
+		delete(l.IDs, ID)
+	}
+}
+
+func (l *leases) list() []string {
+	l.Lock()
+	defer l.Unlock()
+
+	IDs := make([]string, 0, len(l.IDs))
+	for ID := range l.IDs {
+		IDs = append(IDs, ID)
+	}
+
+	return IDs
+}
+
+// reclaimExpired gives back to the queue the URLs claimed by a crawler that didn't renew their lease in time
+func reclaimExpired(lease time.Duration) {
+	reclaimed, err := globalLQ.client.ReclaimExpired(context.TODO(), lease)
+	if err != nil {
+		logger.Error("error reclaiming expired URLs", "err", err.Error(), "func", "lq.reclaimExpired")
+		return
+	}
+
+	if reclaimed > 0 {
+		logger.Info("reclaimed URLs with an expired lease", "count", reclaimed, "lease", lease.String())
+	}
+}
+
+// heartbeat periodically renews the lease of the URLs claimed by this crawler,
+// then reclaims the URLs whose lease expired
+func heartbeat() {
+	defer globalLQ.wg.Done()
+
+	logger := log.NewFieldedLogger(&log.Fields{
+		"component": "lq.heartbeat",
+	})
+
+	lease := config.Get().LQLease
+	if lease <= 0 {
+		logger.Debug("leases disabled")
+		return
+	}
+
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-globalLQ.ctx.Done():
+			logger.Debug("closed")
+			return
+		case <-ticker.C:
+			IDs := globalLQ.leases.list()
+			if len(IDs) > 0 {
+				if err := globalLQ.client.RenewLeases(context.TODO(), IDs); err != nil {
+					logger.Error("error renewing leases", "err", err.Error(), "count", len(IDs))
+					continue
+				}
+				logger.Debug("renewed leases", "count", len(IDs))
+			}
+
+			reclaimExpired(lease)
+		}
+	}
+}
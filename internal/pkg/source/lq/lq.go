@@ -7,17 +7,22 @@ import (
 	"github.com/internetarchive/Zeno/internal/pkg/config"
 	"github.com/internetarchive/Zeno/internal/pkg/log"
 	"github.com/internetarchive/Zeno/internal/pkg/reactor"
+	"github.com/internetarchive/Zeno/internal/pkg/source/lq/sqlc_model"
 	"github.com/internetarchive/Zeno/internal/pkg/stats"
 	"github.com/internetarchive/Zeno/pkg/models"
 )
 
 type lq struct {
-	wg        sync.WaitGroup
-	ctx       context.Context
-	cancel    context.CancelFunc
-	finishCh  chan *models.Item
-	produceCh chan *models.Item
-	client    *LQClient
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	// consumerCtx is canceled to stop claiming URLs while the rest of the LQ keeps running
+	consumerCtx    context.Context
+	consumerCancel context.CancelFunc
+	finishCh       chan *models.Item
+	produceCh      chan *models.Item
+	client         *LQClient
+	leases         *leases
 }
 
 var (
@@ -48,19 +53,30 @@ func Start(finishChan, produceChan chan *models.Item) error {
 			return
 		}
 
+		consumerCtx, consumerCancel := context.WithCancel(ctx)
+
 		globalLQ = &lq{
-			wg:        sync.WaitGroup{},
-			ctx:       ctx,
-			cancel:    cancel,
-			finishCh:  finishChan,
-			produceCh: produceChan,
-			client:    LQclient,
+			wg:             sync.WaitGroup{},
+			ctx:            ctx,
+			cancel:         cancel,
+			consumerCtx:    consumerCtx,
+			consumerCancel: consumerCancel,
+			finishCh:       finishChan,
+			produceCh:      produceChan,
+			client:         LQclient,
+			leases:         newLeases(),
+		}
+
+		// URLs left CLAIMED by a crawler that was killed are given back before starting
+		if config.Get().LQLease > 0 {
+			reclaimExpired(config.Get().LQLease)
 		}
 
-		globalLQ.wg.Add(3)
+		globalLQ.wg.Add(4)
 		go consumer()
 		go producer()
 		go finisher()
+		go heartbeat()
 
 		logger.Info("started")
 
@@ -78,14 +94,41 @@ func Stop() {
 	if globalLQ != nil {
 		globalLQ.cancel()
 		globalLQ.wg.Wait()
-		seedsToReset := reactor.GetStateTable()
-		for _, seed := range seedsToReset {
-			if err := globalLQ.client.ResetURL(context.TODO(), seed); err != nil {
-				logger.Error("error while reseting", "id", seed, "err", err)
-			}
-			logger.Debug("reset seed", "id", seed)
-		}
+		ResetSeeds()
 		once = sync.Once{}
 		logger.Info("stopped")
 	}
 }
+
+// StopConsumer stops claiming new URLs, the URLs claimed but not yet sent to the reactor are given back.
+// The seeds in flight are still finished and their outlinks queued, e.g. while draining the reactor.
+func StopConsumer() {
+	if globalLQ != nil {
+		globalLQ.consumerCancel()
+	}
+}
+
+// ResetSeeds gives back to the LQ the seeds currently claimed by the reactor, so they can be crawled again.
+func ResetSeeds() {
+	if globalLQ == nil {
+		return
+	}
+
+	seedsToReset := reactor.GetStateTable()
+	for _, seed := range seedsToReset {
+		if err := globalLQ.client.ResetURL(context.TODO(), seed); err != nil {
+			logger.Error("error while reseting", "id", seed, "err", err)
+		}
+		globalLQ.leases.remove(seed)
+		logger.Debug("reset seed", "id", seed)
+	}
+}
+
+// Add inserts URLs in the running LQ, URLs already present in the queue are ignored.
+func Add(ctx context.Context, URLs []sqlc_model.Url) error {
+	if globalLQ == nil {
+		return ErrLQNotInitialized
+	}
+
+	return globalLQ.client.Add(ctx, URLs, false)
+}
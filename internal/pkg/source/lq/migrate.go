@@ -0,0 +1,126 @@
+package lq
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// columnMigration adds a column introduced after the first version of the schema to an existing database
+type columnMigration struct {
+	column     string
+	definition string
+	// backfill fills the column of the existing rows, if not empty
+	backfill string
+	// backfillFunc fills the column of the existing rows when it can't be done in SQL, if not nil
+	backfillFunc func(tx *sql.Tx) error
+}
+
+var columnMigrations = []columnMigration{
+	// The URLs queued before the canonicalization are deduplicated on their value
+	{column: "canonical", definition: "TEXT NOT NULL DEFAULT ''", backfill: "UPDATE urls SET canonical = value"},
+	// The URLs queued before the host-fair claiming are spread across their hosts too
+	{column: "host", definition: "TEXT NOT NULL DEFAULT ''", backfillFunc: backfillHosts},
+	{column: "priority", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{column: "host_seq", definition: "INTEGER NOT NULL DEFAULT 0", backfill: `UPDATE urls SET host_seq = sequenced.host_seq
+FROM (SELECT rowid AS position, ROW_NUMBER() OVER (PARTITION BY host, priority, hops ORDER BY rowid) AS host_seq FROM urls) AS sequenced
+WHERE urls.rowid = sequenced.position`},
+}
+
+// migrate brings the urls table of a database created by a previous version up to date,
+// before the schema is applied so that its indexes can use the new columns
+func migrate(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('urls')")
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// New database, created by the schema
+	if len(columns) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, migration := range columnMigrations {
+		if columns[migration.column] {
+			continue
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE urls ADD COLUMN %s %s", migration.column, migration.definition)); err != nil {
+			return fmt.Errorf("unable to add column %s: %w", migration.column, err)
+		}
+
+		if migration.backfill != "" {
+			if _, err := tx.Exec(migration.backfill); err != nil {
+				return fmt.Errorf("unable to backfill column %s: %w", migration.column, err)
+			}
+		}
+
+		if migration.backfillFunc != nil {
+			if err := migration.backfillFunc(tx); err != nil {
+				return fmt.Errorf("unable to backfill column %s: %w", migration.column, err)
+			}
+		}
+
+		logger.Info("lq database migrated", "column", migration.column)
+	}
+
+	return tx.Commit()
+}
+
+// backfillHosts sets the host of the existing rows from their value, in batches
+func backfillHosts(tx *sql.Tx) error {
+	const batchSize = 10000
+
+	var position int64
+	for {
+		rows, err := tx.Query("SELECT rowid, value FROM urls WHERE rowid > ? ORDER BY rowid LIMIT ?", position, batchSize)
+		if err != nil {
+			return err
+		}
+
+		hosts := make(map[int64]string, batchSize)
+		for rows.Next() {
+			var value string
+			if err := rows.Scan(&position, &value); err != nil {
+				rows.Close()
+				return err
+			}
+			hosts[position] = hostOf(value)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(hosts) == 0 {
+			return nil
+		}
+
+		for rowid, host := range hosts {
+			if _, err := tx.Exec("UPDATE urls SET host = ? WHERE rowid = ?", host, rowid); err != nil {
+				return err
+			}
+		}
+	}
+}
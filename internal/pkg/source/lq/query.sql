@@ -1,6 +1,9 @@
 -- name: GetFreshURLs :many
+-- The URLs with the highest priority, then the lowest hops, are claimed first, round-robin across hosts:
+-- the first URL queued for each host, then the second, etc.
 SELECT * FROM urls
 WHERE status = 'FRESH'
+ORDER BY priority DESC, hops, host_seq, rowid
 LIMIT ?;
 
 -- name: ClaimThisURL :exec
@@ -14,8 +17,12 @@ SET status = 'FRESH', timestamp = strftime('%s', 'now')
 WHERE id = ?;
 
 -- name: AddURL :exec
-INSERT INTO urls (id, value, via, hops)
-VALUES (?, ?, ?, ?);
+-- host_seq is the position of the URL among the URLs of its host with the same priority and hops
+INSERT INTO urls (id, value, via, hops, canonical, host, priority, host_seq)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, (
+    SELECT COALESCE(MAX(host_seq), 0) + 1 FROM urls
+    WHERE host = ?6 AND priority = ?7 AND hops = ?4
+));
 
 -- name: DoneURL :exec
 UPDATE urls
@@ -25,3 +32,47 @@ WHERE id = ?;
 -- name: DeleteURL :exec
 DELETE FROM urls
 WHERE id = ?;
+
+-- name: CountURLsByStatus :many
+SELECT status, COUNT(*) AS count FROM urls
+GROUP BY status;
+
+-- name: ListURLs :many
+SELECT * FROM urls
+WHERE id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: ListURLsByStatus :many
+SELECT * FROM urls
+WHERE status = ? AND id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: ResetClaimedURLs :execrows
+UPDATE urls
+SET status = 'FRESH', timestamp = strftime('%s', 'now')
+WHERE status = 'CLAIMED';
+
+-- name: DeleteDoneURLs :execrows
+DELETE FROM urls
+WHERE status = 'DONE';
+
+-- name: ReclaimExpiredURLs :execrows
+UPDATE urls
+SET status = 'FRESH', timestamp = strftime('%s', 'now')
+WHERE status = 'CLAIMED' AND timestamp < ?;
+
+-- name: RenewURLLease :exec
+UPDATE urls
+SET timestamp = strftime('%s', 'now')
+WHERE id = ? AND status = 'CLAIMED';
+
+-- name: GetMeta :one
+SELECT value FROM meta
+WHERE key = ?;
+
+-- name: SetMeta :exec
+INSERT INTO meta (key, value)
+VALUES (?, ?)
+ON CONFLICT (key) DO UPDATE SET value = excluded.value;
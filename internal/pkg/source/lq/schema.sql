@@ -4,7 +4,18 @@ CREATE TABLE IF NOT EXISTS urls (
     via TEXT DEFAULT '' NOT NULL,
     hops INTEGER NOT NULL DEFAULT 0,
     status TEXT NOT NULL DEFAULT 'FRESH' CHECK (status IN ('FRESH', 'CLAIMED', 'DONE')),
-    timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now'))
+    timestamp INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    canonical TEXT NOT NULL DEFAULT '',
+    host TEXT NOT NULL DEFAULT '',
+    priority INTEGER NOT NULL DEFAULT 0,
+    host_seq INTEGER NOT NULL DEFAULT 0
 );
-CREATE UNIQUE INDEX IF NOT EXISTS urls_value ON urls (value); -- for deduplication
-CREATE INDEX IF NOT EXISTS urls_status ON urls (status); -- for queueing
+CREATE UNIQUE INDEX IF NOT EXISTS urls_value ON urls (value);
+CREATE UNIQUE INDEX IF NOT EXISTS urls_canonical ON urls (canonical); -- for deduplication
+CREATE INDEX IF NOT EXISTS urls_claim ON urls (status, priority DESC, hops, host_seq); -- for queueing across hosts
+CREATE INDEX IF NOT EXISTS urls_host_seq ON urls (host, priority, hops, host_seq); -- for sequencing the URLs of each host
+DROP INDEX IF EXISTS urls_status; -- replaced by urls_claim
+CREATE TABLE IF NOT EXISTS meta (
+    key TEXT NOT NULL PRIMARY KEY,
+    value TEXT NOT NULL
+); -- settings the URLs are queued with, e.g. the canonicalization of their canonical key
@@ -4,6 +4,11 @@
 
 package sqlc_model
 
+type Meta struct {
+	Key   string
+	Value string
+}
+
 type Url struct {
 	ID        string
 	Value     string
@@ -11,4 +16,8 @@ type Url struct {
 	Hops      int64
 	Status    string
 	Timestamp int64
+	Canonical string
+	Host      string
+	Priority  int64
+	HostSeq   int64
 }
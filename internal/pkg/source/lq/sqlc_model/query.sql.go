@@ -10,23 +10,33 @@ import (
 )
 
 const addURL = `-- name: AddURL :exec
-INSERT INTO urls (id, value, via, hops)
-VALUES (?, ?, ?, ?)
+INSERT INTO urls (id, value, via, hops, canonical, host, priority, host_seq)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, (
+    SELECT COALESCE(MAX(host_seq), 0) + 1 FROM urls
+    WHERE host = ?6 AND priority = ?7 AND hops = ?4
+))
 `
 
 type AddURLParams struct {
-	ID    string
-	Value string
-	Via   string
-	Hops  int64
+	ID        string
+	Value     string
+	Via       string
+	Hops      int64
+	Canonical string
+	Host      string
+	Priority  int64
 }
 
+// host_seq is the position of the URL among the URLs of its host with the same priority and hops
 func (q *Queries) AddURL(ctx context.Context, arg AddURLParams) error {
 	_, err := q.db.ExecContext(ctx, addURL,
 		arg.ID,
 		arg.Value,
 		arg.Via,
 		arg.Hops,
+		arg.Canonical,
+		arg.Host,
+		arg.Priority,
 	)
 	return err
 }
@@ -42,6 +52,52 @@ func (q *Queries) ClaimThisURL(ctx context.Context, id string) error {
 	return err
 }
 
+const countURLsByStatus = `-- name: CountURLsByStatus :many
+SELECT status, COUNT(*) AS count FROM urls
+GROUP BY status
+`
+
+type CountURLsByStatusRow struct {
+	Status string
+	Count  int64
+}
+
+func (q *Queries) CountURLsByStatus(ctx context.Context) ([]CountURLsByStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, countURLsByStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountURLsByStatusRow
+	for rows.Next() {
+		var i CountURLsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteDoneURLs = `-- name: DeleteDoneURLs :execrows
+DELETE FROM urls
+WHERE status = 'DONE'
+`
+
+func (q *Queries) DeleteDoneURLs(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteDoneURLs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteURL = `-- name: DeleteURL :exec
 DELETE FROM urls
 WHERE id = ?
@@ -64,11 +120,14 @@ func (q *Queries) DoneURL(ctx context.Context, id string) error {
 }
 
 const getFreshURLs = `-- name: GetFreshURLs :many
-SELECT id, value, via, hops, status, timestamp FROM urls
+SELECT id, value, via, hops, status, timestamp, canonical, host, priority, host_seq FROM urls
 WHERE status = 'FRESH'
+ORDER BY priority DESC, hops, host_seq, rowid
 LIMIT ?
 `
 
+// The URLs with the highest priority, then the lowest hops, are claimed first, round-robin across hosts:
+// the first URL queued for each host, then the second, etc.
 func (q *Queries) GetFreshURLs(ctx context.Context, limit int64) ([]Url, error) {
 	rows, err := q.db.QueryContext(ctx, getFreshURLs, limit)
 	if err != nil {
@@ -85,6 +144,115 @@ func (q *Queries) GetFreshURLs(ctx context.Context, limit int64) ([]Url, error)
 			&i.Hops,
 			&i.Status,
 			&i.Timestamp,
+			&i.Canonical,
+			&i.Host,
+			&i.Priority,
+			&i.HostSeq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMeta = `-- name: GetMeta :one
+SELECT value FROM meta
+WHERE key = ?
+`
+
+func (q *Queries) GetMeta(ctx context.Context, key string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getMeta, key)
+	var value string
+	err := row.Scan(&value)
+	return value, err
+}
+
+const listURLs = `-- name: ListURLs :many
+SELECT id, value, via, hops, status, timestamp, canonical, host, priority, host_seq FROM urls
+WHERE id > ?
+ORDER BY id
+LIMIT ?
+`
+
+type ListURLsParams struct {
+	ID    string
+	Limit int64
+}
+
+func (q *Queries) ListURLs(ctx context.Context, arg ListURLsParams) ([]Url, error) {
+	rows, err := q.db.QueryContext(ctx, listURLs, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Url
+	for rows.Next() {
+		var i Url
+		if err := rows.Scan(
+			&i.ID,
+			&i.Value,
+			&i.Via,
+			&i.Hops,
+			&i.Status,
+			&i.Timestamp,
+			&i.Canonical,
+			&i.Host,
+			&i.Priority,
+			&i.HostSeq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listURLsByStatus = `-- name: ListURLsByStatus :many
+SELECT id, value, via, hops, status, timestamp, canonical, host, priority, host_seq FROM urls
+WHERE status = ? AND id > ?
+ORDER BY id
+LIMIT ?
+`
+
+type ListURLsByStatusParams struct {
+	Status string
+	ID     string
+	Limit  int64
+}
+
+func (q *Queries) ListURLsByStatus(ctx context.Context, arg ListURLsByStatusParams) ([]Url, error) {
+	rows, err := q.db.QueryContext(ctx, listURLsByStatus, arg.Status, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Url
+	for rows.Next() {
+		var i Url
+		if err := rows.Scan(
+			&i.ID,
+			&i.Value,
+			&i.Via,
+			&i.Hops,
+			&i.Status,
+			&i.Timestamp,
+			&i.Canonical,
+			&i.Host,
+			&i.Priority,
+			&i.HostSeq,
 		); err != nil {
 			return nil, err
 		}
@@ -99,6 +267,45 @@ func (q *Queries) GetFreshURLs(ctx context.Context, limit int64) ([]Url, error)
 	return items, nil
 }
 
+const reclaimExpiredURLs = `-- name: ReclaimExpiredURLs :execrows
+UPDATE urls
+SET status = 'FRESH', timestamp = strftime('%s', 'now')
+WHERE status = 'CLAIMED' AND timestamp < ?
+`
+
+func (q *Queries) ReclaimExpiredURLs(ctx context.Context, timestamp int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reclaimExpiredURLs, timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const renewURLLease = `-- name: RenewURLLease :exec
+UPDATE urls
+SET timestamp = strftime('%s', 'now')
+WHERE id = ? AND status = 'CLAIMED'
+`
+
+func (q *Queries) RenewURLLease(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, renewURLLease, id)
+	return err
+}
+
+const resetClaimedURLs = `-- name: ResetClaimedURLs :execrows
+UPDATE urls
+SET status = 'FRESH', timestamp = strftime('%s', 'now')
+WHERE status = 'CLAIMED'
+`
+
+func (q *Queries) ResetClaimedURLs(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resetClaimedURLs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetURL = `-- name: ResetURL :exec
 UPDATE urls
 SET status = 'FRESH', timestamp = strftime('%s', 'now')
@@ -109,3 +316,19 @@ func (q *Queries) ResetURL(ctx context.Context, id string) error {
 	_, err := q.db.ExecContext(ctx, resetURL, id)
 	return err
 }
+
+const setMeta = `-- name: SetMeta :exec
+INSERT INTO meta (key, value)
+VALUES (?, ?)
+ON CONFLICT (key) DO UPDATE SET value = excluded.value
+`
+
+type SetMetaParams struct {
+	Key   string
+	Value string
+}
+
+func (q *Queries) SetMeta(ctx context.Context, arg SetMetaParams) error {
+	_, err := q.db.ExecContext(ctx, setMeta, arg.Key, arg.Value)
+	return err
+}
@@ -225,6 +225,117 @@ func WarcWritingQueueSizeGet() int64 { return globalStats.WARCWritingQueueSize.L
 // WarcWritingQueueSizeReset resets the WarcWritingQueueSize to 0.
 func WarcWritingQueueSizeReset() { globalStats.WARCWritingQueueSize.Store(0) }
 
+//////////////////////////
+//    HostsSaturated    //
+//////////////////////////
+
+// HostsSaturatedSet sets the number of hosts with requests waiting for their concurrency limit,
+// and the total number of waiting requests.
+func HostsSaturatedSet(hosts, waiting int64) {
+	globalStats.HostsSaturated.Store(hosts)
+	globalStats.RequestsWaitingForHost.Store(waiting)
+	if globalPromStats != nil {
+		globalPromStats.hostsSaturated.WithLabelValues(config.Get().Job, hostname, version).Set(float64(hosts))
+		globalPromStats.requestsWaitingForHost.WithLabelValues(config.Get().Job, hostname, version).Set(float64(waiting))
+	}
+}
+
+// HostsSaturatedGet returns the number of hosts with requests waiting for their concurrency limit.
+func HostsSaturatedGet() int64 { return globalStats.HostsSaturated.Load() }
+
+// RequestsWaitingForHostGet returns the number of requests waiting for their host concurrency limit.
+func RequestsWaitingForHostGet() int64 { return globalStats.RequestsWaitingForHost.Load() }
+
+//////////////////////////
+//     WARCUploads      //
+//////////////////////////
+
+// WARCUploadedIncr increments the number of WARC files uploaded by 1 and the uploaded bytes by the given size.
+func WARCUploadedIncr(bytes int64) {
+	globalStats.WARCsUploaded.Add(1)
+	globalStats.WARCUploadedBytes.Add(bytes)
+	if globalPromStats != nil {
+		globalPromStats.warcsUploaded.WithLabelValues(config.Get().Job, hostname, version).Inc()
+		globalPromStats.warcUploadedBytes.WithLabelValues(config.Get().Job, hostname, version).Add(float64(bytes))
+	}
+}
+
+// WARCsUploadedGet returns the number of WARC files uploaded.
+func WARCsUploadedGet() int64 { return globalStats.WARCsUploaded.Load() }
+
+// WARCUploadedBytesGet returns the number of bytes of WARC files uploaded.
+func WARCUploadedBytesGet() int64 { return globalStats.WARCUploadedBytes.Load() }
+
+// WARCUploadFailuresIncr increments the number of failed WARC file uploads by 1.
+func WARCUploadFailuresIncr() {
+	globalStats.WARCUploadFailures.Add(1)
+	if globalPromStats != nil {
+		globalPromStats.warcUploadFailures.WithLabelValues(config.Get().Job, hostname, version).Inc()
+	}
+}
+
+// WARCUploadFailuresGet returns the number of failed WARC file uploads.
+func WARCUploadFailuresGet() int64 { return globalStats.WARCUploadFailures.Load() }
+
+// WARCUploadQueueSizeSet sets the number of closed WARC files waiting to be uploaded.
+func WARCUploadQueueSizeSet(value int64) {
+	globalStats.WARCUploadQueueSize.Store(value)
+	if globalPromStats != nil {
+		globalPromStats.warcUploadQueueSize.WithLabelValues(config.Get().Job, hostname, version).Set(float64(value))
+	}
+}
+
+// WARCUploadQueueSizeGet returns the number of closed WARC files waiting to be uploaded.
+func WARCUploadQueueSizeGet() int64 { return globalStats.WARCUploadQueueSize.Load() }
+
+//////////////////////////
+//   SeencheckFilter    //
+//////////////////////////
+
+// SeencheckFilterHitsIncr increments by 1 the number of URLs answered as unseen by the seencheck filter.
+func SeencheckFilterHitsIncr() {
+	globalStats.SeencheckFilterHits.Add(1)
+	if globalPromStats != nil {
+		globalPromStats.seencheckFilterHits.WithLabelValues(config.Get().Job, hostname, version).Inc()
+	}
+}
+
+// SeencheckFilterMissesIncr increments by 1 the number of URLs looked up in the seencheck database after the filter.
+func SeencheckFilterMissesIncr() {
+	globalStats.SeencheckFilterMisses.Add(1)
+	if globalPromStats != nil {
+		globalPromStats.seencheckFilterMisses.WithLabelValues(config.Get().Job, hostname, version).Inc()
+	}
+}
+
+// SeencheckFilterFalsePositivesIncr increments by 1 the number of URLs the seencheck filter wrongly reported as maybe seen.
+func SeencheckFilterFalsePositivesIncr() {
+	globalStats.SeencheckFilterFPs.Add(1)
+	if globalPromStats != nil {
+		globalPromStats.seencheckFilterFPs.WithLabelValues(config.Get().Job, hostname, version).Inc()
+	}
+}
+
+// SeencheckFilterHitsGet returns the number of URLs answered as unseen by the seencheck filter.
+func SeencheckFilterHitsGet() int64 { return globalStats.SeencheckFilterHits.Load() }
+
+// SeencheckFilterMissesGet returns the number of URLs looked up in the seencheck database after the filter.
+func SeencheckFilterMissesGet() int64 { return globalStats.SeencheckFilterMisses.Load() }
+
+// SeencheckFilterFalsePositivesGet returns the number of URLs the seencheck filter wrongly reported as maybe seen.
+func SeencheckFilterFalsePositivesGet() int64 { return globalStats.SeencheckFilterFPs.Load() }
+
+// SeencheckFilterHitRateGet returns the percentage of the seencheck lookups answered by the filter alone.
+func SeencheckFilterHitRateGet() float64 {
+	hits := globalStats.SeencheckFilterHits.Load()
+	total := hits + globalStats.SeencheckFilterMisses.Load()
+	if total == 0 {
+		return 0
+	}
+
+	return float64(hits) * 100 / float64(total)
+}
+
 //////////////////////////
 //   MeanHTTPRespTime   //
 //////////////////////////
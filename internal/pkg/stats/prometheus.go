@@ -25,6 +25,15 @@ type prometheusStats struct {
 	meanProcessBodyTime    *prometheus.HistogramVec // in ns
 	meanWaitOnFeedbackTime *prometheus.HistogramVec // in ns
 	warcWritingQueueSize   *prometheus.GaugeVec
+	hostsSaturated         *prometheus.GaugeVec
+	requestsWaitingForHost *prometheus.GaugeVec
+	warcsUploaded          *prometheus.CounterVec
+	warcUploadedBytes      *prometheus.CounterVec
+	warcUploadFailures     *prometheus.CounterVec
+	warcUploadQueueSize    *prometheus.GaugeVec
+	seencheckFilterHits    *prometheus.CounterVec
+	seencheckFilterMisses  *prometheus.CounterVec
+	seencheckFilterFPs     *prometheus.CounterVec
 }
 
 func newPrometheusStats() *prometheusStats {
@@ -89,6 +98,42 @@ func newPrometheusStats() *prometheusStats {
 			prometheus.GaugeOpts{Name: config.Get().PrometheusPrefix + "warc_writing_queue_size", Help: "Size of the WARC writing queue"},
 			[]string{"project", "hostname", "version"},
 		),
+		hostsSaturated: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: config.Get().PrometheusPrefix + "hosts_saturated", Help: "Number of hosts with requests waiting for their concurrency limit"},
+			[]string{"project", "hostname", "version"},
+		),
+		requestsWaitingForHost: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: config.Get().PrometheusPrefix + "requests_waiting_for_host", Help: "Number of requests waiting for their host concurrency limit"},
+			[]string{"project", "hostname", "version"},
+		),
+		warcsUploaded: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: config.Get().PrometheusPrefix + "warcs_uploaded", Help: "Total number of WARC files uploaded"},
+			[]string{"project", "hostname", "version"},
+		),
+		warcUploadedBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: config.Get().PrometheusPrefix + "warc_uploaded_bytes", Help: "Total number of bytes of WARC files uploaded"},
+			[]string{"project", "hostname", "version"},
+		),
+		warcUploadFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: config.Get().PrometheusPrefix + "warc_upload_failures", Help: "Total number of failed WARC file uploads"},
+			[]string{"project", "hostname", "version"},
+		),
+		warcUploadQueueSize: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{Name: config.Get().PrometheusPrefix + "warc_upload_queue_size", Help: "Number of closed WARC files waiting to be uploaded"},
+			[]string{"project", "hostname", "version"},
+		),
+		seencheckFilterHits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: config.Get().PrometheusPrefix + "seencheck_filter_hits", Help: "Total number of URLs answered as unseen by the seencheck filter, without reading the database"},
+			[]string{"project", "hostname", "version"},
+		),
+		seencheckFilterMisses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: config.Get().PrometheusPrefix + "seencheck_filter_misses", Help: "Total number of URLs looked up in the seencheck database after the filter"},
+			[]string{"project", "hostname", "version"},
+		),
+		seencheckFilterFPs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{Name: config.Get().PrometheusPrefix + "seencheck_filter_false_positives", Help: "Total number of URLs looked up in the seencheck database after the filter that weren't seen"},
+			[]string{"project", "hostname", "version"},
+		),
 	}
 }
 
@@ -108,6 +153,15 @@ func registerPrometheusMetrics() {
 	prometheus.MustRegister(globalPromStats.meanProcessBodyTime)
 	prometheus.MustRegister(globalPromStats.warcWritingQueueSize)
 	prometheus.MustRegister(globalPromStats.meanWaitOnFeedbackTime)
+	prometheus.MustRegister(globalPromStats.hostsSaturated)
+	prometheus.MustRegister(globalPromStats.requestsWaitingForHost)
+	prometheus.MustRegister(globalPromStats.warcsUploaded)
+	prometheus.MustRegister(globalPromStats.warcUploadedBytes)
+	prometheus.MustRegister(globalPromStats.warcUploadFailures)
+	prometheus.MustRegister(globalPromStats.warcUploadQueueSize)
+	prometheus.MustRegister(globalPromStats.seencheckFilterHits)
+	prometheus.MustRegister(globalPromStats.seencheckFilterMisses)
+	prometheus.MustRegister(globalPromStats.seencheckFilterFPs)
 }
 
 func PrometheusHandler() http.Handler {
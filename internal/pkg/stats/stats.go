@@ -23,6 +23,15 @@ type stats struct {
 	MeanProcessBodyTime    *mean // in ms
 	MeanWaitOnFeedbackTime *mean // in ms
 	WARCWritingQueueSize   atomic.Int64
+	HostsSaturated         atomic.Int64
+	RequestsWaitingForHost atomic.Int64
+	WARCsUploaded          atomic.Int64
+	WARCUploadedBytes      atomic.Int64
+	WARCUploadFailures     atomic.Int64
+	WARCUploadQueueSize    atomic.Int64
+	SeencheckFilterHits    atomic.Int64
+	SeencheckFilterMisses  atomic.Int64
+	SeencheckFilterFPs     atomic.Int64
 }
 
 var (
@@ -112,5 +121,9 @@ func GetMapTUI() map[string]interface{} {
 		"HTTP 5xx/s":              bucketSum(globalStats.HTTPReturnCodes.getFiltered("5*")),
 		"Mean HTTP response time": globalStats.MeanHTTPResponseTime.get(),
 		"WARC writing queue size": globalStats.WARCWritingQueueSize.Load(),
+		"Saturated hosts":         globalStats.HostsSaturated.Load(),
+		"WARCs uploaded":          globalStats.WARCsUploaded.Load(),
+		"WARC upload queue size":  globalStats.WARCUploadQueueSize.Load(),
+		"Seencheck filter hit %":  SeencheckFilterHitRateGet(),
 	}
 }
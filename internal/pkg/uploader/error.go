@@ -0,0 +1,10 @@
+package uploader
+
+import "errors"
+
+var (
+	// ErrUploaderAlreadyInitialized is the error returned when the uploader is already initialized
+	ErrUploaderAlreadyInitialized = errors.New("uploader already initialized")
+	// ErrVerificationFailed is the error returned when the uploaded object doesn't match the local file
+	ErrVerificationFailed = errors.New("uploaded object doesn't match the local file")
+)
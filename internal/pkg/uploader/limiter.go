@@ -0,0 +1,67 @@
+package uploader
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter limits the bytes per second sent by all the uploads
+type bandwidthLimiter struct {
+	mu   sync.Mutex
+	rate float64 // bytes per second
+	next time.Time
+}
+
+// newBandwidthLimiter returns a limiter to the given bytes per second, nil if rate isn't positive
+func newBandwidthLimiter(rate float64) *bandwidthLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &bandwidthLimiter{rate: rate}
+}
+
+// wait blocks until n more bytes can be sent
+func (l *bandwidthLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// reader returns a reader limited by the limiter
+func (l *bandwidthLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+
+	return &limitedReader{r: r, limiter: l}
+}
+
+type limitedReader struct {
+	r       io.Reader
+	limiter *bandwidthLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Small reads so the bandwidth is shared between the uploads
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+
+	n, err := r.r.Read(p)
+	r.limiter.wait(n)
+
+	return n, err
+}
@@ -0,0 +1,213 @@
+package uploader
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3Client is a minimal client of the S3 multipart upload API, using path-style URLs
+// (https://endpoint/bucket/key) so that it works with S3-compatible stores like MinIO
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+	limiter   *bandwidthLimiter
+	nowFunc   func() time.Time
+}
+
+// s3Error is an error response of the S3 API
+type s3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("S3 error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// completedPart is a part of a multipart upload, as listed when completing it
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func newS3Client(endpoint, region, bucket, accessKey, secretKey string, limiter *bandwidthLimiter) (*s3Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return &s3Client{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		http:      &http.Client{Timeout: 10 * time.Minute},
+		limiter:   limiter,
+		nowFunc:   time.Now,
+	}, nil
+}
+
+// do sends a signed request about the object, returning an *s3Error if the response isn't a success
+func (c *s3Client) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	objectPath := c.endpoint.Path + "/" + c.bucket + "/" + key
+	u := *c.endpoint
+	u.Path = objectPath
+	u.RawPath = uriEncode(objectPath, false)
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = c.limiter.reader(bytes.NewReader(body))
+	}
+
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	signV4(req, hashHex(body), c.accessKey, c.secretKey, c.region, c.nowFunc())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, parseS3Error(resp.StatusCode, resp.Body)
+	}
+
+	return resp, nil
+}
+
+// createMultipartUpload starts a multipart upload of the object and returns its upload ID
+func (c *s3Client) createMultipartUpload(key string) (string, error) {
+	resp, err := c.do(http.MethodPost, key, url.Values{"uploads": {""}}, http.Header{"Content-Type": {"application/warc"}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if result.UploadID == "" {
+		return "", fmt.Errorf("no upload ID in the response")
+	}
+
+	return result.UploadID, nil
+}
+
+// uploadPart uploads a part, the store rejecting it if it doesn't match its MD5. It returns the ETag of the part.
+func (c *s3Client) uploadPart(key, uploadID string, partNumber int, data []byte) (string, error) {
+	sum := md5.Sum(data)
+
+	resp, err := c.do(http.MethodPut, key, url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}, http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}}, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.Header.Get("ETag"), nil
+}
+
+// completeMultipartUpload assembles the parts and returns the ETag of the object
+func (c *s3Client) completeMultipartUpload(key, uploadID string, parts []completedPart) (string, error) {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, http.Header{"Content-Type": {"application/xml"}}, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The completion can fail after a 200 OK, the error being in the body
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.Contains(content, []byte("<Error>")) {
+		return "", parseS3Error(resp.StatusCode, bytes.NewReader(content))
+	}
+
+	var result struct {
+		ETag string `xml:"ETag"`
+	}
+	if err := xml.Unmarshal(content, &result); err != nil {
+		return "", err
+	}
+
+	return result.ETag, nil
+}
+
+// abortMultipartUpload discards the parts of an upload that failed
+func (c *s3Client) abortMultipartUpload(key, uploadID string) error {
+	resp, err := c.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// headObject returns the size and ETag of the object
+func (c *s3Client) headObject(key string) (size int64, etag string, err error) {
+	resp, err := c.do(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+
+	return resp.ContentLength, resp.Header.Get("ETag"), nil
+}
+
+func parseS3Error(statusCode int, body io.Reader) error {
+	s3Err := &s3Error{StatusCode: statusCode}
+
+	content, _ := io.ReadAll(io.LimitReader(body, 64*1024))
+	if xml.Unmarshal(content, s3Err) != nil || s3Err.Code == "" {
+		s3Err.Code = http.StatusText(statusCode)
+	}
+
+	return s3Err
+}
@@ -0,0 +1,487 @@
+// Package uploader ships the closed WARC files to an S3-compatible storage. Each file is sent with a multipart upload,
+// verified against the MD5 digests of its parts, recorded in a manifest, then deleted locally.
+package uploader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/cdxj"
+	"github.com/internetarchive/Zeno/internal/pkg/log"
+	"github.com/internetarchive/Zeno/internal/pkg/stats"
+)
+
+const (
+	// checkInterval is how often the WARC directory is checked for closed WARC files to upload
+	checkInterval = 10 * time.Second
+
+	// failureDelay is how long to wait before uploading again a file whose upload failed
+	failureDelay = time.Minute
+)
+
+// Settings are the settings of the uploader
+type Settings struct {
+	Endpoint     string
+	Region       string
+	Bucket       string
+	Prefix       string // Prefix of the object keys
+	AccessKey    string
+	SecretKey    string
+	PartSize     int64   // in bytes
+	Concurrency  int     // Number of files uploaded in parallel
+	MaxRetries   int     // Retries of each request
+	RateLimit    float64 // in bytes per second, 0 means unlimited
+	WARCsDir     string
+	ManifestPath string
+	WaitForIndex bool // Only upload the WARC files once their CDXJ index is written
+}
+
+// ManifestEntry is a line of the manifest, recording an uploaded WARC file
+type ManifestEntry struct {
+	File       string    `json:"file"`
+	Bucket     string    `json:"bucket"`
+	Key        string    `json:"key"`
+	Bytes      int64     `json:"bytes"`
+	ETag       string    `json:"etag"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type uploader struct {
+	settings   Settings
+	client     *s3Client
+	retryDelay time.Duration // first delay between retries, doubled at each retry
+
+	stop chan struct{}
+	wg   sync.WaitGroup // watcher
+	uwg  sync.WaitGroup // uploads
+	sem  chan struct{}
+
+	mu         sync.Mutex
+	inFlight   map[string]bool
+	retryAfter map[string]time.Time
+	uploaded   map[string]bool // files recorded in the manifest
+
+	manifestMu sync.Mutex
+}
+
+var (
+	globalUploader *uploader
+	once           sync.Once
+	logger         *log.FieldedLogger
+)
+
+// Start starts watching the WARC directory and uploading the closed WARC files, should only be called once
+func Start(settings Settings) error {
+	var done bool
+	var startErr error
+
+	once.Do(func() {
+		done = true
+
+		u, err := newUploader(settings)
+		if err != nil {
+			startErr = err
+			return
+		}
+
+		globalUploader = u
+		globalUploader.wg.Add(1)
+		go globalUploader.watch()
+
+		logger.Info("started", "endpoint", settings.Endpoint, "bucket", settings.Bucket)
+	})
+
+	if !done {
+		return ErrUploaderAlreadyInitialized
+	}
+
+	return startErr
+}
+
+// Stop stops watching the WARC directory, then uploads the WARC files left once the WARC writers are closed.
+// It must be called after the archiver is stopped.
+func Stop() {
+	if globalUploader == nil {
+		return
+	}
+
+	close(globalUploader.stop)
+	globalUploader.wg.Wait()
+
+	// The files that failed recently are tried again, as this is the last chance to upload them
+	globalUploader.mu.Lock()
+	clear(globalUploader.retryAfter)
+	globalUploader.mu.Unlock()
+
+	logger.Info("uploading the remaining WARC files")
+	globalUploader.scan()
+	globalUploader.uwg.Wait()
+
+	stats.WARCUploadQueueSizeSet(0)
+	logger.Info("stopped")
+}
+
+func newUploader(settings Settings) (*uploader, error) {
+	if logger == nil {
+		logger = log.NewFieldedLogger(&log.Fields{
+			"component": "uploader",
+		})
+	}
+
+	if settings.Bucket == "" {
+		return nil, errors.New("no S3 bucket given")
+	}
+
+	if settings.Concurrency < 1 {
+		settings.Concurrency = 1
+	}
+
+	client, err := newS3Client(settings.Endpoint, settings.Region, settings.Bucket, settings.AccessKey, settings.SecretKey, newBandwidthLimiter(settings.RateLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	u := &uploader{
+		settings:   settings,
+		client:     client,
+		retryDelay: time.Second,
+		stop:       make(chan struct{}),
+		sem:        make(chan struct{}, settings.Concurrency),
+		inFlight:   make(map[string]bool),
+		retryAfter: make(map[string]time.Time),
+		uploaded:   make(map[string]bool),
+	}
+
+	if err := u.loadManifest(); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (u *uploader) watch() {
+	defer u.wg.Done()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-u.stop:
+			return
+		case <-ticker.C:
+			u.scan()
+		}
+	}
+}
+
+// scan starts the upload of the closed WARC files that aren't being uploaded,
+// the rotator removing the .open suffix once a file is complete
+func (u *uploader) scan() {
+	warcs, err := filepath.Glob(filepath.Join(u.settings.WARCsDir, "*.warc.gz"))
+	if err != nil {
+		logger.Error("unable to list the WARC files", "err", err.Error(), "func", "uploader.scan")
+		return
+	}
+
+	var queue []string
+	for _, warcPath := range warcs {
+		u.mu.Lock()
+		skip := u.inFlight[warcPath] || time.Now().Before(u.retryAfter[warcPath])
+		u.mu.Unlock()
+		if skip {
+			continue
+		}
+
+		if u.settings.WaitForIndex {
+			if _, err := os.Stat(cdxj.IndexPath(warcPath)); err != nil {
+				continue
+			}
+		}
+
+		queue = append(queue, warcPath)
+	}
+
+	stats.WARCUploadQueueSizeSet(int64(len(queue)))
+
+	for _, warcPath := range queue {
+		u.mu.Lock()
+		u.inFlight[warcPath] = true
+		u.mu.Unlock()
+
+		u.sem <- struct{}{}
+		u.uwg.Add(1)
+		go func() {
+			defer u.uwg.Done()
+			defer func() { <-u.sem }()
+
+			err := u.ship(warcPath)
+
+			u.mu.Lock()
+			delete(u.inFlight, warcPath)
+			if err != nil {
+				u.retryAfter[warcPath] = time.Now().Add(failureDelay)
+			} else {
+				delete(u.retryAfter, warcPath)
+			}
+			u.mu.Unlock()
+
+			if err != nil {
+				stats.WARCUploadFailuresIncr()
+				logger.Error("unable to upload WARC file", "warc", warcPath, "err", err.Error(), "func", "uploader.scan")
+			}
+		}()
+	}
+}
+
+// ship uploads the WARC file, records it in the manifest and deletes it
+func (u *uploader) ship(warcPath string) error {
+	name := filepath.Base(warcPath)
+
+	u.mu.Lock()
+	alreadyUploaded := u.uploaded[name]
+	u.mu.Unlock()
+
+	// Uploaded before the deletion failed
+	if alreadyUploaded {
+		logger.Warn("WARC file already uploaded, deleting it", "warc", warcPath)
+		return os.Remove(warcPath)
+	}
+
+	start := time.Now()
+
+	entry, err := u.upload(warcPath)
+	if err != nil {
+		return err
+	}
+
+	if err := u.record(entry); err != nil {
+		return fmt.Errorf("unable to record the upload in the manifest: %w", err)
+	}
+
+	if err := os.Remove(warcPath); err != nil {
+		return err
+	}
+
+	stats.WARCUploadedIncr(entry.Bytes)
+	logger.Info("WARC file uploaded", "warc", name, "key", entry.Key, "bytes", entry.Bytes, "duration", time.Since(start).String())
+
+	return nil
+}
+
+// upload sends the file with a multipart upload and verifies the object
+func (u *uploader) upload(filePath string) (entry ManifestEntry, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return entry, err
+	}
+
+	key := u.settings.Prefix + filepath.Base(filePath)
+
+	var uploadID string
+	err = u.retry("create multipart upload", func() (err error) {
+		uploadID, err = u.client.createMultipartUpload(key)
+		return err
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	defer func() {
+		if err != nil {
+			if abortErr := u.client.abortMultipartUpload(key, uploadID); abortErr != nil {
+				logger.Warn("unable to abort the multipart upload", "key", key, "err", abortErr.Error())
+			}
+		}
+	}()
+
+	var (
+		parts      []completedPart
+		partDigest []byte // concatenation of the MD5 of the parts, giving the ETag of the object
+		size       int64
+	)
+
+	buf := make([]byte, u.settings.PartSize)
+	for partNumber := 1; ; partNumber++ {
+		n, readErr := io.ReadFull(file, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return entry, readErr
+		}
+
+		// The last part, empty only if the file is empty
+		if n == 0 && partNumber > 1 {
+			break
+		}
+
+		data := buf[:n]
+		sum := md5.Sum(data)
+
+		var etag string
+		err = u.retry("upload part", func() (err error) {
+			etag, err = u.client.uploadPart(key, uploadID, partNumber, data)
+			return err
+		})
+		if err != nil {
+			return entry, err
+		}
+
+		// The ETag of a part is its MD5, unless the store encrypts it with a managed key
+		if unquoted := strings.Trim(etag, `"`); isMD5(unquoted) && unquoted != hex.EncodeToString(sum[:]) {
+			return entry, fmt.Errorf("%w: part %d ETag %s", ErrVerificationFailed, partNumber, etag)
+		}
+
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+		partDigest = append(partDigest, sum[:]...)
+		size += int64(n)
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	var etag string
+	err = u.retry("complete multipart upload", func() (err error) {
+		etag, err = u.client.completeMultipartUpload(key, uploadID, parts)
+		return err
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	objectDigest := md5.Sum(partDigest)
+	expectedETag := fmt.Sprintf("%s-%d", hex.EncodeToString(objectDigest[:]), len(parts))
+
+	var (
+		objectSize int64
+		objectETag string
+	)
+	err = u.retry("head object", func() (err error) {
+		objectSize, objectETag, err = u.client.headObject(key)
+		return err
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	if objectSize != size || size != info.Size() {
+		return entry, fmt.Errorf("%w: %d bytes uploaded, %d bytes stored, %d bytes on disk", ErrVerificationFailed, size, objectSize, info.Size())
+	}
+
+	if unquoted := strings.Trim(objectETag, `"`); strings.Contains(unquoted, "-") && unquoted != expectedETag {
+		return entry, fmt.Errorf("%w: ETag %s, expected %s", ErrVerificationFailed, objectETag, expectedETag)
+	}
+
+	return ManifestEntry{
+		File:       filepath.Base(filePath),
+		Bucket:     u.settings.Bucket,
+		Key:        key,
+		Bytes:      size,
+		ETag:       strings.Trim(etag, `"`),
+		UploadedAt: time.Now().UTC(),
+	}, nil
+}
+
+// retry calls fn until it succeeds, fails with a non-retryable error or the retries are exhausted
+func (u *uploader) retry(operation string, fn func() error) error {
+	delay := u.retryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= u.settings.MaxRetries || !isRetryable(err) {
+			return err
+		}
+
+		logger.Warn("S3 request failed, retrying", "operation", operation, "attempt", attempt+1, "delay", delay.String(), "err", err.Error())
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isRetryable returns true for the network errors and the server-side S3 errors
+func isRetryable(err error) bool {
+	var s3Err *s3Error
+	if errors.As(err, &s3Err) {
+		return s3Err.StatusCode >= 500 || s3Err.StatusCode == http.StatusTooManyRequests || s3Err.StatusCode == http.StatusRequestTimeout
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func isMD5(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// loadManifest reads the files already uploaded from the manifest
+func (u *uploader) loadManifest() error {
+	content, err := os.ReadFile(u.settings.ManifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		var entry ManifestEntry
+		if line == "" || json.Unmarshal([]byte(line), &entry) != nil {
+			continue
+		}
+		u.uploaded[entry.File] = true
+	}
+
+	return nil
+}
+
+// record appends the upload to the manifest
+func (u *uploader) record(entry ManifestEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	u.manifestMu.Lock()
+	defer u.manifestMu.Unlock()
+
+	manifest, err := os.OpenFile(u.settings.ManifestPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := manifest.Write(append(line, '\n')); err != nil {
+		manifest.Close()
+		return err
+	}
+
+	if err := manifest.Close(); err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	u.uploaded[entry.File] = true
+	u.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrUnsupportedURL is returned when the URL can't be turned into a SURT key (e.g. dns: URIs)
+var ErrUnsupportedURL = errors.New("unsupported URL")
+
+var wwwPrefix = regexp.MustCompile(`^www\d*\.`)
+
+// SURT returns the Sort-friendly URI Reordering Transform of the URL, used as the key of the CDXJ lines and of the canonicalized URLs:
+// the host labels are reversed and comma separated, the default port, www prefix and fragment are dropped,
+// the query parameters are sorted and the whole key is lowercased.
+// e.g. https://www.example.com/Path?b=2&a=1 becomes com,example)/path?a=1&b=2
+func SURT(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", ErrUnsupportedURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	var key strings.Builder
+
+	if net.ParseIP(host) != nil {
+		key.WriteString(host)
+	} else {
+		labels := strings.Split(wwwPrefix.ReplaceAllString(host, ""), ".")
+		slices.Reverse(labels)
+		key.WriteString(strings.Join(labels, ","))
+	}
+
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		key.WriteString(":" + port)
+	}
+
+	key.WriteString(")")
+
+	if path := u.EscapedPath(); path != "" {
+		key.WriteString(path)
+	} else {
+		key.WriteString("/")
+	}
+
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		slices.Sort(params)
+		key.WriteString("?" + strings.Join(params, "&"))
+	}
+
+	return strings.ToLower(key.String()), nil
+}
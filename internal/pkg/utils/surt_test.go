@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestSURT(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://www.example.com/Path?b=2&a=1#frag", "com,example)/path?a=1&b=2"},
+		{"http://example.com", "com,example)/"},
+		{"http://sub.example.com:80/", "com,example,sub)/"},
+		{"https://example.com:8443/a", "com,example:8443)/a"},
+		{"http://www2.EXAMPLE.org./", "org,example)/"},
+		{"http://192.168.0.1/index.html", "192.168.0.1)/index.html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			key, err := SURT(tt.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if key != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, key)
+			}
+		})
+	}
+
+	if _, err := SURT("dns:example.com"); err != ErrUnsupportedURL {
+		t.Errorf("expected ErrUnsupportedURL, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package wacz
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// signedData is the anonymous signature of the datapackage digest, as described by the WACZ signing specification
+type signedData struct {
+	Hash      string `json:"hash"`
+	Created   string `json:"created"`
+	Software  string `json:"software"`
+	Signature string `json:"signature"`
+	PublicKey string `json:"publicKey"`
+}
+
+// LoadSigningKey reads a PEM-encoded ECDSA private key, in PKCS #8 or SEC 1 form
+func LoadSigningKey(keyPath string) (*ecdsa.PrivateKey, error) {
+	content, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found in the signing key file")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the signing key must be an ECDSA key")
+	}
+
+	return ecKey, nil
+}
+
+// sign signs the SHA-256 of the datapackage hash string with the key. The signature is ASN.1 DER encoded
+// and the public key is given in its PKIX form, both base64-encoded.
+func sign(key *ecdsa.PrivateKey, hash, created, software string) (*signedData, error) {
+	digest := sha256.Sum256([]byte(hash))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signedData{
+		Hash:      hash,
+		Created:   created,
+		Software:  software,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+	}, nil
+}
@@ -0,0 +1,423 @@
+// Package wacz packages the WARC files of a job into a WACZ file (https://specs.webrecorder.net/wacz/1.1.1/),
+// with a CDXJ index, the pages of the seeds and a datapackage listing the digests of all the files.
+// Everything is streamed to the ZIP file, so the size of the job isn't limited by the memory.
+package wacz
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/internetarchive/Zeno/internal/pkg/archiver/cdxj"
+	"github.com/internetarchive/Zeno/internal/pkg/utils"
+)
+
+// Version is the version of the WACZ specification the packages follow
+const Version = "1.1.1"
+
+// ErrNoWARCs is returned when the job has no closed WARC files to package
+var ErrNoWARCs = errors.New("no WARC files to package")
+
+// Seed is a seed of the job, listed as a page of the WACZ if it was captured
+type Seed struct {
+	ID  string
+	URL string
+}
+
+// Options are the optional contents of the WACZ
+type Options struct {
+	Title       string
+	Software    string
+	Seeds       []Seed
+	DetectPages bool              // List all the HTML pages captured instead of the seeds
+	SigningKey  *ecdsa.PrivateKey // Signs the datapackage digest if set
+}
+
+// Summary describes a created WACZ
+type Summary struct {
+	WARCs int
+	Pages int
+	Bytes int64
+}
+
+// resource is a file of the WACZ, as listed in datapackage.json
+type resource struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Hash  string `json:"hash"`
+	Bytes int64  `json:"bytes"`
+}
+
+type dataPackage struct {
+	Profile      string     `json:"profile"`
+	WACZVersion  string     `json:"wacz_version"`
+	Title        string     `json:"title,omitempty"`
+	Software     string     `json:"software"`
+	Created      string     `json:"created"`
+	MainPageURL  string     `json:"mainPageURL,omitempty"`
+	MainPageDate string     `json:"mainPageDate,omitempty"`
+	Resources    []resource `json:"resources"`
+}
+
+type dataPackageDigest struct {
+	Path       string      `json:"path"`
+	Hash       string      `json:"hash"`
+	SignedData *signedData `json:"signedData,omitempty"`
+}
+
+type page struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	TS  string `json:"ts"`
+}
+
+// Create packages the closed WARC files of the job directory into a WACZ file at output.
+// The CDXJ indexes already written next to the WARC files are reused, the others are generated in a temporary directory.
+func Create(jobPath, output string, opts Options) (summary Summary, err error) {
+	warcs, err := filepath.Glob(filepath.Join(jobPath, "warcs", "*.warc.gz"))
+	if err != nil {
+		return summary, err
+	}
+
+	if len(warcs) == 0 {
+		return summary, ErrNoWARCs
+	}
+
+	tmpDir, err := os.MkdirTemp("", "zeno-wacz-")
+	if err != nil {
+		return summary, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	indexPath := filepath.Join(tmpDir, "index.cdxj")
+	if err := buildIndex(warcs, tmpDir, indexPath); err != nil {
+		return summary, err
+	}
+
+	pagesPath := filepath.Join(tmpDir, "pages.jsonl")
+	pages, err := writePages(indexPath, pagesPath, opts.Seeds, opts.DetectPages)
+	if err != nil {
+		return summary, err
+	}
+
+	tmpOutput := output + ".tmp"
+	file, err := os.Create(tmpOutput)
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpOutput)
+		}
+	}()
+
+	counter := &countingWriter{w: file}
+	archive := zip.NewWriter(counter)
+
+	pkg := dataPackage{
+		Profile:     "data-package",
+		WACZVersion: Version,
+		Title:       opts.Title,
+		Software:    opts.Software,
+		Created:     time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if len(pages) == 1 {
+		pkg.MainPageURL = pages[0].URL
+		pkg.MainPageDate = pages[0].TS
+	}
+
+	// The WARC files are already compressed, they are stored as is
+	for _, warcPath := range warcs {
+		res, err := addFile(archive, warcPath, "archive/"+filepath.Base(warcPath), zip.Store)
+		if err != nil {
+			return summary, err
+		}
+		pkg.Resources = append(pkg.Resources, res)
+	}
+
+	for _, f := range []struct{ path, name string }{{indexPath, "indexes/index.cdxj"}, {pagesPath, "pages/pages.jsonl"}} {
+		res, err := addFile(archive, f.path, f.name, zip.Deflate)
+		if err != nil {
+			return summary, err
+		}
+		pkg.Resources = append(pkg.Resources, res)
+	}
+
+	pkgJSON, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		return summary, err
+	}
+
+	if err := addBytes(archive, "datapackage.json", pkgJSON); err != nil {
+		return summary, err
+	}
+
+	digest := dataPackageDigest{
+		Path: "datapackage.json",
+		Hash: sha256Digest(pkgJSON),
+	}
+
+	if opts.SigningKey != nil {
+		digest.SignedData, err = sign(opts.SigningKey, digest.Hash, pkg.Created, opts.Software)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	digestJSON, err := json.MarshalIndent(digest, "", "  ")
+	if err != nil {
+		return summary, err
+	}
+
+	if err := addBytes(archive, "datapackage-digest.json", digestJSON); err != nil {
+		return summary, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return summary, err
+	}
+
+	if err := file.Close(); err != nil {
+		return summary, err
+	}
+
+	if err := os.Rename(tmpOutput, output); err != nil {
+		return summary, err
+	}
+
+	return Summary{WARCs: len(warcs), Pages: len(pages), Bytes: counter.n}, nil
+}
+
+// buildIndex writes the merged CDXJ index of the WARC files at indexPath
+func buildIndex(warcs []string, tmpDir, indexPath string) error {
+	indexes := make([]string, 0, len(warcs))
+
+	for _, warcPath := range warcs {
+		existing := cdxj.IndexPath(warcPath)
+		if indexInfo, err := os.Stat(existing); err == nil {
+			if warcInfo, err := os.Stat(warcPath); err == nil && !indexInfo.ModTime().Before(warcInfo.ModTime()) {
+				indexes = append(indexes, existing)
+				continue
+			}
+		}
+
+		generated := filepath.Join(tmpDir, filepath.Base(cdxj.IndexPath(warcPath)))
+		if err := cdxj.WriteIndexTo(warcPath, generated); err != nil {
+			return err
+		}
+		indexes = append(indexes, generated)
+	}
+
+	return cdxj.Merge(indexes, indexPath)
+}
+
+// writePages writes the pages.jsonl file listing the seeds found in the index at their first capture,
+// or all the HTML pages successfully captured if detect is true
+func writePages(indexPath, pagesPath string, seeds []Seed, detect bool) (pages []page, err error) {
+	if detect {
+		pages, err = detectPages(indexPath)
+	} else {
+		pages, err = seedPages(indexPath, seeds)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(pagesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(map[string]string{"format": "json-pages-1.0", "id": "pages", "title": "All Pages"}); err != nil {
+		return nil, err
+	}
+
+	for _, p := range pages {
+		if err := encoder.Encode(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return pages, file.Close()
+}
+
+// seedPages returns the seeds found in the index, at their first capture
+func seedPages(indexPath string, seeds []Seed) (pages []page, err error) {
+	captures := make(map[string]string, len(seeds))
+	for _, seed := range seeds {
+		if key, err := utils.SURT(seed.URL); err == nil {
+			captures[key] = ""
+		}
+	}
+
+	if len(captures) == 0 {
+		return nil, nil
+	}
+
+	err = scanIndex(indexPath, func(key, timestamp, _ string) {
+		// The lines of a key are sorted by timestamp, the first one is kept
+		if ts, ok := captures[key]; ok && ts == "" {
+			captures[key] = timestamp
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seed := range seeds {
+		key, err := utils.SURT(seed.URL)
+		if err != nil || captures[key] == "" {
+			continue
+		}
+
+		ts, err := time.Parse("20060102150405", captures[key])
+		if err != nil {
+			continue
+		}
+
+		// Only list the first seed of a key
+		captures[key] = ""
+		pages = append(pages, page{ID: seed.ID, URL: seed.URL, TS: ts.UTC().Format(time.RFC3339)})
+	}
+
+	return pages, nil
+}
+
+// detectPages returns the first successful capture of each HTML page of the index
+func detectPages(indexPath string) (pages []page, err error) {
+	var lastKey string
+
+	err = scanIndex(indexPath, func(key, timestamp, block string) {
+		if key == lastKey {
+			return
+		}
+
+		var fields struct {
+			URL    string `json:"url"`
+			Mime   string `json:"mime"`
+			Status string `json:"status"`
+		}
+		if json.Unmarshal([]byte(block), &fields) != nil || fields.Mime != "text/html" || !strings.HasPrefix(fields.Status, "2") {
+			return
+		}
+
+		ts, err := time.Parse("20060102150405", timestamp)
+		if err != nil {
+			return
+		}
+
+		lastKey = key
+		pages = append(pages, page{
+			ID:  uuid.NewSHA1(uuid.NameSpaceURL, []byte(fields.URL)).String(),
+			URL: fields.URL,
+			TS:  ts.UTC().Format(time.RFC3339),
+		})
+	})
+
+	return pages, err
+}
+
+// scanIndex calls fn with the key, timestamp and JSON block of each line of the CDXJ index
+func scanIndex(indexPath string, fn func(key, timestamp, block string)) error {
+	index, err := os.Open(indexPath)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	reader := bufio.NewReader(index)
+	for {
+		line, err := reader.ReadString('\n')
+		if fields := strings.SplitN(strings.TrimSuffix(line, "\n"), " ", 3); len(fields) == 3 {
+			fn(fields[0], fields[1], fields[2])
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// addFile streams the file into the archive, computing its digest on the way
+func addFile(archive *zip.Writer, filePath, name string, method uint16) (res resource, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return res, err
+	}
+	defer file.Close()
+
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return res, err
+	}
+
+	digest := sha256.New()
+	written, err := io.Copy(io.MultiWriter(w, digest), file)
+	if err != nil {
+		return res, err
+	}
+
+	return resource{
+		Name:  strings.ToLower(path.Base(name)),
+		Path:  name,
+		Hash:  hashString(digest),
+		Bytes: written,
+	}, nil
+}
+
+func addBytes(archive *zip.Writer, name string, content []byte) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(content)
+	return err
+}
+
+func sha256Digest(content []byte) string {
+	digest := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
+
+func hashString(h hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// countingWriter counts the bytes written to the WACZ file
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
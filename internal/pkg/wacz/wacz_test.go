@@ -0,0 +1,238 @@
+package wacz
+
+import (
+	"archive/zip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CorentinB/warc"
+)
+
+// writeTestJob writes a job directory with a WARC file holding HTML and CSS responses
+func writeTestJob(t *testing.T) string {
+	t.Helper()
+
+	jobPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(jobPath, "warcs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Create(filepath.Join(jobPath, "warcs", "ZENO-00001.warc.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	responses := []struct{ url, date, contentType string }{
+		{"https://example.com/", "2025-01-01T00:00:00Z", "text/html"},
+		{"https://example.com/style.css", "2025-01-01T00:00:01Z", "text/css"},
+		{"https://example.com/about", "2025-01-01T00:00:02Z", "text/html; charset=utf-8"},
+		{"https://example.com/", "2025-01-02T00:00:00Z", "text/html"},
+	}
+
+	for _, response := range responses {
+		writer, err := warc.NewWriter(file, "ZENO-00001.warc.gz", "GZIP", "", false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		record := warc.NewRecord(t.TempDir(), false)
+		record.Header.Set("WARC-Type", "response")
+		record.Header.Set("WARC-Target-URI", response.url)
+		record.Header.Set("WARC-Date", response.date)
+		record.Header.Set("Content-Type", "application/http; msgtype=response")
+		record.Content.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: " + response.contentType + "\r\n\r\nbody"))
+
+		if _, err := writer.WriteRecord(record); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writer.CloseCompressedWriter(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return jobPath
+}
+
+// readWACZ returns the files of the WACZ by name
+func readWACZ(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("invalid WACZ: %v", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string][]byte)
+	for _, f := range archive.File {
+		if strings.HasPrefix(f.Name, "archive/") && f.Method != zip.Store {
+			t.Errorf("expected %s to be stored without compression", f.Name)
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return files
+}
+
+func TestCreate(t *testing.T) {
+	jobPath := writeTestJob(t)
+	output := filepath.Join(t.TempDir(), "job.wacz")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := Create(jobPath, output, Options{
+		Title:    "job",
+		Software: "Zeno/test",
+		Seeds: []Seed{
+			{ID: "1", URL: "https://example.com/"},
+			{ID: "2", URL: "https://example.com/never-captured"},
+		},
+		SigningKey: key,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if summary.WARCs != 1 || summary.Pages != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	files := readWACZ(t, output)
+
+	for _, name := range []string{"archive/ZENO-00001.warc.gz", "indexes/index.cdxj", "pages/pages.jsonl", "datapackage.json", "datapackage-digest.json"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("expected %s in the WACZ", name)
+		}
+	}
+
+	if lines := strings.Split(strings.TrimSpace(string(files["indexes/index.cdxj"])), "\n"); len(lines) != 4 {
+		t.Errorf("expected 4 index lines, got %d", len(lines))
+	}
+
+	pages := strings.Split(strings.TrimSpace(string(files["pages/pages.jsonl"])), "\n")
+	if len(pages) != 2 || pages[1] != `{"id":"1","url":"https://example.com/","ts":"2025-01-01T00:00:00Z"}` {
+		t.Errorf("unexpected pages %v", pages)
+	}
+
+	var pkg dataPackage
+	if err := json.Unmarshal(files["datapackage.json"], &pkg); err != nil {
+		t.Fatalf("invalid datapackage.json: %v", err)
+	}
+
+	if pkg.MainPageURL != "https://example.com/" || pkg.Title != "job" || len(pkg.Resources) != 3 {
+		t.Errorf("unexpected datapackage %+v", pkg)
+	}
+
+	for _, res := range pkg.Resources {
+		if expected := sha256Digest(files[res.Path]); res.Hash != expected || res.Bytes != int64(len(files[res.Path])) {
+			t.Errorf("unexpected hash or size for %s", res.Path)
+		}
+	}
+
+	var digest dataPackageDigest
+	if err := json.Unmarshal(files["datapackage-digest.json"], &digest); err != nil {
+		t.Fatalf("invalid datapackage-digest.json: %v", err)
+	}
+
+	if digest.Hash != sha256Digest(files["datapackage.json"]) || digest.SignedData == nil || digest.SignedData.Hash != digest.Hash {
+		t.Fatalf("unexpected digest %+v", digest)
+	}
+
+	publicKeyDER, _ := base64.StdEncoding.DecodeString(digest.SignedData.PublicKey)
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyDER)
+	if err != nil {
+		t.Fatalf("invalid public key: %v", err)
+	}
+
+	signature, _ := base64.StdEncoding.DecodeString(digest.SignedData.Signature)
+	hash := sha256.Sum256([]byte(digest.Hash))
+	if !ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), hash[:], signature) {
+		t.Error("invalid signature")
+	}
+}
+
+func TestCreateDetectPages(t *testing.T) {
+	jobPath := writeTestJob(t)
+	output := filepath.Join(t.TempDir(), "job.wacz")
+
+	summary, err := Create(jobPath, output, Options{DetectPages: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The HTML pages, each at its first capture
+	if summary.Pages != 2 {
+		t.Errorf("expected 2 pages, got %d", summary.Pages)
+	}
+
+	files := readWACZ(t, output)
+	if !strings.Contains(string(files["pages/pages.jsonl"]), `"url":"https://example.com/","ts":"2025-01-01T00:00:00Z"`) {
+		t.Errorf("unexpected pages %s", files["pages/pages.jsonl"])
+	}
+
+	if _, err := Create(t.TempDir(), output, Options{}); err != ErrNoWARCs {
+		t.Errorf("expected ErrNoWARCs, got %v", err)
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sec1, _ := x509.MarshalECPrivateKey(key)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	dir := t.TempDir()
+	for name, block := range map[string]*pem.Block{
+		"sec1.pem":  {Type: "EC PRIVATE KEY", Bytes: sec1},
+		"pkcs8.pem": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadSigningKey(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !loaded.Equal(key) {
+			t.Errorf("%s: unexpected key", name)
+		}
+	}
+
+	path := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(path, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSigningKey(path); err == nil {
+		t.Error("expected an error on an invalid key")
+	}
+}
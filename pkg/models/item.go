@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 
@@ -69,6 +70,16 @@ func (s ItemState) String() string {
 	}
 }
 
+// Hop types of the hop paths, as in Heritrix
+const (
+	// HopLink is a hop to an outlink
+	HopLink = "L"
+	// HopEmbed is a hop to an asset
+	HopEmbed = "E"
+	// HopRedirect is a hop to a redirection
+	HopRedirect = "R"
+)
+
 // ItemSource qualifies the source of a item in the pipeline
 type ItemSource int64
 
@@ -85,6 +96,23 @@ const (
 	ItemSourceFeedback
 )
 
+func (s ItemSource) String() string {
+	switch s {
+	case ItemSourceInsert:
+		return "Insert"
+	case ItemSourceQueue:
+		return "Queue"
+	case ItemSourceHQ:
+		return "HQ"
+	case ItemSourcePostprocess:
+		return "Postprocess"
+	case ItemSourceFeedback:
+		return "Feedback"
+	default:
+		return "Unknown"
+	}
+}
+
 // CheckConsistency checks if the item is consistent with the constraints of the model
 // Developers should add more constraints as needed
 // Ideally this function should be called after every mutation of an item object to ensure consistency and throw a panic if consistency is broken
@@ -249,6 +277,33 @@ func (i *Item) GetSeed() *Item {
 	return nil
 }
 
+// GetHopPath returns the hops from the seed to the item: one L per hop to the seed, then E for assets and R for redirections
+func (i *Item) GetHopPath() string {
+	var path []string
+
+	current := i
+	for ; !current.IsSeed(); current = current.parent {
+		if current.IsRedirection() {
+			path = append(path, HopRedirect)
+		} else {
+			path = append(path, HopEmbed)
+		}
+	}
+
+	slices.Reverse(path)
+
+	return strings.Repeat(HopLink, current.url.GetHops()) + strings.Join(path, "")
+}
+
+// GetVia returns the URL the item was discovered from, if known
+func (i *Item) GetVia() string {
+	if i.IsSeed() {
+		return i.seedVia
+	}
+
+	return i.parent.url.String()
+}
+
 // GetNodesAtLevel returns all the nodes at a given level in the seed
 //
 // Can be paired with item.GetMaxDepth() to get all the items at the max depth (i.e.: all the items that potentially need work)
@@ -26,6 +26,8 @@ type URL struct {
 	Hops      int // This determines the number of hops this item is the result of, a hop is a "jump" from 1 page to another page
 	Redirects int
 
+	responseRecordID string
+
 	stringCache string
 	once        sync.Once
 }
@@ -95,6 +97,16 @@ func (u *URL) GetResponse() *http.Response {
 	return u.response
 }
 
+// SetResponseRecordID sets the WARC-Record-ID (a UUID) of the response record of the URL
+func (u *URL) SetResponseRecordID(recordID string) {
+	u.responseRecordID = recordID
+}
+
+// GetResponseRecordID returns the WARC-Record-ID (a UUID) of the response record of the URL, if known
+func (u *URL) GetResponseRecordID() string {
+	return u.responseRecordID
+}
+
 func (u *URL) GetRedirects() int {
 	return u.Redirects
 }
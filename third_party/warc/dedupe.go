@@ -0,0 +1,96 @@
+package warc
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var CDXHTTPClient = http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+type DedupeOptions struct {
+	// Lookup, if set, is called for the payloads not found by the local dedupe, before the CDX dedupe
+	Lookup        LookupFunc
+	CDXURL        string
+	CDXCookie     string
+	SizeThreshold int
+	LocalDedupe   bool
+	CDXDedupe     bool
+}
+
+// Revisit is the original capture of a payload, that a revisit record refers to
+type Revisit struct {
+	ResponseUUID string // UUID of the WARC-Record-ID of the original response record, if known
+	TargetURI    string
+	Date         string // W3C ISO 8601 date of the original capture
+	Size         int
+}
+
+// LookupFunc looks up the original capture of a payload by its digest (base32 SHA-1, without the sha1: prefix),
+// the target URI of the response being given for information. If found, a revisit record referring to the
+// capture is written instead of the response, whatever its target URI.
+type LookupFunc func(digest, targetURI string) (revisit Revisit, found bool)
+
+type revisitRecord struct {
+	responseUUID string
+	targetURI    string
+	date         string
+	size         int
+}
+
+func (d *customDialer) checkLocalRevisit(digest string) revisitRecord {
+	revisit, exists := d.client.dedupeHashTable.Load(digest)
+	if exists {
+		return revisit.(revisitRecord)
+	}
+
+	return revisitRecord{}
+}
+
+func checkCDXRevisit(CDXURL string, digest string, targetURI string, cookie string) (revisitRecord, error) {
+	req, err := http.NewRequest("GET", CDXURL+"/web/timemap/cdx?url="+url.QueryEscape(targetURI)+"&limit=-1", nil)
+	if err != nil {
+		return revisitRecord{}, err
+	}
+
+	if cookie != "" {
+		req.Header.Add("Cookie", cookie)
+	}
+	resp, err := CDXHTTPClient.Do(req)
+	if err != nil {
+		return revisitRecord{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return revisitRecord{}, err
+	}
+
+	cdxReply := strings.Fields(string(body))
+
+	if len(cdxReply) >= 7 && cdxReply[3] != "warc/revisit" && cdxReply[5] == digest {
+		recordSize, _ := strconv.Atoi(cdxReply[6])
+
+		return revisitRecord{
+			responseUUID: "",
+			size:         recordSize,
+			targetURI:    cdxReply[2],
+			date:         cdxReply[1],
+		}, nil
+	}
+
+	return revisitRecord{}, nil
+}